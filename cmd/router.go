@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/config"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/handlers"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/middleware"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/notify"
+	"github.com/redis/go-redis/v9"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"gorm.io/gorm"
+)
+
+// newRouter wires the handlers into the API routes. The returned function
+// starts the background jobs, which run until ctx is cancelled.
+func newRouter(cfg *config.Config, db *gorm.DB, redisClient *redis.Client) (*gin.Engine, func(ctx context.Context)) {
+	// Setup router; query tokens are taken out of the URL before it is logged
+	router := gin.New()
+	router.Use(middleware.HideQueryToken(), gin.Logger(), gin.Recovery())
+
+	//Setup middleware
+	authMiddleware := middleware.NewAuthMiddleware(cfg.JWTSecret, db, redisClient)
+	idempotencyMiddleware := middleware.NewIdempotencyMiddleware(redisClient)
+
+	// Setup handlers
+	ledger := handlers.NewLedgerSettings(cfg)
+	authHandler := handlers.NewAuthHandler(db, redisClient, cfg)
+	productHandler := handlers.NewProductHandler(db, redisClient, ledger)
+	stockHandler := handlers.NewStockHandler(db, redisClient, ledger, cfg.ApprovalThreshold)
+	warehouseHandler := handlers.NewWarehouseHandler(db)
+	transferHandler := handlers.NewTransferHandler(db, redisClient, ledger)
+	serialHandler := handlers.NewSerialHandler(db)
+	reportHandler := handlers.NewReportHandler(db, ledger)
+	supplierHandler := handlers.NewSupplierHandler(db)
+	purchaseOrderHandler := handlers.NewPurchaseOrderHandler(db, redisClient, ledger)
+	salesOrderHandler := handlers.NewSalesOrderHandler(db, redisClient, cfg.ReservationTTL, ledger)
+	returnHandler := handlers.NewReturnHandler(db, redisClient, ledger)
+	movementCatalogHandler := handlers.NewMovementCatalogHandler(db)
+	stocktakeHandler := handlers.NewStocktakeHandler(db, redisClient, ledger)
+	cycleCountHandler := handlers.NewCycleCountHandler(db, cfg.ABCLookbackDays)
+	replenishmentHandler := handlers.NewReplenishmentHandler(db)
+	forecastHandler := handlers.NewForecastHandler(db, redisClient, cfg.ServiceLevel, cfg.LeadTimeDays)
+	alertHandler := handlers.NewAlertHandler(db, notify.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	})
+	roleHandler := handlers.NewRoleHandler(db)
+	serviceAccountHandler := handlers.NewServiceAccountHandler(db, cfg.APIKeyMaxTTL)
+	eventHandler := handlers.NewEventHandler(db)
+	if err := eventHandler.Subscribe("cache-invalidation", handlers.CacheInvalidationConsumer(redisClient)); err != nil {
+		log.Printf("Warning: Failed to register cache invalidation subscriber: %v", err)
+	}
+	streamHandler := handlers.NewStreamHandler(db, redisClient)
+	if err := eventHandler.Subscribe("stock-stream", streamHandler.PublishConsumer()); err != nil {
+		log.Printf("Warning: Failed to register stock stream publisher: %v", err)
+	}
+
+	startJobs := func(ctx context.Context) {
+		// Release sales order reservations once they expire
+		go salesOrderHandler.ExpireReservations(ctx, 30*time.Second)
+
+		// Open each day's ABC cycle counts
+		go cycleCountHandler.ScheduleCycleCounts(ctx, time.Hour)
+
+		// Send stock alerts to webhooks and email, retrying failures
+		go alertHandler.DeliverAlerts(ctx, 10*time.Second)
+
+		// Drop refresh tokens that have expired
+		go authHandler.PurgeRefreshTokens(ctx, time.Hour)
+
+		// Publish outbox events and deliver them to subscribers
+		go eventHandler.DispatchEvents(ctx, time.Second)
+
+		// Relay stream events from Redis to clients connected to this instance
+		go streamHandler.Listen(ctx)
+	}
+
+	// Public routers
+	public := router.Group("/api/v1")
+	{
+		public.POST("/register", authHandler.Register)
+		public.POST("/login", authHandler.Login)
+		public.POST("/refresh", authHandler.Refresh)
+		public.GET("/auth/google", authHandler.GoogleLogin)
+		public.GET("/auth/google/callback", authHandler.GoogleCallback)
+		public.GET("/auth/providers", authHandler.GetAuthProviders)
+		public.GET("/auth/oidc/:provider", authHandler.OIDCLogin)
+		public.GET("/auth/oidc/:provider/callback", authHandler.OIDCCallback)
+		public.GET("/health", handlers.HealthCheck)
+	}
+
+	// Reading records needs inventory:read, so API keys only read what their scopes allow
+	read := authMiddleware.RequirePermission(models.PermInventoryRead)
+
+	// Streaming routers, browsers' EventSource may send the token as a query parameter
+	stream := router.Group("/api/v1/stream", authMiddleware.TokenFromQuery(), authMiddleware.ValidateJWT(), read)
+	{
+		stream.GET("/stock", streamHandler.StreamStock)
+	}
+
+	// Protected routers
+	protected := router.Group("/api/v1")
+	protected.Use(authMiddleware.ValidateJWT())
+	{
+		// Sessions
+		protected.POST("/logout", authHandler.Logout)
+		protected.POST("/logout/all", authHandler.LogoutAll)
+
+		// Users, roles, permissions and service accounts
+		admin := protected.Group("", authMiddleware.RequirePermission(models.PermUserAdmin))
+		{
+			admin.GET("/users", roleHandler.GetUsers)
+			admin.PUT("/users/:id/role", authHandler.UpdateUserRole)
+			admin.GET("/permissions", roleHandler.GetPermissions)
+			admin.GET("/roles", roleHandler.GetRoles)
+			admin.POST("/roles", roleHandler.CreateRole)
+			admin.PUT("/roles/:id", roleHandler.UpdateRole)
+			admin.DELETE("/roles/:id", roleHandler.DeleteRole)
+			admin.GET("/service-accounts", serviceAccountHandler.GetServiceAccounts)
+			admin.POST("/service-accounts", serviceAccountHandler.CreateServiceAccount)
+			admin.DELETE("/service-accounts/:id", serviceAccountHandler.DeleteServiceAccount)
+			admin.GET("/service-accounts/:id/api-keys", serviceAccountHandler.GetAPIKeys)
+			admin.POST("/service-accounts/:id/api-keys", serviceAccountHandler.CreateAPIKey)
+			admin.POST("/api-keys/:id/rotate", serviceAccountHandler.RotateAPIKey)
+			admin.DELETE("/api-keys/:id", serviceAccountHandler.RevokeAPIKey)
+		}
+
+		// Product manage
+		product := protected.Group("/products")
+		{
+			product.GET("", read, productHandler.GetProducts)
+			product.GET("/:id", read, productHandler.GetProductByID)
+			product.POST("", authMiddleware.RequirePermission(models.PermProductWrite), idempotencyMiddleware.Handle(), productHandler.CreateProduct)
+			product.PUT("/:id", authMiddleware.RequirePermission(models.PermProductWrite), productHandler.UpdateProduct)
+			product.DELETE("/:id", authMiddleware.RequirePermission(models.PermProductWrite), productHandler.DeleteProduct)
+			product.GET("/:id/forecast", read, forecastHandler.GetForecast)
+			product.POST("/:id/forecast/apply", authMiddleware.RequirePermission(models.PermProductWrite), forecastHandler.ApplyForecast)
+		}
+
+		// Stock manage
+		stock := protected.Group("/stocks")
+		{
+			stock.GET("", read, stockHandler.GetCurrentStock)
+			stock.GET("product/:id", read, stockHandler.GetStockHistory)
+			stock.GET("product/:id/lots", read, stockHandler.GetProductLots)
+			stock.POST("", authMiddleware.RequirePermission(models.PermStockWrite), idempotencyMiddleware.Handle(), stockHandler.CreateStockMovement)
+			stock.POST("/:id/reverse", authMiddleware.RequirePermission(models.PermStockWrite), stockHandler.ReverseStockMovement)
+			stock.GET("/pending", authMiddleware.RequirePermission(models.PermStockApprove), stockHandler.GetPendingMovements)
+			stock.POST("/:id/approve", authMiddleware.RequirePermission(models.PermStockApprove), stockHandler.ApproveStockMovement)
+			stock.POST("/:id/reject", authMiddleware.RequirePermission(models.PermStockApprove), stockHandler.RejectStockMovement)
+		}
+
+		// Warehouse manage
+		warehouse := protected.Group("/warehouses")
+		{
+			warehouse.GET("", read, warehouseHandler.GetWarehouses)
+			warehouse.GET("/:id", read, warehouseHandler.GetWarehouseByID)
+			warehouse.POST("", authMiddleware.RequirePermission(models.PermWarehouseWrite), warehouseHandler.CreateWarehouse)
+			warehouse.PUT("/:id", authMiddleware.RequirePermission(models.PermWarehouseWrite), warehouseHandler.UpdateWarehouse)
+			warehouse.DELETE("/:id", authMiddleware.RequirePermission(models.PermWarehouseWrite), warehouseHandler.DeleteWarehouse)
+		}
+
+		// Inter-warehouse transfers
+		transfer := protected.Group("/transfers")
+		{
+			transfer.GET("", read, transferHandler.GetTransfers)
+			transfer.GET("/:id", read, transferHandler.GetTransferByID)
+			transfer.POST("", authMiddleware.RequirePermission(models.PermTransferWrite), transferHandler.CreateTransfer)
+			transfer.POST("/:id/receive", authMiddleware.RequirePermission(models.PermTransferWrite), transferHandler.ReceiveTransfer)
+			transfer.POST("/:id/close", authMiddleware.RequirePermission(models.PermTransferWrite), transferHandler.CloseTransfer)
+		}
+
+		// Supplier manage
+		supplier := protected.Group("/suppliers")
+		{
+			supplier.GET("", read, supplierHandler.GetSuppliers)
+			supplier.GET("/:id", read, supplierHandler.GetSupplierByID)
+			supplier.POST("", authMiddleware.RequirePermission(models.PermSupplierWrite), supplierHandler.CreateSupplier)
+			supplier.PUT("/:id", authMiddleware.RequirePermission(models.PermSupplierWrite), supplierHandler.UpdateSupplier)
+			supplier.DELETE("/:id", authMiddleware.RequirePermission(models.PermSupplierWrite), supplierHandler.DeleteSupplier)
+			supplier.GET("/:id/products", read, supplierHandler.GetSupplierProducts)
+			supplier.PUT("/:id/products", authMiddleware.RequirePermission(models.PermSupplierWrite), supplierHandler.SetSupplierProduct)
+			supplier.DELETE("/:id/products/:product_id", authMiddleware.RequirePermission(models.PermSupplierWrite), supplierHandler.DeleteSupplierProduct)
+		}
+
+		// Purchase orders
+		purchaseOrder := protected.Group("/purchase-orders")
+		{
+			purchaseOrder.GET("", read, purchaseOrderHandler.GetPurchaseOrders)
+			purchaseOrder.GET("/:id", read, purchaseOrderHandler.GetPurchaseOrderByID)
+			purchaseOrder.POST("", authMiddleware.RequirePermission(models.PermPurchaseWrite), purchaseOrderHandler.CreatePurchaseOrder)
+			purchaseOrder.PUT("/:id", authMiddleware.RequirePermission(models.PermPurchaseWrite), purchaseOrderHandler.UpdatePurchaseOrder)
+			purchaseOrder.POST("/:id/approve", authMiddleware.RequirePermission(models.PermPurchaseApprove), purchaseOrderHandler.ApprovePurchaseOrder)
+			purchaseOrder.POST("/:id/receive", authMiddleware.RequirePermission(models.PermPurchaseWrite), purchaseOrderHandler.ReceivePurchaseOrder)
+			purchaseOrder.POST("/:id/close", authMiddleware.RequirePermission(models.PermPurchaseApprove), purchaseOrderHandler.ClosePurchaseOrder)
+			purchaseOrder.POST("/:id/cancel", authMiddleware.RequirePermission(models.PermPurchaseApprove), purchaseOrderHandler.CancelPurchaseOrder)
+		}
+
+		// Replenishment suggestions and the purchase orders raised from them
+		replenishment := protected.Group("/replenishment")
+		{
+			replenishment.GET("/runs", read, replenishmentHandler.GetReplenishmentRuns)
+			replenishment.GET("/runs/:id", read, replenishmentHandler.GetReplenishmentRunByID)
+			replenishment.POST("/runs", authMiddleware.RequirePermission(models.PermPurchaseWrite), replenishmentHandler.CreateReplenishmentRun)
+			replenishment.POST("/runs/:id/purchase-orders", authMiddleware.RequirePermission(models.PermPurchaseWrite), replenishmentHandler.CreateReplenishmentOrders)
+		}
+
+		// Sales orders
+		salesOrder := protected.Group("/sales-orders")
+		{
+			salesOrder.GET("", read, salesOrderHandler.GetSalesOrders)
+			salesOrder.GET("/:id", read, salesOrderHandler.GetSalesOrderByID)
+			salesOrder.POST("", authMiddleware.RequirePermission(models.PermSalesWrite), idempotencyMiddleware.Handle(), salesOrderHandler.CreateSalesOrder)
+			salesOrder.POST("/:id/fulfill", authMiddleware.RequirePermission(models.PermSalesWrite), salesOrderHandler.FulfillSalesOrder)
+			salesOrder.POST("/:id/cancel", authMiddleware.RequirePermission(models.PermSalesWrite), salesOrderHandler.CancelSalesOrder)
+		}
+
+		// Customer returns (RMA)
+		returns := protected.Group("/returns")
+		{
+			returns.GET("", read, returnHandler.GetReturns)
+			returns.GET("/:id", read, returnHandler.GetReturnByID)
+			returns.POST("", authMiddleware.RequirePermission(models.PermReturnWrite), returnHandler.CreateReturn)
+			returns.POST("/:id/receive", authMiddleware.RequirePermission(models.PermReturnWrite), returnHandler.ReceiveReturn)
+			returns.POST("/:id/inspect", authMiddleware.RequirePermission(models.PermReturnWrite), returnHandler.InspectReturn)
+			returns.POST("/:id/disposition", authMiddleware.RequirePermission(models.PermReturnWrite), returnHandler.DispositionReturn)
+			returns.POST("/:id/close", authMiddleware.RequirePermission(models.PermReturnWrite), returnHandler.CloseReturn)
+			returns.POST("/:id/cancel", authMiddleware.RequirePermission(models.PermReturnWrite), returnHandler.CancelReturn)
+		}
+
+		// Stocktakes / cycle counts
+		stocktake := protected.Group("/stocktakes")
+		{
+			stocktake.GET("", read, stocktakeHandler.GetStocktakes)
+			stocktake.GET("/:id", read, stocktakeHandler.GetStocktakeByID)
+			stocktake.POST("", authMiddleware.RequirePermission(models.PermStocktakeWrite), stocktakeHandler.CreateStocktake)
+			stocktake.POST("/:id/counts", authMiddleware.RequirePermission(models.PermStocktakeWrite), stocktakeHandler.SubmitStocktakeCounts)
+			stocktake.POST("/:id/approve", authMiddleware.RequirePermission(models.PermStocktakeApprove), stocktakeHandler.ApproveStocktake)
+			stocktake.POST("/:id/cancel", authMiddleware.RequirePermission(models.PermStocktakeWrite), stocktakeHandler.CancelStocktake)
+		}
+
+		// ABC classification and daily cycle counts
+		cycleCount := protected.Group("/cycle-counts")
+		{
+			cycleCount.GET("/classes", read, cycleCountHandler.GetClasses)
+			cycleCount.POST("/classify", authMiddleware.RequirePermission(models.PermStocktakeApprove), cycleCountHandler.Classify)
+			cycleCount.POST("/generate", authMiddleware.RequirePermission(models.PermStocktakeApprove), cycleCountHandler.GenerateCycleCounts)
+		}
+
+		// Movement type and reason code catalogue, maintained by admins
+		movementType := protected.Group("/movement-types")
+		{
+			movementType.GET("", read, movementCatalogHandler.GetMovementTypes)
+			movementType.POST("", authMiddleware.RequirePermission(models.PermCatalogAdmin), movementCatalogHandler.CreateMovementType)
+			movementType.PUT("/:id", authMiddleware.RequirePermission(models.PermCatalogAdmin), movementCatalogHandler.UpdateMovementType)
+			movementType.DELETE("/:id", authMiddleware.RequirePermission(models.PermCatalogAdmin), movementCatalogHandler.DeleteMovementType)
+		}
+
+		reasonCode := protected.Group("/reason-codes")
+		{
+			reasonCode.GET("", read, movementCatalogHandler.GetReasonCodes)
+			reasonCode.POST("", authMiddleware.RequirePermission(models.PermCatalogAdmin), movementCatalogHandler.CreateReasonCode)
+			reasonCode.PUT("/:id", authMiddleware.RequirePermission(models.PermCatalogAdmin), movementCatalogHandler.UpdateReasonCode)
+			reasonCode.DELETE("/:id", authMiddleware.RequirePermission(models.PermCatalogAdmin), movementCatalogHandler.DeleteReasonCode)
+		}
+
+		// Stock threshold alerts and where they are delivered
+		alert := protected.Group("/alerts")
+		{
+			alert.GET("", read, alertHandler.GetAlerts)
+			alert.GET("/:id", read, alertHandler.GetAlertByID)
+		}
+		protected.POST("/alert-deliveries/:id/retry", authMiddleware.RequirePermission(models.PermAlertAdmin), alertHandler.RetryAlertDelivery)
+
+		alertChannel := protected.Group("/alert-channels", authMiddleware.RequirePermission(models.PermAlertAdmin))
+		{
+			alertChannel.GET("", alertHandler.GetAlertChannels)
+			alertChannel.POST("", alertHandler.CreateAlertChannel)
+			alertChannel.PUT("/:id", alertHandler.UpdateAlertChannel)
+			alertChannel.DELETE("/:id", alertHandler.DeleteAlertChannel)
+		}
+
+		// Domain event stream and its subscribers
+		protected.GET("/events", authMiddleware.RequirePermission(models.PermEventRead), eventHandler.GetEvents)
+
+		eventSubscription := protected.Group("/event-subscriptions", authMiddleware.RequirePermission(models.PermEventAdmin))
+		{
+			eventSubscription.GET("", eventHandler.GetEventSubscriptions)
+			eventSubscription.POST("", eventHandler.CreateEventSubscription)
+			eventSubscription.DELETE("/:id", eventHandler.DeleteEventSubscription)
+			eventSubscription.POST("/:id/replay", eventHandler.ReplayEvents)
+		}
+
+		// Serial number lookup
+		protected.GET("/serials/:serial", read, serialHandler.GetSerial)
+
+		// Reports
+		report := protected.Group("/reports", authMiddleware.RequirePermission(models.PermReportRead))
+		{
+			report.GET("/valuation", reportHandler.GetValuation)
+			report.GET("/shrinkage", reportHandler.GetShrinkage)
+			report.GET("/count-accuracy", reportHandler.GetCountAccuracy)
+		}
+	}
+
+	// Swagger
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	return router, startJobs
+}
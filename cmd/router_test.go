@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/config"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/testutil"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const testJWTSecret = "router-test-secret"
+
+// testServer is the full router over the database named by TEST_DATABASE_URL.
+// Redis is not needed: test tokens carry no jti or sid, and cache writes that
+// fail are ignored.
+type testServer struct {
+	t      *testing.T
+	db     *gorm.DB
+	router *gin.Engine
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	db := testutil.OpenDB(t)
+
+	redisClient := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { redisClient.Close() })
+
+	gin.SetMode(gin.TestMode)
+	router, _ := newRouter(&config.Config{JWTSecret: testJWTSecret, APIKeyMaxTTL: 24 * time.Hour}, db, redisClient)
+	return &testServer{t: t, db: db, router: router}
+}
+
+// createUser adds a user with the given role and returns a token for them
+func (s *testServer) createUser(role string) (models.User, string) {
+	s.t.Helper()
+	user := models.User{
+		Email:    testutil.UniqueName(role) + "@example.com",
+		Name:     "Test " + role,
+		Role:     role,
+		IsActive: true,
+	}
+	if err := s.db.Create(&user).Error; err != nil {
+		s.t.Fatalf("create user: %v", err)
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": user.ID,
+		"role":    user.Role,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		s.t.Fatalf("sign token: %v", err)
+	}
+	return user, token
+}
+
+func (s *testServer) createProduct(userID uint) models.Product {
+	s.t.Helper()
+	product := models.Product{
+		SKU:       testutil.UniqueName("SKU"),
+		Name:      "Test product",
+		UnitPrice: 10,
+		CostPrice: 5,
+		IsActive:  true,
+		CreatedBy: userID,
+		UpdatedBy: userID,
+	}
+	if err := s.db.Create(&product).Error; err != nil {
+		s.t.Fatalf("create product: %v", err)
+	}
+	return product
+}
+
+// createPending holds an IN movement for approval as if requester had submitted it
+func (s *testServer) createPending(productID, requesterID uint) models.Stock {
+	s.t.Helper()
+	var warehouse models.Warehouse
+	if err := s.db.Where("is_default = ?", true).First(&warehouse).Error; err != nil {
+		s.t.Fatalf("default warehouse: %v", err)
+	}
+	request, _ := json.Marshal(models.StockUpdateRequest{
+		ProductID: productID, WarehouseID: warehouse.ID, Type: "IN", Quantity: 1,
+	})
+	movement := models.Stock{
+		ProductID:      productID,
+		WarehouseID:    warehouse.ID,
+		Type:           "IN",
+		Quantity:       1,
+		Status:         models.StockStatusPending,
+		PendingRequest: request,
+		CreatedBy:      requesterID,
+		UpdatedBy:      requesterID,
+		CreatedAt:      time.Now(),
+	}
+	if err := s.db.Create(&movement).Error; err != nil {
+		s.t.Fatalf("create pending movement: %v", err)
+	}
+	return movement
+}
+
+func (s *testServer) do(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// TestAdminRoutesRequirePermission checks that the user, role and approval
+// routes and adjustments turn a plain user away and let an admin through
+func TestAdminRoutesRequirePermission(t *testing.T) {
+	s := newTestServer(t)
+	_, userToken := s.createUser(models.RoleUser)
+	admin, adminToken := s.createUser(models.RoleAdmin)
+	requester, _ := s.createUser(models.RoleUser)
+	product := s.createProduct(admin.ID)
+
+	adjust := map[string]interface{}{"product_id": product.ID, "type": "ADJUST", "quantity": 5, "reason_code": "COUNT_VARIANCE"}
+
+	tests := []struct {
+		name   string
+		method string
+		path   func() string
+		body   interface{}
+	}{
+		{"list users", http.MethodGet, func() string { return "/api/v1/users" }, nil},
+		{"list roles", http.MethodGet, func() string { return "/api/v1/roles" }, nil},
+		{"create role", http.MethodPost, func() string { return "/api/v1/roles" },
+			map[string]interface{}{"name": testutil.UniqueName("auditor"), "permissions": []string{models.PermReportRead}}},
+		{"change a user's role", http.MethodPut, func() string { return fmt.Sprintf("/api/v1/users/%d/role", requester.ID) },
+			map[string]string{"role": models.RoleUser}},
+		{"list pending", http.MethodGet, func() string { return "/api/v1/stocks/pending" }, nil},
+		{"approve", http.MethodPost, func() string {
+			return fmt.Sprintf("/api/v1/stocks/%d/approve", s.createPending(product.ID, requester.ID).ID)
+		}, nil},
+		{"reject", http.MethodPost, func() string {
+			return fmt.Sprintf("/api/v1/stocks/%d/reject", s.createPending(product.ID, requester.ID).ID)
+		}, nil},
+		{"adjust", http.MethodPost, func() string { return "/api/v1/stocks" }, adjust},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := s.do(tt.method, tt.path(), userToken, tt.body); w.Code != http.StatusForbidden {
+				t.Errorf("user: status %d, want 403: %s", w.Code, w.Body)
+			}
+			if w := s.do(tt.method, tt.path(), adminToken, tt.body); w.Code < 200 || w.Code > 299 {
+				t.Errorf("admin: status %d, want 2xx: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
+
+// TestReverseAdjustRequiresAdjustPermission checks that undoing an ADJUST
+// needs stock:adjust even though reversing needs only stock:write
+func TestReverseAdjustRequiresAdjustPermission(t *testing.T) {
+	s := newTestServer(t)
+	_, userToken := s.createUser(models.RoleUser)
+	admin, adminToken := s.createUser(models.RoleAdmin)
+	product := s.createProduct(admin.ID)
+
+	w := s.do(http.MethodPost, "/api/v1/stocks", adminToken,
+		map[string]interface{}{"product_id": product.ID, "type": "ADJUST", "quantity": 5, "reason_code": "COUNT_VARIANCE"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("adjust: status %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Movement models.Stock `json:"movement"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode adjust response: %v", err)
+	}
+
+	path := fmt.Sprintf("/api/v1/stocks/%d/reverse", resp.Movement.ID)
+	if w := s.do(http.MethodPost, path, userToken, nil); w.Code != http.StatusForbidden {
+		t.Errorf("user: status %d, want 403: %s", w.Code, w.Body)
+	}
+	if w := s.do(http.MethodPost, path, adminToken, nil); w.Code != http.StatusOK {
+		t.Errorf("admin: status %d, want 200: %s", w.Code, w.Body)
+	}
+}
+
+// TestAPIKeyReadsNeedScopes checks that an API key reads only what its scopes
+// allow, even when the service account's role could read everything
+func TestAPIKeyReadsNeedScopes(t *testing.T) {
+	s := newTestServer(t)
+	_, adminToken := s.createUser(models.RoleAdmin)
+
+	w := s.do(http.MethodPost, "/api/v1/service-accounts", adminToken,
+		map[string]string{"name": testutil.UniqueName("reader"), "role": models.RoleUser})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create service account: status %d: %s", w.Code, w.Body)
+	}
+	var account struct {
+		ID uint `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &account); err != nil {
+		t.Fatalf("decode service account: %v", err)
+	}
+
+	issue := func(scopes ...string) string {
+		t.Helper()
+		w := s.do(http.MethodPost, fmt.Sprintf("/api/v1/service-accounts/%d/api-keys", account.ID), adminToken,
+			map[string]interface{}{"name": "test", "scopes": scopes})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("issue key %v: status %d: %s", scopes, w.Code, w.Body)
+		}
+		var key struct {
+			Key string `json:"key"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &key); err != nil {
+			t.Fatalf("decode key: %v", err)
+		}
+		return key.Key
+	}
+	writer := issue(models.PermStockWrite)
+	reader := issue(models.PermInventoryRead, models.PermEventRead)
+
+	for _, path := range []string{"/api/v1/products", "/api/v1/stocks", "/api/v1/warehouses", "/api/v1/events"} {
+		if w := s.do(http.MethodGet, path, writer, nil); w.Code != http.StatusForbidden {
+			t.Errorf("GET %s with stock:write only: status %d, want 403: %s", path, w.Code, w.Body)
+		}
+		if w := s.do(http.MethodGet, path, reader, nil); w.Code != http.StatusOK {
+			t.Errorf("GET %s with read scopes: status %d, want 200: %s", path, w.Code, w.Body)
+		}
+	}
+}
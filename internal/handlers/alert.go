@@ -0,0 +1,613 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/notify"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	alertMaxAttempts   = 8                // deliveries are given up after this many failures
+	alertRetryBase     = 30 * time.Second // first retry delay, doubled on each failure
+	alertRetryMax      = time.Hour
+	alertDeliveryLease = 5 * time.Minute // how long a claimed delivery is hidden from other dispatchers
+	alertDeliveryBatch = 50
+)
+
+// AlertHandler delivers stock alerts and manages alert channels
+type AlertHandler struct {
+	db   *gorm.DB
+	smtp notify.SMTPConfig
+}
+
+func NewAlertHandler(db *gorm.DB, smtp notify.SMTPConfig) *AlertHandler {
+	return &AlertHandler{
+		db:   db,
+		smtp: smtp,
+	}
+}
+
+// AlertChannelRequest holds the fields for creating or updating an alert channel
+type AlertChannelRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Type   string   `json:"type" binding:"required,oneof=WEBHOOK EMAIL"`
+	Target string   `json:"target" binding:"required"` // webhook URL, or comma-separated email addresses
+	Secret string   `json:"secret"`                    // webhook signing key, required for webhooks and kept when left empty on update
+	Kinds  []string `json:"kinds" binding:"omitempty,dive,oneof=LOW_STOCK OUT_OF_STOCK OVERSTOCK"`
+}
+
+// AlertPayload is the body posted to webhooks
+type AlertPayload struct {
+	DeliveryID  uint      `json:"delivery_id"`
+	AlertID     uint      `json:"alert_id"`
+	Kind        string    `json:"kind"`
+	Status      string    `json:"status"`
+	ProductID   uint      `json:"product_id"`
+	SKU         string    `json:"sku"`
+	Name        string    `json:"name"`
+	Quantity    int       `json:"quantity"`
+	Threshold   int       `json:"threshold"`
+	MovementID  *uint     `json:"movement_id"`
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+// activeStockAlerts returns the thresholds a product's quantity is past
+func activeStockAlerts(product models.Product, quantity int) map[string]int {
+	active := map[string]int{}
+	if quantity <= 0 {
+		active[models.AlertKindOutOfStock] = 0
+	}
+	if product.MinQuantity > 0 && quantity <= product.MinQuantity {
+		active[models.AlertKindLowStock] = product.MinQuantity
+	}
+	if product.MaxQuantity > 0 && quantity > product.MaxQuantity {
+		active[models.AlertKindOverstock] = product.MaxQuantity
+	}
+	return active
+}
+
+// raiseStockAlerts compares a product's new quantity with its thresholds,
+// opening alerts for thresholds it is now past and resolving those it is back
+// within. New alerts are queued for delivery to every matching channel in the
+// same transaction as the movement that caused them. Crossing a threshold
+// within cooldown of its alert resolving re-opens the alert without notifying.
+func raiseStockAlerts(tx *gorm.DB, product models.Product, quantity int, movementID uint, cooldown time.Duration) error {
+	if !product.IsActive {
+		return nil
+	}
+	active := activeStockAlerts(product, quantity)
+	now := time.Now()
+
+	var open []models.Alert
+	if err := tx.Where("product_id = ? AND status = ?", product.ID, models.AlertStatusOpen).Find(&open).Error; err != nil {
+		return err
+	}
+	isOpen := map[string]bool{}
+	for i := range open {
+		alert := &open[i]
+		if _, ok := active[alert.Kind]; ok {
+			isOpen[alert.Kind] = true
+			continue
+		}
+		alert.Status = models.AlertStatusResolved
+		alert.Quantity = quantity
+		alert.ResolvedAt = &now
+		if err := tx.Save(alert).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, kind := range []string{models.AlertKindOutOfStock, models.AlertKindLowStock, models.AlertKindOverstock} {
+		threshold, ok := active[kind]
+		if !ok || isOpen[kind] {
+			continue
+		}
+
+		// Crossing again soon after it cleared re-opens the alert without notifying
+		var recent models.Alert
+		err := tx.Where("product_id = ? AND kind = ? AND status = ? AND resolved_at > ?",
+			product.ID, kind, models.AlertStatusResolved, now.Add(-cooldown)).
+			Order("resolved_at DESC").First(&recent).Error
+		if err == nil {
+			recent.Status = models.AlertStatusOpen
+			recent.Quantity = quantity
+			recent.Threshold = threshold
+			recent.MovementID = &movementID
+			recent.Occurrences++
+			recent.LastTriggeredAt = now
+			recent.ResolvedAt = nil
+			if err := tx.Save(&recent).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		alert := models.Alert{
+			ProductID:       product.ID,
+			Kind:            kind,
+			Status:          models.AlertStatusOpen,
+			Quantity:        quantity,
+			Threshold:       threshold,
+			MovementID:      &movementID,
+			Occurrences:     1,
+			LastTriggeredAt: now,
+		}
+		if err := tx.Create(&alert).Error; err != nil {
+			return err
+		}
+		if err := queueAlertDeliveries(tx, alert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queueAlertDeliveries creates a pending delivery for every active channel
+// that wants this kind of alert
+func queueAlertDeliveries(tx *gorm.DB, alert models.Alert) error {
+	var channels []models.AlertChannel
+	if err := tx.Where("is_active = ?", true).Find(&channels).Error; err != nil {
+		return err
+	}
+	for _, ch := range channels {
+		if ch.Kinds != "" && !containsKind(ch.Kinds, alert.Kind) {
+			continue
+		}
+		delivery := models.AlertDelivery{
+			AlertID:       alert.ID,
+			ChannelID:     ch.ID,
+			Status:        models.AlertDeliveryPending,
+			NextAttemptAt: alert.LastTriggeredAt,
+		}
+		if err := tx.Create(&delivery).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// containsKind reports whether a comma-separated kind list includes kind
+func containsKind(kinds, kind string) bool {
+	for _, k := range strings.Split(kinds, ",") {
+		if strings.TrimSpace(k) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// alertRetryDelay is the backoff before the next attempt after the given number of failures
+func alertRetryDelay(attempts int) time.Duration {
+	delay := alertRetryBase
+	for i := 1; i < attempts && delay < alertRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, alertRetryMax)
+}
+
+// DeliverAlerts sends pending alert deliveries every interval until ctx is
+// cancelled. Deliveries are claimed with SKIP LOCKED so several app instances
+// can run it side by side.
+func (h *AlertHandler) DeliverAlerts(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.deliverDueAlerts(ctx)
+		}
+	}
+}
+
+// deliverDueAlerts claims the deliveries that are due and sends them
+func (h *AlertHandler) deliverDueAlerts(ctx context.Context) {
+	var due []models.AlertDelivery
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.AlertDeliveryPending, time.Now()).
+			Order("next_attempt_at").Limit(alertDeliveryBatch).Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		ids := make([]uint, 0, len(due))
+		for _, d := range due {
+			ids = append(ids, d.ID)
+		}
+		// Hide them from other dispatchers while they are being sent
+		return tx.Model(&models.AlertDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(alertDeliveryLease)).Error
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to claim alert deliveries: %v", err)
+		return
+	}
+
+	for i := range due {
+		delivery := &due[i]
+		sendErr := h.sendAlertDelivery(ctx, delivery)
+
+		delivery.Attempts++
+		if sendErr == nil {
+			now := time.Now()
+			delivery.Status = models.AlertDeliverySent
+			delivery.DeliveredAt = &now
+			delivery.LastError = ""
+		} else {
+			delivery.LastError = sendErr.Error()
+			delivery.NextAttemptAt = time.Now().Add(alertRetryDelay(delivery.Attempts))
+			if delivery.Attempts >= alertMaxAttempts {
+				delivery.Status = models.AlertDeliveryFailed
+			}
+			log.Printf("Warning: Alert delivery %d attempt %d failed: %v", delivery.ID, delivery.Attempts, sendErr)
+		}
+		if err := h.db.Omit(clause.Associations).Save(delivery).Error; err != nil {
+			log.Printf("Warning: Failed to update alert delivery %d: %v", delivery.ID, err)
+		}
+	}
+}
+
+// sendAlertDelivery sends one delivery to its channel
+func (h *AlertHandler) sendAlertDelivery(ctx context.Context, delivery *models.AlertDelivery) error {
+	var channel models.AlertChannel
+	if err := h.db.First(&channel, delivery.ChannelID).Error; err != nil {
+		return err
+	}
+	if !channel.IsActive {
+		return fmt.Errorf("alert channel %d has been deleted", channel.ID)
+	}
+	var alert models.Alert
+	if err := h.db.Preload("Product").First(&alert, delivery.AlertID).Error; err != nil {
+		return err
+	}
+
+	payload := AlertPayload{
+		DeliveryID:  delivery.ID,
+		AlertID:     alert.ID,
+		Kind:        alert.Kind,
+		Status:      alert.Status,
+		ProductID:   alert.ProductID,
+		Quantity:    alert.Quantity,
+		Threshold:   alert.Threshold,
+		MovementID:  alert.MovementID,
+		TriggeredAt: alert.LastTriggeredAt,
+	}
+	if alert.Product != nil {
+		payload.SKU = alert.Product.SKU
+		payload.Name = alert.Product.Name
+	}
+
+	switch channel.Type {
+	case models.AlertChannelWebhook:
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		return notify.PostWebhook(ctx, channel.Target, channel.Secret, strconv.Itoa(int(delivery.ID)), body)
+	case models.AlertChannelEmail:
+		var to []string
+		for _, addr := range strings.Split(channel.Target, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				to = append(to, addr)
+			}
+		}
+		subject := fmt.Sprintf("[%s] %s %s", payload.Kind, payload.SKU, payload.Name)
+		body := fmt.Sprintf("Product: %s %s\nAlert: %s\nQuantity: %d\nThreshold: %d\nTriggered at: %s\n",
+			payload.SKU, payload.Name, payload.Kind, payload.Quantity, payload.Threshold,
+			payload.TriggeredAt.Format(time.RFC3339))
+		return notify.SendEmail(h.smtp, to, subject, body)
+	default:
+		return fmt.Errorf("unknown alert channel type %s", channel.Type)
+	}
+}
+
+// validateAlertChannel checks the target of a channel request. secret is the
+// channel's current signing key, empty for a new channel; every webhook must
+// end up with one so no delivery goes out unsigned.
+func validateAlertChannel(req AlertChannelRequest, secret string) error {
+	switch req.Type {
+	case models.AlertChannelWebhook:
+		u, err := url.Parse(req.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return validationError("Webhook target must be an http or https URL")
+		}
+		if req.Secret == "" && secret == "" {
+			return validationError("Webhook channels need a secret to sign deliveries with")
+		}
+	case models.AlertChannelEmail:
+		for _, addr := range strings.Split(req.Target, ",") {
+			if !strings.Contains(strings.TrimSpace(addr), "@") {
+				return validationError(fmt.Sprintf("Invalid email address %s", strings.TrimSpace(addr)))
+			}
+		}
+	}
+	return nil
+}
+
+// GetAlerts godoc
+// @Summary Get stock alerts
+// @Description List stock alerts, newest first
+// @Tags alerts
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Param status query string false "OPEN or RESOLVED"
+// @Param kind query string false "LOW_STOCK, OUT_OF_STOCK or OVERSTOCK"
+// @Param product_id query int false "Product filter"
+// @Success 200 {object} map[string]interface{}
+// @Router /alerts [get]
+func (h *AlertHandler) GetAlerts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	query := h.db.Model(&models.Alert{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+
+	var total int64
+	var alerts []models.Alert
+	query.Count(&total)
+	query.Preload("Product").Offset(offset).Limit(limit).Order("last_triggered_at DESC").Find(&alerts)
+
+	c.JSON(http.StatusOK, gin.H{
+		"alerts": alerts,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+// GetAlertByID godoc
+// @Summary Get a stock alert
+// @Description Get a stock alert with the state of its deliveries
+// @Tags alerts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Alert ID"
+// @Success 200 {object} models.Alert
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /alerts/{id} [get]
+func (h *AlertHandler) GetAlertByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+		return
+	}
+
+	var alert models.Alert
+	if err := h.db.Preload("Product").Preload("Deliveries.Channel").First(&alert, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+// RetryAlertDelivery godoc
+// @Summary Retry a failed alert delivery
+// @Description Queue a delivery that ran out of attempts to be sent again
+// @Tags alerts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Alert delivery ID"
+// @Success 200 {object} MessageResponse "Alert delivery queued"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /alert-deliveries/{id}/retry [post]
+func (h *AlertHandler) RetryAlertDelivery(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert delivery ID"})
+		return
+	}
+
+	var delivery models.AlertDelivery
+	if err := h.db.First(&delivery, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert delivery not found"})
+		return
+	}
+	if delivery.Status != models.AlertDeliveryFailed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only failed deliveries can be retried"})
+		return
+	}
+
+	if err := h.db.Model(&delivery).Updates(map[string]interface{}{
+		"status":          models.AlertDeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue alert delivery"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert delivery queued"})
+}
+
+// GetAlertChannels godoc
+// @Summary Get alert channels
+// @Description List the webhooks and email recipients alerts are delivered to
+// @Tags alerts
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.AlertChannel
+// @Failure 403 {object} ErrorResponse
+// @Router /alert-channels [get]
+func (h *AlertHandler) GetAlertChannels(c *gin.Context) {
+	var channels []models.AlertChannel
+	h.db.Where("is_active = ?", true).Order("name").Find(&channels)
+
+	c.JSON(http.StatusOK, channels)
+}
+
+// CreateAlertChannel godoc
+// @Summary Create an alert channel
+// @Description Add a webhook (signed with HMAC-SHA256 under its required secret) or email recipients for stock alerts (requires alert:admin).
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param channel body AlertChannelRequest true "Channel details"
+// @Success 201 {object} models.AlertChannel
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /alert-channels [post]
+func (h *AlertHandler) CreateAlertChannel(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req AlertChannelRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateAlertChannel(req, ""); err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	channel := models.AlertChannel{
+		Name:      req.Name,
+		Type:      req.Type,
+		Target:    req.Target,
+		Secret:    req.Secret,
+		Kinds:     strings.Join(req.Kinds, ","),
+		IsActive:  true,
+		CreatedBy: userID.(uint),
+		UpdatedBy: userID.(uint),
+	}
+	if err := h.db.Create(&channel).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert channel"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, channel)
+}
+
+// UpdateAlertChannel godoc
+// @Summary Update an alert channel
+// @Description Update an alert channel. An empty secret keeps the current one (requires alert:admin).
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Alert channel ID"
+// @Param channel body AlertChannelRequest true "Channel details"
+// @Success 200 {object} MessageResponse "Alert channel updated successfully"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /alert-channels/{id} [put]
+func (h *AlertHandler) UpdateAlertChannel(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert channel ID"})
+		return
+	}
+
+	var req AlertChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var channel models.AlertChannel
+	if err := h.db.Where("id = ? AND is_active = ?", id, true).First(&channel).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert channel not found"})
+		return
+	}
+	if err := validateAlertChannel(req, channel.Secret); err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	channel.Name = req.Name
+	channel.Type = req.Type
+	channel.Target = req.Target
+	if req.Secret != "" {
+		channel.Secret = req.Secret
+	}
+	channel.Kinds = strings.Join(req.Kinds, ",")
+	channel.UpdatedBy = userID.(uint)
+
+	if err := h.db.Save(&channel).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert channel"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert channel updated successfully"})
+}
+
+// DeleteAlertChannel godoc
+// @Summary Delete an alert channel
+// @Description Stop delivering alerts to a channel (requires alert:admin).
+// @Tags alerts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Alert channel ID"
+// @Success 200 {object} MessageResponse "Alert channel deleted successfully"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /alert-channels/{id} [delete]
+func (h *AlertHandler) DeleteAlertChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert channel ID"})
+		return
+	}
+
+	var channel models.AlertChannel
+	if err := h.db.Where("id = ? AND is_active = ?", id, true).First(&channel).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert channel not found"})
+		return
+	}
+
+	// Soft delete; pending deliveries to it are dropped
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&channel).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.AlertDelivery{}).
+			Where("channel_id = ? AND status = ?", channel.ID, models.AlertDeliveryPending).
+			Updates(map[string]interface{}{"status": models.AlertDeliveryFailed, "last_error": "Channel deleted"}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert channel"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert channel deleted successfully"})
+}
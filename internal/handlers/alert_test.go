@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+)
+
+func TestActiveStockAlerts(t *testing.T) {
+	product := models.Product{MinQuantity: 10, MaxQuantity: 100}
+	tests := []struct {
+		name     string
+		product  models.Product
+		quantity int
+		want     map[string]int
+	}{
+		{"within thresholds", product, 50, map[string]int{}},
+		{"at the minimum", product, 10, map[string]int{models.AlertKindLowStock: 10}},
+		{"at the maximum", product, 100, map[string]int{}},
+		{"above the maximum", product, 101, map[string]int{models.AlertKindOverstock: 100}},
+		{"out of stock is also low", product, 0, map[string]int{models.AlertKindOutOfStock: 0, models.AlertKindLowStock: 10}},
+		{"negative", product, -3, map[string]int{models.AlertKindOutOfStock: 0, models.AlertKindLowStock: 10}},
+		{"no thresholds set", models.Product{}, 5, map[string]int{}},
+		{"no thresholds, out of stock", models.Product{}, 0, map[string]int{models.AlertKindOutOfStock: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := activeStockAlerts(tt.product, tt.quantity); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("activeStockAlerts(%d) = %v, want %v", tt.quantity, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour}, // 64 minutes, capped
+		{50, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := alertRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("alertRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
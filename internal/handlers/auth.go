@@ -2,29 +2,35 @@ package handlers
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/impk123/Inventory-Management-Mini-System/internal/config"
 	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AuthHandler struct {
 	db          *gorm.DB
+	cache       *redis.Client
 	config      *config.Config
 	oauthConfig *oauth2.Config
+	providers   map[string]*oidcProvider // OpenID Connect providers by name
 }
 
-func NewAuthHandler(db *gorm.DB, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(db *gorm.DB, cache *redis.Client, cfg *config.Config) *AuthHandler {
 	oauthConfig := &oauth2.Config{
 		ClientID:     cfg.GoogleClientID,
 		ClientSecret: cfg.GoogleClientSecret,
@@ -33,13 +39,19 @@ func NewAuthHandler(db *gorm.DB, cfg *config.Config) *AuthHandler {
 			"https://www.googleapis.com/auth/userinfo.email",
 			"https://www.googleapis.com/auth/userinfo.profile",
 		},
-		Endpoint: google.Endpoint,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   cfg.GoogleAuthURL,
+			TokenURL:  cfg.GoogleTokenURL,
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
 	}
 
 	return &AuthHandler{
 		db:          db,
+		cache:       cache,
 		config:      cfg,
 		oauthConfig: oauthConfig,
+		providers:   newOIDCProviders(cfg),
 	}
 }
 
@@ -58,9 +70,11 @@ type LoginRequest struct {
 
 // AuthResponse
 type AuthResponse struct {
-	Message string        `json:"message"`
-	Token   string        `json:"token,omitempty"`
-	User    *UserResponse `json:"user,omitempty"`
+	Message      string        `json:"message"`
+	Token        string        `json:"token,omitempty"`
+	RefreshToken string        `json:"refresh_token,omitempty"`
+	ExpiresIn    int           `json:"expires_in,omitempty"` // seconds until Token expires
+	User         *UserResponse `json:"user,omitempty"`
 }
 
 // UserResponse
@@ -124,18 +138,14 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// Generate token
-	token := h.generateToken(user)
-
-	c.JSON(http.StatusCreated, AuthResponse{
-		Message: "User registered successfully",
-		Token:   token,
-		User: &UserResponse{
-			ID:    user.ID,
-			Email: user.Email,
-			Name:  user.Name,
-			Role:  user.Role,
-		},
-	})
+	resp, err := h.startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to generate token"})
+		return
+	}
+
+	resp.Message = "User registered successfully"
+	c.JSON(http.StatusCreated, resp)
 }
 
 // Login godoc
@@ -174,66 +184,215 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	h.db.Save(&user)
 
 	// Generate token
-	token := h.generateToken(user)
-
-	c.JSON(http.StatusOK, AuthResponse{
-		Message: "Login successful",
-		Token:   token,
-		User: &UserResponse{
-			ID:    user.ID,
-			Email: user.Email,
-			Name:  user.Name,
-			Role:  user.Role,
-		},
-	})
+	resp, err := h.startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to generate token"})
+		return
+	}
+
+	resp.Message = "Login successful"
+	c.JSON(http.StatusOK, resp)
+}
+
+// googleUserInfo is the part of Google's userinfo response we use
+type googleUserInfo struct {
+	ID            string `json:"id"`
+	Email         string `json:"email"`
+	VerifiedEmail bool   `json:"verified_email"`
+	Name          string `json:"name"`
+}
+
+const (
+	oauthStateCookie    = "oauth_state"
+	oauthVerifierCookie = "oauth_verifier"
+	oauthCookieMaxAge   = 10 * 60 // seconds the user has to finish signing in
+)
+
+// setOAuthCookie stores a value for the callback. The cookies are HttpOnly,
+// limited to the auth routes and sent on the top-level redirect back (Lax).
+func setOAuthCookie(c *gin.Context, name, value string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, value, maxAge, "/api/v1/auth", "", secure, true)
 }
 
 // GoogleLogin godoc
 // @Summary Login with Google
-// @Description Redirect to Google OAuth2 login
+// @Description Redirect to Google OAuth2 login. A random state and a PKCE verifier are kept in short-lived cookies for the callback.
 // @Tags auth
-// @Success 302
+// @Success 307
+// @Failure 503 {object} ErrorResponse
 // @Router /auth/google [get]
 func (h *AuthHandler) GoogleLogin(c *gin.Context) {
-	url := h.oauthConfig.AuthCodeURL("state", oauth2.AccessTypeOffline)
+	if h.oauthConfig.ClientID == "" {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{Error: "Google login is not configured"})
+		return
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to start Google login"})
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	setOAuthCookie(c, oauthStateCookie, state, oauthCookieMaxAge)
+	setOAuthCookie(c, oauthVerifierCookie, verifier, oauthCookieMaxAge)
+
+	url := h.oauthConfig.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
 	c.Redirect(http.StatusTemporaryRedirect, url)
 }
 
 // GoogleCallback godoc
 // @Summary Google OAuth2 callback
-// @Description Handle Google OAuth2 callback
+// @Description Handle Google OAuth2 callback: check the state cookie, exchange the code with the PKCE verifier, then sign in the user with that Google account, link it to the plain user with the same email, or create a new user. Google accounts without a verified email are refused, and accounts with any other role are never linked by email
 // @Tags auth
 // @Param code query string true "Authorization code"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]interface{}
+// @Param state query string true "State from the login redirect"
+// @Success 200 {object} AuthResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
 // @Router /auth/google/callback [get]
 func (h *AuthHandler) GoogleCallback(c *gin.Context) {
+	state, _ := c.Cookie(oauthStateCookie)
+	verifier, _ := c.Cookie(oauthVerifierCookie)
+	// The cookies are single use
+	setOAuthCookie(c, oauthStateCookie, "", -1)
+	setOAuthCookie(c, oauthVerifierCookie, "", -1)
+
+	if errCode := c.Query("error"); errCode != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Google login failed: " + errCode})
+		return
+	}
+	if state == "" || verifier == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid OAuth state"})
+		return
+	}
+
 	code := c.Query("code")
 	if code == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code required"})
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Authorization code required"})
 		return
 	}
 
-	token, err := h.oauthConfig.Exchange(context.Background(), code)
+	ctx := c.Request.Context()
+	token, err := h.oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(verifier))
 	if err != nil {
 		log.Printf("Token exchange error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to exchange token"})
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Failed to exchange token"})
 		return
 	}
 
 	// Get user info from Google
-	client := h.oauthConfig.Client(context.Background(), token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	info, err := h.fetchGoogleUserInfo(ctx, token)
+	if err != nil {
+		log.Printf("Google userinfo error: %v", err)
+		c.JSON(http.StatusBadGateway, ErrorResponse{Error: "Failed to get user info"})
+		return
+	}
+	if info.ID == "" || info.Email == "" {
+		c.JSON(http.StatusBadGateway, ErrorResponse{Error: "Google did not return an account ID and email"})
+		return
+	}
+
+	user, err := h.findOrCreateGoogleUser(info)
+	if err != nil {
+		switch {
+		case errors.As(err, new(forbiddenError)):
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: err.Error()})
+		case errors.As(err, new(conflictError)):
+			c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to sign in with Google"})
+		}
+		return
+	}
+
+	resp, err := h.startSession(c, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user info"})
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to generate token"})
 		return
 	}
+
+	resp.Message = "Google login successful"
+	c.JSON(http.StatusOK, resp)
+}
+
+// fetchGoogleUserInfo reads the signed-in account from the userinfo endpoint
+func (h *AuthHandler) fetchGoogleUserInfo(ctx context.Context, token *oauth2.Token) (googleUserInfo, error) {
+	var info googleUserInfo
+	resp, err := h.oauthConfig.Client(ctx, token).Get(h.config.GoogleUserInfoURL)
+	if err != nil {
+		return info, err
+	}
 	defer resp.Body.Close()
 
-	// Parse user info and create/update user
-	// (Implementation depends on Google API response structure)
+	if resp.StatusCode != http.StatusOK {
+		return info, fmt.Errorf("userinfo returned %s", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	return info, err
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Google login successful", "token": "jwt-token-here"})
+// checkEmailLink refuses to link an external account to user by email alone.
+// Whoever controls the email at the provider would get the account's
+// permissions, so only plain user accounts are linked automatically.
+func checkEmailLink(user models.User) error {
+	if user.IsService || user.Role != models.RoleUser {
+		return forbiddenError("An account with this email already exists, sign in with your password instead")
+	}
+	return nil
+}
+
+// findOrCreateGoogleUser returns the user signed in with a Google account.
+// Google must have verified the email. An account seen for the first time is
+// linked to the plain user with the same email, or a new user is created.
+func (h *AuthHandler) findOrCreateGoogleUser(info googleUserInfo) (models.User, error) {
+	// Without a verified email the account could belong to anyone
+	if !info.VerifiedEmail {
+		return models.User{}, forbiddenError("Google email is not verified")
+	}
+
+	var user models.User
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("google_id = ?", info.ID).First(&user).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("email = ?", info.Email).First(&user).Error
+			switch {
+			case err == nil:
+				if err := checkEmailLink(user); err != nil {
+					return err
+				}
+				if user.GoogleID != nil {
+					return conflictError("Email is already linked to another Google account")
+				}
+				user.GoogleID = &info.ID
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				user = models.User{
+					Email:    info.Email,
+					GoogleID: &info.ID,
+					Name:     info.Name,
+					Role:     models.RoleUser,
+					IsActive: true,
+				}
+			default:
+				return err
+			}
+		}
+
+		if !user.IsActive {
+			return forbiddenError("Account is disabled")
+		}
+		user.LastLoginAt = time.Now()
+		return tx.Save(&user).Error
+	})
+	return user, err
 }
 
 func (h *AuthHandler) GetProfile(c *gin.Context) {
@@ -259,15 +418,90 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 	})
 }
 
-func (h *AuthHandler) generateToken(user models.User) string {
-	claims := jwt.MapClaims{
-		"user_id": user.ID,
-		"email":   user.Email,
-		"role":    user.Role,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+// UpdateUserRoleRequest holds the new role for a user
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required,max=32"`
+}
+
+// keepLastAdmin refuses to move user to role when they are the last active
+// admin, so roles can still be managed
+func keepLastAdmin(tx *gorm.DB, user models.User, role string) error {
+	if user.Role != models.RoleAdmin || role == models.RoleAdmin {
+		return nil
+	}
+	var admins []models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("role = ? AND is_active = ? AND is_service = ? AND id <> ?", models.RoleAdmin, true, false, user.ID).
+		Find(&admins).Error; err != nil {
+		return err
+	}
+	if len(admins) == 0 {
+		return conflictError("Cannot remove the last admin")
+	}
+	return nil
+}
+
+// UpdateUserRole godoc
+// @Summary Change a user's role
+// @Description Assign one of the roles from GET /roles to a user (requires user:admin). The last admin cannot be demoted.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body UpdateUserRoleRequest true "New role"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users/{id}/role [put]
+func (h *AuthHandler) UpdateUserRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, _ := token.SignedString([]byte(h.config.JWTSecret))
-	return signedToken
+	var req UpdateUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return notFoundError("User not found")
+			}
+			return err
+		}
+
+		var roles int64
+		if err := tx.Model(&models.Role{}).Where("name = ?", req.Role).Count(&roles).Error; err != nil {
+			return err
+		}
+		if roles == 0 {
+			return validationError("Role not found")
+		}
+
+		if err := keepLastAdmin(tx, user, req.Role); err != nil {
+			return err
+		}
+
+		user.Role = req.Role
+		return tx.Model(&user).Update("role", req.Role).Error
+	})
+	if err != nil {
+		respondAccessError(c, err, "Failed to update user role")
+		return
+	}
+
+	c.JSON(http.StatusOK, UserResponse{
+		ID:    user.ID,
+		Email: user.Email,
+		Name:  user.Name,
+		Role:  user.Role,
+	})
 }
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/config"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+)
+
+// fakeGoogle serves the token and userinfo endpoints of Google OAuth2,
+// answering every code with the account in info
+func fakeGoogle(t *testing.T, info *googleUserInfo) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "test-code" || r.FormValue("code_verifier") != "test-verifier" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-access-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestGoogleCallback signs in through a fake Google and checks that only
+// accounts with a verified email get a user
+func TestGoogleCallback(t *testing.T) {
+	db := openTestDB(t)
+	info := &googleUserInfo{}
+	google := fakeGoogle(t, info)
+
+	h := NewAuthHandler(db, nil, &config.Config{
+		JWTSecret:         "google-test-secret",
+		GoogleAuthURL:     google.URL + "/auth",
+		GoogleTokenURL:    google.URL + "/token",
+		GoogleUserInfoURL: google.URL + "/userinfo",
+	})
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/auth/google/callback", h.GoogleCallback)
+
+	callback := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/google/callback?code=test-code&state=test-state", nil)
+		req.AddCookie(&http.Cookie{Name: oauthStateCookie, Value: "test-state"})
+		req.AddCookie(&http.Cookie{Name: oauthVerifierCookie, Value: "test-verifier"})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("verified email creates a user", func(t *testing.T) {
+		*info = googleUserInfo{ID: uniqueName("google"), Email: uniqueName("google") + "@example.com", VerifiedEmail: true, Name: "Google User"}
+		w := callback()
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+		}
+		var resp AuthResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.Token == "" || resp.User == nil || resp.User.Email != info.Email || resp.User.Role != models.RoleUser {
+			t.Errorf("unexpected response: %s", w.Body)
+		}
+
+		// Signing in again finds the same user by Google ID
+		if w := callback(); w.Code != http.StatusOK {
+			t.Fatalf("second login: status %d, want 200: %s", w.Code, w.Body)
+		}
+		var count int64
+		db.Model(&models.User{}).Where("google_id = ?", info.ID).Count(&count)
+		if count != 1 {
+			t.Errorf("%d users with the Google ID, want 1", count)
+		}
+	})
+
+	t.Run("unverified email is refused", func(t *testing.T) {
+		*info = googleUserInfo{ID: uniqueName("google"), Email: uniqueName("google") + "@example.com", Name: "Unverified"}
+		if w := callback(); w.Code != http.StatusForbidden {
+			t.Fatalf("status %d, want 403: %s", w.Code, w.Body)
+		}
+		var count int64
+		db.Model(&models.User{}).Where("email = ?", info.Email).Count(&count)
+		if count != 0 {
+			t.Errorf("%d users created for an unverified email, want 0", count)
+		}
+	})
+
+	t.Run("unverified email does not link", func(t *testing.T) {
+		existing := createTestUser(t, db, models.RoleUser)
+		*info = googleUserInfo{ID: uniqueName("google"), Email: existing.Email, Name: "Unverified"}
+		if w := callback(); w.Code != http.StatusForbidden {
+			t.Fatalf("status %d, want 403: %s", w.Code, w.Body)
+		}
+		var user models.User
+		if err := db.First(&user, existing.ID).Error; err != nil {
+			t.Fatalf("load user: %v", err)
+		}
+		if user.GoogleID != nil {
+			t.Errorf("unverified Google account linked to %s", user.Email)
+		}
+	})
+
+	t.Run("does not link a privileged account", func(t *testing.T) {
+		for _, role := range []string{models.RoleAdmin, models.RoleManager} {
+			existing := createTestUser(t, db, role)
+			*info = googleUserInfo{ID: uniqueName("google"), Email: existing.Email, VerifiedEmail: true, Name: "Takeover"}
+			if w := callback(); w.Code != http.StatusForbidden {
+				t.Errorf("%s: status %d, want 403: %s", role, w.Code, w.Body)
+			}
+			var user models.User
+			if err := db.First(&user, existing.ID).Error; err != nil {
+				t.Fatalf("load user: %v", err)
+			}
+			if user.GoogleID != nil {
+				t.Errorf("%s: Google account linked to %s", role, user.Email)
+			}
+		}
+	})
+
+	t.Run("bad state is rejected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/google/callback?code=test-code&state=other", nil)
+		req.AddCookie(&http.Cookie{Name: oauthStateCookie, Value: "test-state"})
+		req.AddCookie(&http.Cookie{Name: oauthVerifierCookie, Value: "test-verifier"})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status %d, want 400: %s", w.Code, w.Body)
+		}
+	})
+}
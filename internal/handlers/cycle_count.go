@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+)
+
+// cycleCountIntervalDays is how often each ABC class is counted
+var cycleCountIntervalDays = map[string]int{
+	"A": 30,  // monthly
+	"B": 90,  // quarterly
+	"C": 365, // yearly
+}
+
+const (
+	abcClassAShare = 0.80 // share of annual usage value covered by A items
+	abcClassBShare = 0.95 // ... by A and B items together
+)
+
+// CycleCountHandler classifies products by ABC class and generates the daily
+// cycle count task lists
+type CycleCountHandler struct {
+	db           *gorm.DB
+	lookbackDays int
+}
+
+func NewCycleCountHandler(db *gorm.DB, lookbackDays int) *CycleCountHandler {
+	if lookbackDays <= 0 {
+		lookbackDays = 365
+	}
+	return &CycleCountHandler{
+		db:           db,
+		lookbackDays: lookbackDays,
+	}
+}
+
+// ProductUsage is a product's issue value over the lookback period and the
+// class it falls into
+type ProductUsage struct {
+	ProductID  uint    `json:"product_id"`
+	SKU        string  `json:"sku"`
+	Name       string  `json:"name"`
+	Quantity   int     `json:"quantity"` // units issued by OUT, net of reversals
+	UsageValue float64 `json:"usage_value"`
+	Class      string  `json:"abc_class"`
+}
+
+// CycleCountClassesResponse is the result of an ABC classification
+type CycleCountClassesResponse struct {
+	Since    string            `json:"since"`
+	Products []ProductUsage    `json:"products"`
+	Counts   map[string]int    `json:"counts"`
+	Excluded []ExcludedProduct `json:"excluded"` // classified, but never put on a cycle count
+}
+
+// ExcludedProduct is an active product that cycle counts leave out
+type ExcludedProduct struct {
+	ProductID uint   `json:"product_id"`
+	SKU       string `json:"sku"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+}
+
+// excludedFromCycleCounts lists the active products generateCycleCount never
+// selects. Stocktakes count quantities, so serial-tracked products, which are
+// accounted for by serial number, are left out.
+func excludedFromCycleCounts(tx *gorm.DB) ([]ExcludedProduct, error) {
+	excluded := []ExcludedProduct{}
+	if err := tx.Model(&models.Product{}).Select("id AS product_id, sku, name").
+		Where("is_active = ? AND tracking_mode = ?", true, models.TrackingModeSerial).
+		Order("sku").Scan(&excluded).Error; err != nil {
+		return nil, err
+	}
+	for i := range excluded {
+		excluded[i].Reason = "Serial-tracked products are not counted in stocktakes"
+	}
+	return excluded, nil
+}
+
+// rankProducts ranks active products by OUT quantity times unit price over
+// the lookback period. Products making up the first 80% of the usage value
+// are A, the next 15% B and the rest C.
+func rankProducts(tx *gorm.DB, since time.Time) ([]ProductUsage, error) {
+	var usage []ProductUsage
+	if err := tx.Table("products").
+		Select(`products.id AS product_id, products.sku, products.name,
+			COALESCE(SUM(CASE WHEN stocks.type = 'OUT' THEN stocks.quantity
+			                  WHEN reversed.type = 'OUT' THEN -stocks.quantity
+			                  ELSE 0 END), 0) AS quantity,
+			COALESCE(SUM(CASE WHEN stocks.type = 'OUT' THEN stocks.quantity
+			                  WHEN reversed.type = 'OUT' THEN -stocks.quantity
+			                  ELSE 0 END), 0) * products.unit_price AS usage_value`).
+		Joins("LEFT JOIN stocks ON stocks.product_id = products.id AND stocks.created_at >= ? AND stocks.status = ?",
+			since, models.StockStatusPosted).
+		Joins("LEFT JOIN stocks AS reversed ON reversed.id = stocks.reversal_of").
+		Where("products.is_active = ?", true).
+		Group("products.id, products.sku, products.name, products.unit_price").
+		Order("usage_value DESC, products.id").
+		Scan(&usage).Error; err != nil {
+		return nil, err
+	}
+
+	total := 0.0
+	for _, u := range usage {
+		total += max(u.UsageValue, 0)
+	}
+
+	cumulative := 0.0
+	for i := range usage {
+		switch {
+		case total == 0 || usage[i].UsageValue <= 0:
+			usage[i].Class = "C"
+		case cumulative/total < abcClassAShare:
+			usage[i].Class = "A"
+		case cumulative/total < abcClassBShare:
+			usage[i].Class = "B"
+		default:
+			usage[i].Class = "C"
+		}
+		cumulative += max(usage[i].UsageValue, 0)
+	}
+	return usage, nil
+}
+
+// classifyProducts ranks the products and stores their ABC class, recording
+// ProductUpdated for every product whose class changed. It must run inside a
+// transaction.
+func classifyProducts(tx *gorm.DB, since time.Time, userID uint) ([]ProductUsage, error) {
+	usage, err := rankProducts(tx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	byClass := map[string][]uint{}
+	for _, u := range usage {
+		byClass[u.Class] = append(byClass[u.Class], u.ProductID)
+	}
+	for _, class := range []string{"A", "B", "C"} {
+		if len(byClass[class]) == 0 {
+			continue
+		}
+		var changed []models.Product
+		if err := tx.Where("id IN ? AND abc_class IS DISTINCT FROM ?", byClass[class], class).Order("id").Find(&changed).Error; err != nil {
+			return nil, err
+		}
+		if len(changed) == 0 {
+			continue
+		}
+
+		ids := make([]uint, len(changed))
+		for i := range changed {
+			ids[i] = changed[i].ID
+		}
+		if err := tx.Model(&models.Product{}).Where("id IN ?", ids).Update("abc_class", class).Error; err != nil {
+			return nil, err
+		}
+		for i := range changed {
+			changed[i].ABCClass = class
+			if err := recordEvent(tx, models.EventProductUpdated, "product", changed[i].ID, changed[i], userID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return usage, nil
+}
+
+// generateCycleCount opens the cycle count for a warehouse and day. Each class
+// gets a daily quota of its product count divided by its interval, filled with
+// the products that are due, longest since their last count first, so the
+// whole class is counted once per interval at an even daily workload.
+// Serial-tracked products are never selected, see excludedFromCycleCounts.
+// It returns nil when the day already has a cycle count or nothing is due.
+func generateCycleCount(tx *gorm.DB, warehouse models.Warehouse, day time.Time, userID uint) (*models.Stocktake, error) {
+	var existing int64
+	tx.Model(&models.Stocktake{}).Where("warehouse_id = ? AND cycle_date = ?", warehouse.ID, day).Count(&existing)
+	if existing > 0 {
+		return nil, nil
+	}
+
+	// Products held at the warehouse that are not already being counted there
+	var products []models.Product
+	if err := tx.Where("is_active = ? AND tracking_mode <> ?", true, models.TrackingModeSerial).
+		Where("id IN (?)", tx.Model(&models.StockBalance{}).Select("product_id").Where("warehouse_id = ?", warehouse.ID)).
+		Where("id NOT IN (?)", tx.Model(&models.StocktakeLine{}).Select("stocktake_lines.product_id").
+			Joins("JOIN stocktakes ON stocktakes.id = stocktake_lines.stocktake_id").
+			Where("stocktakes.warehouse_id = ? AND stocktakes.status = ?", warehouse.ID, models.StocktakeStatusOpen)).
+		Order("id").Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	type lastCount struct {
+		ProductID uint
+		CountedAt time.Time
+	}
+	var counts []lastCount
+	if err := tx.Model(&models.StocktakeLine{}).
+		Select("stocktake_lines.product_id, MAX(stocktakes.approved_at) AS counted_at").
+		Joins("JOIN stocktakes ON stocktakes.id = stocktake_lines.stocktake_id").
+		Where("stocktakes.warehouse_id = ? AND stocktakes.status = ?", warehouse.ID, models.StocktakeStatusPosted).
+		Group("stocktake_lines.product_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	lastCounted := make(map[uint]time.Time, len(counts))
+	for _, c := range counts {
+		lastCounted[c.ProductID] = c.CountedAt
+	}
+
+	byClass := map[string][]models.Product{}
+	for _, p := range products {
+		class := p.ABCClass
+		if _, ok := cycleCountIntervalDays[class]; !ok {
+			class = "C"
+		}
+		byClass[class] = append(byClass[class], p)
+	}
+
+	var selected []models.Product
+	for class, members := range byClass {
+		interval := cycleCountIntervalDays[class]
+		quota := (len(members) + interval - 1) / interval
+
+		var due []models.Product
+		for _, p := range members {
+			counted, ok := lastCounted[p.ID]
+			if !ok || !counted.AddDate(0, 0, interval).After(day) {
+				due = append(due, p)
+			}
+		}
+		// Never counted first, then the longest since the last count
+		sort.SliceStable(due, func(i, j int) bool {
+			return lastCounted[due[i].ID].Before(lastCounted[due[j].ID])
+		})
+		selected = append(selected, due[:min(quota, len(due))]...)
+	}
+	if len(selected) == 0 {
+		return nil, nil
+	}
+
+	stocktake, err := openStocktake(tx, warehouse.ID, selected, 1,
+		fmt.Sprintf("Cycle count %s", day.Format("2006-01-02")), userID)
+	if err != nil {
+		return nil, err
+	}
+	stocktake.CycleDate = &day
+	if err := tx.Model(stocktake).Update("cycle_date", day).Error; err != nil {
+		return nil, err
+	}
+	return stocktake, nil
+}
+
+// generateCycleCounts classifies products and opens the day's cycle count at
+// every active warehouse
+func (h *CycleCountHandler) generateCycleCounts(day time.Time, userID uint) ([]models.Stocktake, error) {
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		_, err := classifyProducts(tx, day.AddDate(0, 0, -h.lookbackDays), userID)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	var warehouses []models.Warehouse
+	if err := h.db.Where("is_active = ?", true).Order("id").Find(&warehouses).Error; err != nil {
+		return nil, err
+	}
+
+	stocktakes := []models.Stocktake{}
+	for _, w := range warehouses {
+		var stocktake *models.Stocktake
+		err := h.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			stocktake, err = generateCycleCount(tx, w, day, userID)
+			return err
+		})
+		if err != nil {
+			return stocktakes, fmt.Errorf("warehouse %s: %w", w.Code, err)
+		}
+		if stocktake != nil {
+			stocktakes = append(stocktakes, *stocktake)
+		}
+	}
+	return stocktakes, nil
+}
+
+// ScheduleCycleCounts opens each day's cycle counts once per day, checking
+// every interval. A unique index on warehouse and cycle date keeps several
+// instances from generating the same day twice. It runs until ctx is done.
+func (h *CycleCountHandler) ScheduleCycleCounts(ctx context.Context, interval time.Duration) {
+	var generated time.Time
+
+	run := func() {
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		if today.Equal(generated) {
+			return
+		}
+		stocktakes, err := h.generateCycleCounts(today, 0)
+		if err != nil {
+			log.Printf("Warning: Failed to generate cycle counts: %v", err)
+			return
+		}
+		generated = today
+		if len(stocktakes) > 0 {
+			log.Printf("Generated %d cycle count(s) for %s", len(stocktakes), today.Format("2006-01-02"))
+		}
+	}
+
+	run()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run()
+		}
+	}
+}
+
+// GetClasses godoc
+// @Summary ABC classification
+// @Description Rank products by OUT quantity times unit price over the lookback period and show the class each falls into, without storing it. Serial-tracked products are classified but listed under excluded, since cycle counts leave them out.
+// @Tags cycle-counts
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} CycleCountClassesResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /cycle-counts/classes [get]
+func (h *CycleCountHandler) GetClasses(c *gin.Context) {
+	h.respondClasses(c, false)
+}
+
+// Classify godoc
+// @Summary Reclassify products
+// @Description Recompute and store the ABC class of every active product (requires stocktake:approve). This also runs before each day's cycle counts are generated.
+// @Tags cycle-counts
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} CycleCountClassesResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /cycle-counts/classify [post]
+func (h *CycleCountHandler) Classify(c *gin.Context) {
+	h.respondClasses(c, true)
+}
+
+// respondClasses runs the classification, storing it if asked to
+func (h *CycleCountHandler) respondClasses(c *gin.Context, store bool) {
+	since := time.Now().AddDate(0, 0, -h.lookbackDays)
+
+	var usage []ProductUsage
+	var err error
+	if store {
+		userID, _ := c.Get("userID")
+		err = h.db.Transaction(func(tx *gorm.DB) error {
+			usage, err = classifyProducts(tx, since, userID.(uint))
+			return err
+		})
+	} else {
+		usage, err = rankProducts(h.db, since)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to classify products"})
+		return
+	}
+
+	counts := map[string]int{"A": 0, "B": 0, "C": 0}
+	for _, u := range usage {
+		counts[u.Class]++
+	}
+	excluded, err := excludedFromCycleCounts(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to classify products"})
+		return
+	}
+
+	c.JSON(http.StatusOK, CycleCountClassesResponse{
+		Since:    since.Format("2006-01-02"),
+		Products: usage,
+		Counts:   counts,
+		Excluded: excluded,
+	})
+}
+
+// GenerateCycleCounts godoc
+// @Summary Generate today's cycle counts
+// @Description Reclassify products and open today's cycle count at every warehouse now instead of waiting for the scheduler (requires stocktake:approve). Warehouses that already have one are skipped, and serial-tracked products are listed under excluded.
+// @Tags cycle-counts
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /cycle-counts/generate [post]
+func (h *CycleCountHandler) GenerateCycleCounts(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	stocktakes, err := h.generateCycleCounts(today, userID.(uint))
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+	excluded, err := excludedFromCycleCounts(h.db)
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cycle_date": today.Format("2006-01-02"),
+		"stocktakes": stocktakes,
+		"excluded":   excluded,
+	})
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+)
+
+// TestSerialProductsAreExcludedFromCycleCounts checks that serial-tracked
+// products are reported as excluded and plain products are not
+func TestSerialProductsAreExcludedFromCycleCounts(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, models.RoleUser)
+	plain := createTestProduct(t, db, user.ID)
+	serial := createTestProduct(t, db, user.ID)
+	if err := db.Model(&serial).Update("tracking_mode", models.TrackingModeSerial).Error; err != nil {
+		t.Fatalf("set tracking mode: %v", err)
+	}
+
+	excluded, err := excludedFromCycleCounts(db)
+	if err != nil {
+		t.Fatalf("excluded: %v", err)
+	}
+	found := map[uint]bool{}
+	for _, p := range excluded {
+		found[p.ProductID] = true
+		if p.Reason == "" {
+			t.Errorf("product %d excluded without a reason", p.ProductID)
+		}
+	}
+	if !found[serial.ID] {
+		t.Errorf("serial-tracked product %d not reported as excluded", serial.ID)
+	}
+	if found[plain.ID] {
+		t.Errorf("plain product %d reported as excluded", plain.ID)
+	}
+}
@@ -0,0 +1,570 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/cache"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/notify"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	eventPublishBatch  = 500
+	eventDeliveryBatch = 100
+	eventRetryBase     = 5 * time.Second // first retry delay for a failing subscription, doubled on each failure
+	eventRetryMax      = 10 * time.Minute
+	eventDeliveryLease = 5 * time.Minute // how long a claimed subscription is hidden from other dispatchers
+
+	// eventPublishLock is the advisory lock that lets one instance at a time assign offsets
+	eventPublishLock = 7301001
+)
+
+// EventConsumer handles a batch of events for an internal subscription. An
+// error leaves the subscription's offset where it was so the batch is retried.
+type EventConsumer func(ctx context.Context, events []models.OutboxEvent) error
+
+// EventHandler publishes the outbox, delivers events to subscriptions and
+// serves the event stream
+type EventHandler struct {
+	db        *gorm.DB
+	consumers map[string]EventConsumer
+}
+
+func NewEventHandler(db *gorm.DB) *EventHandler {
+	return &EventHandler{
+		db:        db,
+		consumers: map[string]EventConsumer{},
+	}
+}
+
+// EventSubscriptionRequest holds the fields for creating a webhook subscription
+type EventSubscriptionRequest struct {
+	Name       string   `json:"name" binding:"required"`
+	URL        string   `json:"url" binding:"required"`
+	Secret     string   `json:"secret" binding:"required"`
+	EventTypes []string `json:"event_types"`
+	FromOffset *int64   `json:"from_offset"` // optional, defaults to the end of the stream
+}
+
+// ReplayEventsRequest moves a subscription back (or forward) to an offset
+type ReplayEventsRequest struct {
+	Offset int64 `json:"offset" binding:"min=0"` // events after this offset are delivered again
+}
+
+// EventBatch is the body posted to webhook subscriptions
+type EventBatch struct {
+	Subscription string               `json:"subscription"`
+	Events       []models.OutboxEvent `json:"events"`
+}
+
+// StockMovedEvent is the payload of StockMoved, StockMovementPending and StockMovementRejected
+type StockMovedEvent struct {
+	MovementID      uint      `json:"movement_id"`
+	ProductID       uint      `json:"product_id"`
+	WarehouseID     uint      `json:"warehouse_id"`
+	Type            string    `json:"type"`
+	Status          string    `json:"status"`
+	Quantity        int       `json:"quantity"`
+	OldQuantity     int       `json:"old_quantity"`
+	NewQuantity     int       `json:"new_quantity"`
+	ProductQuantity int       `json:"product_quantity"` // product total across warehouses afterwards
+	Reference       string    `json:"reference"`
+	ReasonCode      string    `json:"reason_code,omitempty"`
+	ReversalOf      *uint     `json:"reversal_of,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// newStockMovedEvent builds the event payload for a ledger row
+func newStockMovedEvent(movement *models.Stock, productQuantity int) StockMovedEvent {
+	return StockMovedEvent{
+		MovementID:      movement.ID,
+		ProductID:       movement.ProductID,
+		WarehouseID:     movement.WarehouseID,
+		Type:            movement.Type,
+		Status:          movement.Status,
+		Quantity:        movement.Quantity,
+		OldQuantity:     movement.OldQuantity,
+		NewQuantity:     movement.NewQuantity,
+		ProductQuantity: productQuantity,
+		Reference:       movement.Reference,
+		ReasonCode:      movement.ReasonCode,
+		ReversalOf:      movement.ReversalOf,
+		CreatedAt:       movement.CreatedAt,
+	}
+}
+
+// recordEvent writes a domain event to the outbox. It must run in the same
+// transaction as the change it describes.
+func recordEvent(tx *gorm.DB, eventType, aggregateType string, aggregateID uint, payload interface{}, actorID uint) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.OutboxEvent{
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       data,
+		ActorID:       actorID,
+	}).Error
+}
+
+// Subscribe registers an internal consumer under name. Its subscription row is
+// created on first use starting at the end of the stream, so it only sees new events.
+func (h *EventHandler) Subscribe(name string, consumer EventConsumer) error {
+	var head int64
+	if err := h.db.Model(&models.OutboxEvent{}).Select("COALESCE(MAX(event_offset), 0)").Scan(&head).Error; err != nil {
+		return err
+	}
+	subscription := models.EventSubscription{
+		Name:     name,
+		Type:     models.SubscriptionTypeInternal,
+		Offset:   head,
+		IsActive: true,
+	}
+	if err := h.db.Where(models.EventSubscription{Name: name}).FirstOrCreate(&subscription).Error; err != nil {
+		return err
+	}
+	h.consumers[name] = consumer
+	return nil
+}
+
+// DispatchEvents publishes the outbox and delivers events to subscriptions
+// every interval until ctx is cancelled
+func (h *EventHandler) DispatchEvents(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.publishOutbox(); err != nil {
+				log.Printf("Warning: Failed to publish outbox: %v", err)
+			}
+			h.deliverSubscriptions(ctx)
+		}
+	}
+}
+
+// publishOutbox gives committed, unpublished events the next offsets in ID
+// order. Offsets are assigned after commit, so an event whose transaction
+// commits late still lands after everything consumers have already read.
+func (h *EventHandler) publishOutbox() error {
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", eventPublishLock).Error; err != nil {
+			return err
+		}
+
+		var events []models.OutboxEvent
+		if err := tx.Where("event_offset IS NULL").Order("id").Limit(eventPublishBatch).Find(&events).Error; err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		var head int64
+		if err := tx.Model(&models.OutboxEvent{}).Select("COALESCE(MAX(event_offset), 0)").Scan(&head).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, e := range events {
+			head++
+			if err := tx.Model(&models.OutboxEvent{}).Where("id = ?", e.ID).
+				Updates(map[string]interface{}{"event_offset": head, "published_at": now}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deliverSubscriptions sends each due subscription the events after its
+// offset. A subscription is claimed with SKIP LOCKED and hidden behind a lease
+// while its batch is sent, so each is served by one instance at a time, in
+// order, without a transaction held open across the delivery.
+func (h *EventHandler) deliverSubscriptions(ctx context.Context) {
+	var ids []uint
+	h.db.Model(&models.EventSubscription{}).
+		Where("is_active = ? AND next_attempt_at <= ?", true, time.Now()).
+		Order("id").Pluck("id", &ids)
+
+	for _, id := range ids {
+		if err := h.deliverSubscription(ctx, id); err != nil {
+			log.Printf("Warning: Failed to deliver events to subscription %d: %v", id, err)
+		}
+	}
+}
+
+// deliverSubscription claims a subscription and reads its next batch, sends
+// it, then moves the offset on success or backs the subscription off on failure
+func (h *EventHandler) deliverSubscription(ctx context.Context, id uint) error {
+	var subscription models.EventSubscription
+	var events []models.OutboxEvent
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ? AND is_active = ? AND next_attempt_at <= ?", id, true, time.Now()).
+			First(&subscription).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil // another instance has it
+			}
+			return err
+		}
+		if subscription.Type == models.SubscriptionTypeInternal {
+			if _, ok := h.consumers[subscription.Name]; !ok {
+				return nil // registered by another build of the app
+			}
+		}
+
+		if err := tx.Where("event_offset > ?", subscription.Offset).
+			Order("event_offset").Limit(eventDeliveryBatch).Find(&events).Error; err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		// Hide it from other dispatchers while the batch is being sent
+		return tx.Model(&subscription).Update("next_attempt_at", time.Now().Add(eventDeliveryLease)).Error
+	})
+	if err != nil || len(events) == 0 {
+		return err
+	}
+	last := *events[len(events)-1].Offset
+
+	// Events the subscription does not want are skipped over, not delivered
+	wanted := events[:0:0]
+	for _, e := range events {
+		if subscription.EventTypes == "" || containsKind(subscription.EventTypes, e.Type) {
+			wanted = append(wanted, e)
+		}
+	}
+
+	var sendErr error
+	if len(wanted) > 0 {
+		switch subscription.Type {
+		case models.SubscriptionTypeWebhook:
+			var body []byte
+			body, sendErr = json.Marshal(EventBatch{Subscription: subscription.Name, Events: wanted})
+			if sendErr == nil {
+				deliveryID := fmt.Sprintf("%d-%d", subscription.ID, last)
+				sendErr = notify.PostWebhook(ctx, subscription.URL, subscription.Secret, deliveryID, body)
+			}
+		case models.SubscriptionTypeInternal:
+			sendErr = h.consumers[subscription.Name](ctx, wanted)
+		}
+	}
+
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		var current models.EventSubscription
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		// A replay moved the offset while the batch was out; the new offset wins
+		if current.Offset != subscription.Offset {
+			return nil
+		}
+
+		updates := map[string]interface{}{
+			"last_offset":     last,
+			"attempts":        0,
+			"last_error":      "",
+			"next_attempt_at": time.Now(),
+		}
+		if sendErr != nil {
+			attempts := current.Attempts + 1
+			delay := eventRetryBase
+			for i := 1; i < attempts && delay < eventRetryMax; i++ {
+				delay *= 2
+			}
+			updates = map[string]interface{}{
+				"attempts":        attempts,
+				"last_error":      sendErr.Error(),
+				"next_attempt_at": time.Now().Add(min(delay, eventRetryMax)),
+			}
+			log.Printf("Warning: Event delivery to %s failed (attempt %d): %v", subscription.Name, attempts, sendErr)
+		}
+		return tx.Model(&current).Updates(updates).Error
+	})
+}
+
+// GetEvents godoc
+// @Summary Read the event stream
+// @Description Read published domain events in offset order, starting after the given offset. Consumers keep the offset of the last event they processed and pass it back to resume or replay.
+// @Tags events
+// @Produce json
+// @Security BearerAuth
+// @Param after query int false "Return events after this offset" default(0)
+// @Param limit query int false "Maximum events to return" default(100)
+// @Param type query string false "Event type filter, comma-separated"
+// @Param aggregate_type query string false "product or stock"
+// @Param aggregate_id query int false "Aggregate ID filter"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Router /events [get]
+func (h *EventHandler) GetEvents(c *gin.Context) {
+	after, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil || after < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	query := h.db.Where("event_offset > ?", after)
+	if types := c.Query("type"); types != "" {
+		query = query.Where("type IN ?", strings.Split(types, ","))
+	}
+	if aggregateType := c.Query("aggregate_type"); aggregateType != "" {
+		query = query.Where("aggregate_type = ?", aggregateType)
+	}
+	if aggregateID := c.Query("aggregate_id"); aggregateID != "" {
+		query = query.Where("aggregate_id = ?", aggregateID)
+	}
+
+	var events []models.OutboxEvent
+	query.Order("event_offset").Limit(limit).Find(&events)
+
+	next := after
+	if len(events) > 0 {
+		next = *events[len(events)-1].Offset
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":      events,
+		"next_offset": next,
+	})
+}
+
+// GetEventSubscriptions godoc
+// @Summary Get event subscriptions
+// @Description List the consumers of the event stream with their offsets and delivery state
+// @Tags events
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} ErrorResponse
+// @Router /event-subscriptions [get]
+func (h *EventHandler) GetEventSubscriptions(c *gin.Context) {
+	var subscriptions []models.EventSubscription
+	h.db.Where("is_active = ?", true).Order("name").Find(&subscriptions)
+
+	var head int64
+	h.db.Model(&models.OutboxEvent{}).Select("COALESCE(MAX(event_offset), 0)").Scan(&head)
+
+	c.JSON(http.StatusOK, gin.H{
+		"subscriptions": subscriptions,
+		"head_offset":   head,
+	})
+}
+
+// CreateEventSubscription godoc
+// @Summary Create an event subscription
+// @Description Subscribe a webhook to the event stream. Batches of events are posted in offset order, signed with HMAC-SHA256 under the subscription's secret, and retried with backoff until acknowledged with a 2xx (requires event:admin).
+// @Tags events
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param subscription body EventSubscriptionRequest true "Subscription details"
+// @Success 201 {object} models.EventSubscription
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /event-subscriptions [post]
+func (h *EventHandler) CreateEventSubscription(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req EventSubscriptionRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL must be an http or https URL"})
+		return
+	}
+
+	var existing models.EventSubscription
+	if err := h.db.Where("name = ?", req.Name).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Subscription name already exists"})
+		return
+	}
+
+	offset := int64(0)
+	if req.FromOffset != nil {
+		offset = *req.FromOffset
+	} else {
+		h.db.Model(&models.OutboxEvent{}).Select("COALESCE(MAX(event_offset), 0)").Scan(&offset)
+	}
+
+	subscription := models.EventSubscription{
+		Name:          req.Name,
+		Type:          models.SubscriptionTypeWebhook,
+		URL:           req.URL,
+		Secret:        req.Secret,
+		EventTypes:    strings.Join(req.EventTypes, ","),
+		Offset:        offset,
+		NextAttemptAt: time.Now(),
+		IsActive:      true,
+		CreatedBy:     userID.(uint),
+		UpdatedBy:     userID.(uint),
+	}
+	if err := h.db.Create(&subscription).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create subscription"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// ReplayEvents godoc
+// @Summary Replay events to a subscription
+// @Description Move a subscription's offset so every event after it is delivered again (requires event:admin).
+// @Tags events
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Subscription ID"
+// @Param replay body ReplayEventsRequest true "Offset to replay from"
+// @Success 200 {object} MessageResponse "Subscription rewound"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /event-subscriptions/{id}/replay [post]
+func (h *EventHandler) ReplayEvents(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	var req ReplayEventsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// A delivery in flight sees the offset has moved and leaves it alone
+		var subscription models.EventSubscription
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND is_active = ?", id, true).First(&subscription).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return notFoundError("Subscription not found")
+			}
+			return err
+		}
+		return tx.Model(&subscription).Updates(map[string]interface{}{
+			"last_offset":     req.Offset,
+			"attempts":        0,
+			"last_error":      "",
+			"next_attempt_at": time.Now(),
+			"updated_by":      userID.(uint),
+		}).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Subscription rewound"})
+}
+
+// DeleteEventSubscription godoc
+// @Summary Delete an event subscription
+// @Description Stop delivering events to a webhook subscription (requires event:admin).
+// @Tags events
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} MessageResponse "Subscription deleted successfully"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /event-subscriptions/{id} [delete]
+func (h *EventHandler) DeleteEventSubscription(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	var subscription models.EventSubscription
+	if err := h.db.Where("id = ? AND type = ? AND is_active = ?", id, models.SubscriptionTypeWebhook, true).
+		First(&subscription).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+
+	if err := h.db.Model(&subscription).Update("is_active", false).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Subscription deleted successfully"})
+}
+
+// CacheInvalidationConsumer clears the product and stock caches touched by
+// events. Handlers already clear them after their own writes; this also
+// catches changes made by other instances and background jobs.
+func CacheInvalidationConsumer(client *redis.Client) EventConsumer {
+	return func(ctx context.Context, events []models.OutboxEvent) error {
+		productIDs := []uint{}
+		stockChanged := false
+		for _, e := range events {
+			switch e.Type {
+			case models.EventProductCreated, models.EventProductUpdated, models.EventProductDeleted:
+				productIDs = append(productIDs, e.AggregateID)
+			case models.EventStockMoved:
+				var moved StockMovedEvent
+				if err := json.Unmarshal(e.Payload, &moved); err != nil {
+					return err
+				}
+				productIDs = append(productIDs, moved.ProductID)
+				stockChanged = true
+			}
+		}
+		if len(productIDs) == 0 {
+			return nil
+		}
+
+		if stockChanged {
+			if err := cache.InvalidateStockList(client, ctx); err != nil {
+				return err
+			}
+		}
+		if err := cache.InvalidateProductList(client, ctx); err != nil {
+			return err
+		}
+		for _, id := range productIDs {
+			if err := client.Del(ctx, cache.GenerateProductKey(id)).Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
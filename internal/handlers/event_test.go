@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+)
+
+// productUpdates returns the ProductUpdated payloads recorded for a product
+func productUpdates(t *testing.T, db *gorm.DB, productID uint) []models.Product {
+	t.Helper()
+	var events []models.OutboxEvent
+	if err := db.Where("type = ? AND aggregate_type = ? AND aggregate_id = ?",
+		models.EventProductUpdated, "product", productID).Order("id").Find(&events).Error; err != nil {
+		t.Fatalf("load events: %v", err)
+	}
+	products := make([]models.Product, len(events))
+	for i, e := range events {
+		if err := json.Unmarshal(e.Payload, &products[i]); err != nil {
+			t.Fatalf("decode event %d: %v", e.ID, err)
+		}
+	}
+	return products
+}
+
+// TestReceiptRecordsProductUpdated checks that a receipt moving the average
+// cost publishes the new average
+func TestReceiptRecordsProductUpdated(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, models.RoleUser)
+	product := createTestProduct(t, db, user.ID)
+
+	receive := func(quantity int, unitCost float64) {
+		t.Helper()
+		if _, err := postTestMovement(db, stockMovementInput{
+			ProductID: product.ID, Type: "IN", Quantity: quantity, UnitCost: unitCost, UserID: user.ID,
+		}); err != nil {
+			t.Fatalf("stock in: %v", err)
+		}
+	}
+
+	receive(10, 4)
+	receive(10, 8)
+	receive(20, 6) // the average is already 6
+
+	updates := productUpdates(t, db, product.ID)
+	if len(updates) != 2 {
+		t.Fatalf("%d ProductUpdated events, want 2", len(updates))
+	}
+	if updates[0].AverageCost != 4 || updates[1].AverageCost != 6 {
+		t.Errorf("published averages %v and %v, want 4 and 6", updates[0].AverageCost, updates[1].AverageCost)
+	}
+}
+
+// TestClassifyRecordsProductUpdated checks that storing the ABC classes
+// publishes the products whose class changed, and only those
+func TestClassifyRecordsProductUpdated(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, models.RoleUser)
+	product := createTestProduct(t, db, user.ID) // never issued, so class C
+
+	classify := func() {
+		t.Helper()
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			_, err := classifyProducts(tx, time.Now().AddDate(0, 0, -90), user.ID)
+			return err
+		}); err != nil {
+			t.Fatalf("classify: %v", err)
+		}
+	}
+
+	classify()
+	updates := productUpdates(t, db, product.ID)
+	if len(updates) != 1 || updates[0].ABCClass != "C" {
+		t.Fatalf("got %d ProductUpdated events %v, want one with class C", len(updates), updates)
+	}
+
+	classify()
+	if updates := productUpdates(t, db, product.ID); len(updates) != 1 {
+		t.Errorf("%d ProductUpdated events after an unchanged classification, want 1", len(updates))
+	}
+}
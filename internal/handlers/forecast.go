@@ -0,0 +1,513 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/cache"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	ForecastMethodMovingAverage        = "moving_average"
+	ForecastMethodExponentialSmoothing = "exponential_smoothing"
+)
+
+// forecastPeriods describes each bucket size: its length in days, the season
+// it repeats over and how many buckets of history are read by default and at most
+var forecastPeriods = map[string]struct {
+	days       float64
+	season     int
+	history    int
+	maxHistory int
+}{
+	"day":   {days: 1, season: 7, history: 91, maxHistory: 730},
+	"week":  {days: 7, season: 52, history: 104, maxHistory: 156},
+	"month": {days: 365.0 / 12, season: 12, history: 36, maxHistory: 60},
+}
+
+// smoothingGrid are the parameter values tried when fitting exponential smoothing
+var smoothingGrid = []float64{0.1, 0.3, 0.5, 0.7, 0.9}
+
+// ForecastHandler forecasts product demand from the stock ledger and derives
+// safety stock and reorder points from it
+type ForecastHandler struct {
+	db           *gorm.DB
+	cache        *redis.Client
+	serviceLevel float64
+	leadTimeDays int
+}
+
+func NewForecastHandler(db *gorm.DB, cache *redis.Client, serviceLevel float64, leadTimeDays int) *ForecastHandler {
+	if serviceLevel <= 0 || serviceLevel >= 1 {
+		serviceLevel = 0.95
+	}
+	if leadTimeDays < 0 {
+		leadTimeDays = 7
+	}
+	return &ForecastHandler{
+		db:           db,
+		cache:        cache,
+		serviceLevel: serviceLevel,
+		leadTimeDays: leadTimeDays,
+	}
+}
+
+// DemandPoint is the demand of one period
+type DemandPoint struct {
+	PeriodStart time.Time `json:"period_start"`
+	Quantity    float64   `json:"quantity"`
+}
+
+// ForecastResponse is a product's demand history and forecast with the stock
+// levels derived from it
+type ForecastResponse struct {
+	ProductID          uint          `json:"product_id"`
+	SKU                string        `json:"sku"`
+	Name               string        `json:"name"`
+	Method             string        `json:"method"`
+	Seasonal           bool          `json:"seasonal"` // exponential smoothing used a seasonal (Holt-Winters) model
+	Period             string        `json:"period"`
+	SeasonLength       int           `json:"season_length"`
+	History            []DemandPoint `json:"history"`
+	Forecast           []DemandPoint `json:"forecast"`
+	RMSE               float64       `json:"rmse"` // one-step-ahead forecast error per period
+	DailyDemand        float64       `json:"daily_demand"`
+	LeadTimeDays       int           `json:"lead_time_days"`
+	ServiceLevel       float64       `json:"service_level"`
+	SafetyStock        int           `json:"safety_stock"`
+	ReorderPoint       int           `json:"reorder_point"` // suggested min_quantity
+	SuggestedMaxQty    int           `json:"suggested_max_quantity"`
+	Available          int           `json:"available"`     // available to promise across all warehouses
+	DaysOfCover        *float64      `json:"days_of_cover"` // nil when there is no forecast demand
+	CurrentMinQuantity int           `json:"current_min_quantity"`
+	CurrentMaxQuantity int           `json:"current_max_quantity"`
+}
+
+// periodStart truncates t to the start of its period in local time; weeks start on Monday
+func periodStart(t time.Time, period string) time.Time {
+	t = t.In(time.Local)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	switch period {
+	case "week":
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	default:
+		return day
+	}
+}
+
+// shiftPeriod moves a period start n periods forward, or back when n is negative
+func shiftPeriod(start time.Time, period string, n int) time.Time {
+	switch period {
+	case "week":
+		return start.AddDate(0, 0, 7*n)
+	case "month":
+		return start.AddDate(0, n, 0)
+	default:
+		return start.AddDate(0, 0, n)
+	}
+}
+
+// demandHistory buckets a product's OUT movements, net of reversals, into the
+// given number of complete periods before the current one
+func demandHistory(tx *gorm.DB, productID uint, period string, periods int) ([]DemandPoint, error) {
+	current := periodStart(time.Now(), period)
+	starts := make([]time.Time, periods)
+	for i := range starts {
+		starts[i] = shiftPeriod(current, period, i-periods)
+	}
+
+	var rows []struct {
+		CreatedAt time.Time
+		Quantity  int
+	}
+	if err := tx.Table("stocks").
+		Select("stocks.created_at, CASE WHEN stocks.type = 'OUT' THEN stocks.quantity ELSE -stocks.quantity END AS quantity").
+		Joins("LEFT JOIN stocks AS reversed ON reversed.id = stocks.reversal_of").
+		Where("stocks.product_id = ? AND stocks.status = ?", productID, models.StockStatusPosted).
+		Where("stocks.created_at >= ? AND stocks.created_at < ?", starts[0], current).
+		Where("stocks.type = 'OUT' OR reversed.type = 'OUT'").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	index := make(map[time.Time]int, periods)
+	history := make([]DemandPoint, periods)
+	for i, s := range starts {
+		index[s] = i
+		history[i] = DemandPoint{PeriodStart: s}
+	}
+	for _, r := range rows {
+		if i, ok := index[periodStart(r.CreatedAt, period)]; ok {
+			history[i].Quantity += float64(r.Quantity)
+		}
+	}
+	for i := range history {
+		history[i].Quantity = math.Max(history[i].Quantity, 0)
+	}
+	return history, nil
+}
+
+// movingAverageForecast forecasts the mean of the last window periods and
+// returns the root mean squared one-step-ahead error over the history
+func movingAverageForecast(series []float64, window int) (float64, float64) {
+	window = min(window, len(series))
+	if window == 0 {
+		return 0, 0
+	}
+	mean := func(values []float64) float64 {
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+
+	sse, n := 0.0, 0
+	for t := window; t < len(series); t++ {
+		e := series[t] - mean(series[t-window:t])
+		sse += e * e
+		n++
+	}
+	rmse := 0.0
+	if n > 0 {
+		rmse = math.Sqrt(sse / float64(n))
+	}
+	return mean(series[len(series)-window:]), rmse
+}
+
+// simpleSmoothing fits simple exponential smoothing, picking the alpha with the
+// lowest one-step-ahead error, and returns the final level and that error
+func simpleSmoothing(series []float64) (float64, float64) {
+	if len(series) == 0 {
+		return 0, 0
+	}
+	bestLevel, bestSSE := 0.0, math.Inf(1)
+	for _, alpha := range smoothingGrid {
+		level, sse := series[0], 0.0
+		for _, y := range series[1:] {
+			e := y - level
+			sse += e * e
+			level += alpha * e
+		}
+		if sse < bestSSE {
+			bestLevel, bestSSE = level, sse
+		}
+	}
+	if len(series) < 2 {
+		return bestLevel, 0
+	}
+	return bestLevel, math.Sqrt(bestSSE / float64(len(series)-1))
+}
+
+// holtWinters fits additive Holt-Winters (level, trend and season of length m)
+// over a grid of smoothing parameters and forecasts the next horizon periods.
+// It needs at least two full seasons of history and returns nil without them.
+func holtWinters(series []float64, m, horizon int) ([]float64, float64) {
+	n := len(series)
+	if m < 1 || n < 2*m {
+		return nil, 0
+	}
+	var best []float64
+	bestSSE := math.Inf(1)
+
+	for _, alpha := range smoothingGrid {
+		for _, beta := range smoothingGrid {
+			for _, gamma := range smoothingGrid {
+				// Initialise from the first two seasons
+				first, second := 0.0, 0.0
+				for i := 0; i < m; i++ {
+					first += series[i]
+					second += series[m+i]
+				}
+				first /= float64(m)
+				second /= float64(m)
+				level, trend := first, (second-first)/float64(m)
+				season := make([]float64, m)
+				for i := 0; i < m; i++ {
+					season[i] = series[i] - first
+				}
+
+				sse := 0.0
+				for t := m; t < n; t++ {
+					s := season[t%m]
+					e := series[t] - (level + trend + s)
+					sse += e * e
+					prev := level
+					level = alpha*(series[t]-s) + (1-alpha)*(level+trend)
+					trend = beta*(level-prev) + (1-beta)*trend
+					season[t%m] = gamma*(series[t]-level) + (1-gamma)*s
+				}
+				if sse >= bestSSE {
+					continue
+				}
+				bestSSE = sse
+				best = make([]float64, horizon)
+				for k := 1; k <= horizon; k++ {
+					best[k-1] = level + float64(k)*trend + season[(n+k-1)%m]
+				}
+			}
+		}
+	}
+	return best, math.Sqrt(bestSSE / float64(n-m))
+}
+
+// forecastValues forecasts the next horizon periods with the given method.
+// Exponential smoothing is seasonal once there are two full seasons of
+// history and falls back to simple smoothing before that.
+func forecastValues(series []float64, method string, season, horizon, window int) ([]float64, float64, bool) {
+	if method == ForecastMethodExponentialSmoothing && len(series) >= 2*season {
+		values, rmse := holtWinters(series, season, horizon)
+		return values, rmse, true
+	}
+
+	var next, rmse float64
+	if method == ForecastMethodMovingAverage {
+		next, rmse = movingAverageForecast(series, window)
+	} else {
+		next, rmse = simpleSmoothing(series)
+	}
+	values := make([]float64, horizon)
+	for i := range values {
+		values[i] = next
+	}
+	return values, rmse, false
+}
+
+// serviceLevelZ is the standard normal quantile for a cycle service level
+func serviceLevelZ(level float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*level-1)
+}
+
+// leadTimeFor is the lead time of the supplier a product would be bought from:
+// the preferred supplier, otherwise the quickest
+func (h *ForecastHandler) leadTimeFor(productID uint) int {
+	var supplier models.Supplier
+	err := h.db.Joins("JOIN supplier_products ON supplier_products.supplier_id = suppliers.id").
+		Where("supplier_products.product_id = ? AND suppliers.is_active = ?", productID, true).
+		Order("supplier_products.is_preferred DESC, suppliers.lead_time_days").
+		First(&supplier).Error
+	if err != nil {
+		return h.leadTimeDays
+	}
+	return supplier.LeadTimeDays
+}
+
+// buildForecast forecasts a product's demand from the query parameters:
+// period (day, week, month), history (periods), horizon (periods), method,
+// window (moving average periods), lead_time_days and service_level
+func (h *ForecastHandler) buildForecast(c *gin.Context, product models.Product) (*ForecastResponse, error) {
+	period := c.DefaultQuery("period", "week")
+	spec, ok := forecastPeriods[period]
+	if !ok {
+		return nil, validationError("Invalid period, expected day, week or month")
+	}
+	method := c.DefaultQuery("method", ForecastMethodExponentialSmoothing)
+	if method != ForecastMethodMovingAverage && method != ForecastMethodExponentialSmoothing {
+		return nil, validationError("Invalid method, expected moving_average or exponential_smoothing")
+	}
+
+	history, err := strconv.Atoi(c.DefaultQuery("history", strconv.Itoa(spec.history)))
+	if err != nil || history < 2 || history > spec.maxHistory {
+		return nil, validationError("Invalid history")
+	}
+	horizon, err := strconv.Atoi(c.DefaultQuery("horizon", "4"))
+	if err != nil || horizon < 1 || horizon > spec.season {
+		return nil, validationError("Invalid horizon")
+	}
+	window, err := strconv.Atoi(c.DefaultQuery("window", "4"))
+	if err != nil || window < 1 {
+		return nil, validationError("Invalid window")
+	}
+	serviceLevel := h.serviceLevel
+	if v := c.Query("service_level"); v != "" {
+		serviceLevel, err = strconv.ParseFloat(v, 64)
+		if err != nil || serviceLevel <= 0 || serviceLevel >= 1 {
+			return nil, validationError("Invalid service_level, expected a value between 0 and 1")
+		}
+	}
+	leadTime := h.leadTimeFor(product.ID)
+	if v := c.Query("lead_time_days"); v != "" {
+		leadTime, err = strconv.Atoi(v)
+		if err != nil || leadTime < 0 {
+			return nil, validationError("Invalid lead_time_days")
+		}
+	}
+
+	points, err := demandHistory(h.db, product.ID, period, history)
+	if err != nil {
+		return nil, err
+	}
+	series := make([]float64, len(points))
+	for i, p := range points {
+		series[i] = p.Quantity
+	}
+
+	resp := &ForecastResponse{
+		ProductID:          product.ID,
+		SKU:                product.SKU,
+		Name:               product.Name,
+		Method:             method,
+		Period:             period,
+		SeasonLength:       spec.season,
+		History:            points,
+		LeadTimeDays:       leadTime,
+		ServiceLevel:       serviceLevel,
+		CurrentMinQuantity: product.MinQuantity,
+		CurrentMaxQuantity: product.MaxQuantity,
+	}
+
+	var values []float64
+	values, resp.RMSE, resp.Seasonal = forecastValues(series, method, spec.season, horizon, window)
+
+	start := periodStart(time.Now(), period)
+	total := 0.0
+	for _, v := range values {
+		v = math.Max(v, 0)
+		resp.Forecast = append(resp.Forecast, DemandPoint{PeriodStart: start, Quantity: math.Round(v*100) / 100})
+		start = shiftPeriod(start, period, 1)
+		total += v
+	}
+
+	// Safety stock covers the forecast error over the lead time
+	resp.DailyDemand = total / (float64(horizon) * spec.days)
+	leadTimePeriods := float64(leadTime) / spec.days
+	safetyStock := serviceLevelZ(serviceLevel) * resp.RMSE * math.Sqrt(leadTimePeriods)
+	resp.SafetyStock = int(math.Ceil(math.Max(safetyStock, 0)))
+	resp.ReorderPoint = int(math.Ceil(resp.DailyDemand*float64(leadTime))) + resp.SafetyStock
+	resp.SuggestedMaxQty = resp.ReorderPoint + int(math.Ceil(total))
+
+	var available ProductQuantity
+	if err := h.db.Table("stock_balances").
+		Select("product_id, COALESCE(SUM(quantity - reserved_quantity - quarantined_quantity), 0) AS quantity").
+		Where("product_id = ?", product.ID).
+		Group("product_id").
+		Scan(&available).Error; err != nil {
+		return nil, err
+	}
+	resp.Available = available.Quantity
+	if resp.DailyDemand > 0 {
+		cover := math.Round(float64(max(resp.Available, 0))/resp.DailyDemand*10) / 10
+		resp.DaysOfCover = &cover
+	}
+	resp.DailyDemand = math.Round(resp.DailyDemand*100) / 100
+	resp.RMSE = math.Round(resp.RMSE*100) / 100
+	return resp, nil
+}
+
+// GetForecast godoc
+// @Summary Forecast product demand
+// @Description Forecast a product's demand from its OUT movements (net of reversals) by moving average or exponential smoothing, seasonal when at least two seasons of history are read, and derive safety stock, a reorder point and days of cover
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param period query string false "day, week or month" default(week)
+// @Param method query string false "moving_average or exponential_smoothing" default(exponential_smoothing)
+// @Param history query int false "Periods of history to read"
+// @Param horizon query int false "Periods to forecast" default(4)
+// @Param window query int false "Moving average window in periods" default(4)
+// @Param lead_time_days query int false "Replenishment lead time, defaults to the supplier's"
+// @Param service_level query number false "Cycle service level between 0 and 1"
+// @Success 200 {object} ForecastResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /products/{id}/forecast [get]
+func (h *ForecastHandler) GetForecast(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var product models.Product
+	if err := h.db.First(&product, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	forecast, err := h.buildForecast(c, product)
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, forecast)
+}
+
+// ApplyForecast godoc
+// @Summary Apply a forecast to a product
+// @Description Set the product's min_quantity to the forecast reorder point and max_quantity to the reorder point plus the forecast demand over the horizon. Takes the same parameters as the forecast.
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param period query string false "day, week or month" default(week)
+// @Param method query string false "moving_average or exponential_smoothing" default(exponential_smoothing)
+// @Param history query int false "Periods of history to read"
+// @Param horizon query int false "Periods to forecast" default(4)
+// @Param window query int false "Moving average window in periods" default(4)
+// @Param lead_time_days query int false "Replenishment lead time, defaults to the supplier's"
+// @Param service_level query number false "Cycle service level between 0 and 1"
+// @Success 200 {object} ForecastResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /products/{id}/forecast/apply [post]
+func (h *ForecastHandler) ApplyForecast(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var product models.Product
+	if err := h.db.Where("id = ? AND is_active = ?", id, true).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load product"})
+		return
+	}
+
+	forecast, err := h.buildForecast(c, product)
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&product).Updates(map[string]interface{}{
+			"min_quantity": forecast.ReorderPoint,
+			"max_quantity": forecast.SuggestedMaxQty,
+			"updated_by":   userID.(uint),
+		}).Error; err != nil {
+			return err
+		}
+		product.MinQuantity = forecast.ReorderPoint
+		product.MaxQuantity = forecast.SuggestedMaxQty
+		product.UpdatedBy = userID.(uint)
+		return recordEvent(tx, models.EventProductUpdated, "product", product.ID, product, userID.(uint))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		return
+	}
+
+	// Invalidate List Cache
+	cache.InvalidateProductList(h.cache, c.Request.Context())
+	h.cache.Del(c.Request.Context(), cache.GenerateProductKey(product.ID))
+
+	c.JSON(http.StatusOK, forecast)
+}
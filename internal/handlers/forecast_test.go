@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// approx reports whether two floats agree to within tolerance
+func approx(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+// repeat returns pattern repeated n times
+func repeat(pattern []float64, n int) []float64 {
+	series := make([]float64, 0, len(pattern)*n)
+	for i := 0; i < n; i++ {
+		series = append(series, pattern...)
+	}
+	return series
+}
+
+func TestMovingAverageForecast(t *testing.T) {
+	tests := []struct {
+		name     string
+		series   []float64
+		window   int
+		wantNext float64
+		wantRMSE float64
+	}{
+		{"flat", []float64{5, 5, 5, 5, 5, 5}, 3, 5, 0},
+		{"rising", []float64{1, 2, 3, 4, 5, 6}, 2, 5.5, 1.5},
+		{"window longer than history", []float64{2, 4, 6}, 10, 4, 0},
+		{"single period", []float64{7}, 4, 7, 0},
+		{"empty", nil, 4, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, rmse := movingAverageForecast(tt.series, tt.window)
+			if !approx(next, tt.wantNext, 1e-9) || !approx(rmse, tt.wantRMSE, 1e-9) {
+				t.Errorf("got (%v, %v), want (%v, %v)", next, rmse, tt.wantNext, tt.wantRMSE)
+			}
+		})
+	}
+}
+
+func TestSimpleSmoothing(t *testing.T) {
+	tests := []struct {
+		name      string
+		series    []float64
+		wantLevel float64
+		wantRMSE  float64
+	}{
+		{"flat", []float64{8, 8, 8, 8, 8}, 8, 0},
+		{"single period", []float64{3}, 3, 0},
+		{"empty", nil, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, rmse := simpleSmoothing(tt.series)
+			if !approx(level, tt.wantLevel, 1e-9) || !approx(rmse, tt.wantRMSE, 1e-9) {
+				t.Errorf("got (%v, %v), want (%v, %v)", level, rmse, tt.wantLevel, tt.wantRMSE)
+			}
+		})
+	}
+
+	// A step up is followed: the level ends between the old and new value,
+	// close to the new one with the best alpha
+	level, rmse := simpleSmoothing([]float64{10, 10, 10, 10, 20, 20, 20, 20})
+	if level <= 15 || level > 20 {
+		t.Errorf("level after a step to 20 = %v, want between 15 and 20", level)
+	}
+	if rmse <= 0 {
+		t.Errorf("rmse after a step = %v, want > 0", rmse)
+	}
+}
+
+func TestHoltWinters(t *testing.T) {
+	t.Run("seasonal", func(t *testing.T) {
+		pattern := []float64{10, 20, 30, 40}
+		values, rmse := holtWinters(repeat(pattern, 3), 4, 4)
+		if len(values) != 4 {
+			t.Fatalf("got %d forecasts, want 4", len(values))
+		}
+		for i, v := range values {
+			if !approx(v, pattern[i], 1e-6) {
+				t.Errorf("period %d: forecast %v, want %v", i+1, v, pattern[i])
+			}
+		}
+		if !approx(rmse, 0, 1e-6) {
+			t.Errorf("rmse = %v, want 0 for an exactly repeating series", rmse)
+		}
+	})
+
+	t.Run("flat", func(t *testing.T) {
+		values, rmse := holtWinters(repeat([]float64{6}, 10), 5, 3)
+		for i, v := range values {
+			if !approx(v, 6, 1e-6) {
+				t.Errorf("period %d: forecast %v, want 6", i+1, v)
+			}
+		}
+		if !approx(rmse, 0, 1e-6) {
+			t.Errorf("rmse = %v, want 0", rmse)
+		}
+	})
+
+	t.Run("shorter than two seasons", func(t *testing.T) {
+		if values, _ := holtWinters([]float64{1, 2, 3, 4, 5, 6, 7}, 4, 2); values != nil {
+			t.Errorf("got %v, want nil without two full seasons", values)
+		}
+	})
+}
+
+func TestForecastValues(t *testing.T) {
+	pattern := []float64{10, 20, 30, 40}
+
+	t.Run("seasonal with two seasons", func(t *testing.T) {
+		values, _, seasonal := forecastValues(repeat(pattern, 2), ForecastMethodExponentialSmoothing, 4, 2, 4)
+		if !seasonal {
+			t.Fatal("not seasonal with two full seasons")
+		}
+		if len(values) != 2 || !approx(values[0], 10, 1e-6) || !approx(values[1], 20, 1e-6) {
+			t.Errorf("got %v, want [10 20]", values)
+		}
+	})
+
+	t.Run("shorter than two seasons falls back to simple smoothing", func(t *testing.T) {
+		values, _, seasonal := forecastValues([]float64{5, 5, 5, 5, 5}, ForecastMethodExponentialSmoothing, 4, 3, 4)
+		if seasonal {
+			t.Fatal("seasonal with less than two seasons")
+		}
+		for i, v := range values {
+			if !approx(v, 5, 1e-9) {
+				t.Errorf("period %d: forecast %v, want 5", i+1, v)
+			}
+		}
+	})
+
+	t.Run("moving average is never seasonal", func(t *testing.T) {
+		values, _, seasonal := forecastValues(repeat(pattern, 3), ForecastMethodMovingAverage, 4, 2, 4)
+		if seasonal {
+			t.Fatal("moving average reported seasonal")
+		}
+		if len(values) != 2 || !approx(values[0], 25, 1e-9) || !approx(values[1], 25, 1e-9) {
+			t.Errorf("got %v, want [25 25]", values)
+		}
+	})
+}
+
+func TestServiceLevelZ(t *testing.T) {
+	tests := []struct {
+		level float64
+		want  float64
+	}{
+		{0.5, 0},
+		{0.9, 1.2816},
+		{0.95, 1.6449},
+		{0.975, 1.9600},
+		{0.99, 2.3263},
+	}
+	for _, tt := range tests {
+		if got := serviceLevelZ(tt.level); !approx(got, tt.want, 1e-4) {
+			t.Errorf("serviceLevelZ(%v) = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		name   string
+		t      time.Time
+		period string
+		want   time.Time
+	}{
+		{"day", at(2026, 10, 14, 15, 30), "day", at(2026, 10, 14, 0, 0)},
+		{"week from Wednesday", at(2026, 10, 14, 15, 30), "week", at(2026, 10, 12, 0, 0)},
+		{"week on Monday midnight", at(2026, 10, 12, 0, 0), "week", at(2026, 10, 12, 0, 0)},
+		{"week from Sunday night", at(2026, 10, 18, 23, 59), "week", at(2026, 10, 12, 0, 0)},
+		{"week across the new year", at(2026, 1, 1, 9, 0), "week", at(2025, 12, 29, 0, 0)},
+		{"month from the last day", at(2026, 10, 31, 23, 59), "month", at(2026, 10, 1, 0, 0)},
+		{"month on the first", at(2026, 3, 1, 0, 0), "month", at(2026, 3, 1, 0, 0)},
+		{"month in a leap February", at(2028, 2, 29, 12, 0), "month", at(2028, 2, 1, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := periodStart(tt.t, tt.period); !got.Equal(tt.want) {
+				t.Errorf("periodStart(%v, %s) = %v, want %v", tt.t, tt.period, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+)
+
+// MovementCatalogHandler manages the movement type and reason code catalogue
+type MovementCatalogHandler struct {
+	db *gorm.DB
+}
+
+func NewMovementCatalogHandler(db *gorm.DB) *MovementCatalogHandler {
+	return &MovementCatalogHandler{
+		db: db,
+	}
+}
+
+// validateManualMovement checks that a movement posted through POST /stocks
+// uses an active manual movement type and, where needed, a valid reason code
+func validateManualMovement(tx *gorm.DB, typeCode, reasonCode string) (models.MovementType, error) {
+	movementType, err := lookupMovementType(tx, typeCode)
+	if err != nil {
+		return movementType, err
+	}
+	if !movementType.Manual || !movementType.IsActive {
+		return movementType, validationError(fmt.Sprintf("Movement type %s cannot be posted manually", typeCode))
+	}
+
+	if reasonCode == "" {
+		if movementType.RequiresReason {
+			return movementType, validationError(fmt.Sprintf("Movement type %s requires a reason code", typeCode))
+		}
+		return movementType, nil
+	}
+
+	var reason models.ReasonCode
+	if err := tx.Where("code = ? AND is_active = ?", reasonCode, true).First(&reason).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return movementType, validationError(fmt.Sprintf("Unknown reason code %s", reasonCode))
+		}
+		return movementType, err
+	}
+	return movementType, nil
+}
+
+// CreateMovementTypeRequest holds the fields for creating a movement type
+type CreateMovementTypeRequest struct {
+	Code             string `json:"code" binding:"required,max=32"`
+	Name             string `json:"name" binding:"required"`
+	Direction        string `json:"direction" binding:"required,oneof=IN OUT SET"`
+	AffectsValuation bool   `json:"affects_valuation"`
+	RequiresApproval bool   `json:"requires_approval"`
+	RequiresReason   bool   `json:"requires_reason"`
+}
+
+// UpdateMovementTypeRequest holds the fields that can be changed on a movement
+// type. Omitted flags keep their current value; direction and valuation are
+// ignored for system types.
+type UpdateMovementTypeRequest struct {
+	Name             string `json:"name" binding:"required"`
+	Direction        string `json:"direction" binding:"omitempty,oneof=IN OUT SET"`
+	AffectsValuation *bool  `json:"affects_valuation"`
+	RequiresApproval *bool  `json:"requires_approval"`
+	RequiresReason   *bool  `json:"requires_reason"`
+	IsActive         *bool  `json:"is_active"`
+}
+
+// GetMovementTypes godoc
+// @Summary Get movement types
+// @Description List the movement type catalogue
+// @Tags movement-types
+// @Produce json
+// @Security BearerAuth
+// @Param include_inactive query bool false "Include deactivated types"
+// @Success 200 {array} models.MovementType
+// @Router /movement-types [get]
+func (h *MovementCatalogHandler) GetMovementTypes(c *gin.Context) {
+	query := h.db.Model(&models.MovementType{})
+	if c.Query("include_inactive") != "true" {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var types []models.MovementType
+	query.Order("code").Find(&types)
+
+	c.JSON(http.StatusOK, types)
+}
+
+// CreateMovementType godoc
+// @Summary Create a movement type
+// @Description Add a custom manual movement type to the catalogue (requires catalog:admin)
+// @Tags movement-types
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param movement_type body CreateMovementTypeRequest true "Movement type details"
+// @Success 201 {object} models.MovementType
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /movement-types [post]
+func (h *MovementCatalogHandler) CreateMovementType(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req CreateMovementTypeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	if code == "TRANSFER" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Movement type code TRANSFER is reserved"})
+		return
+	}
+
+	// Check if code exists
+	var existing models.MovementType
+	if err := h.db.Where("code = ?", code).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Movement type code already exists"})
+		return
+	}
+	for _, t := range models.SystemMovementTypes() {
+		if t.Code == code {
+			c.JSON(http.StatusConflict, gin.H{"error": "Movement type code already exists"})
+			return
+		}
+	}
+
+	movementType := models.MovementType{
+		Code:             code,
+		Name:             req.Name,
+		Direction:        req.Direction,
+		AffectsValuation: req.AffectsValuation,
+		RequiresApproval: req.RequiresApproval,
+		RequiresReason:   req.RequiresReason,
+		Manual:           true,
+		IsActive:         true,
+		CreatedBy:        userID.(uint),
+		UpdatedBy:        userID.(uint),
+	}
+
+	if err := h.db.Create(&movementType).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create movement type"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, movementType)
+}
+
+// UpdateMovementType godoc
+// @Summary Update a movement type
+// @Description Update a movement type (requires catalog:admin). System types only accept name, approval and reason changes.
+// @Tags movement-types
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Movement type ID"
+// @Param movement_type body UpdateMovementTypeRequest true "Movement type details"
+// @Success 200 {object} models.MovementType
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /movement-types/{id} [put]
+func (h *MovementCatalogHandler) UpdateMovementType(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movement type ID"})
+		return
+	}
+
+	var req UpdateMovementTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var movementType models.MovementType
+	if err := h.db.First(&movementType, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Movement type not found"})
+		return
+	}
+
+	movementType.Name = req.Name
+	if req.RequiresApproval != nil {
+		movementType.RequiresApproval = *req.RequiresApproval
+	}
+	if req.RequiresReason != nil {
+		movementType.RequiresReason = *req.RequiresReason
+	}
+	if !movementType.IsSystem {
+		if req.Direction != "" {
+			movementType.Direction = req.Direction
+		}
+		if req.AffectsValuation != nil {
+			movementType.AffectsValuation = *req.AffectsValuation
+		}
+		if req.IsActive != nil {
+			movementType.IsActive = *req.IsActive
+		}
+	}
+	movementType.UpdatedBy = userID.(uint)
+
+	if err := h.db.Save(&movementType).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update movement type"})
+		return
+	}
+
+	c.JSON(http.StatusOK, movementType)
+}
+
+// DeleteMovementType godoc
+// @Summary Delete a movement type
+// @Description Deactivate a custom movement type (requires catalog:admin). Past movements keep their type.
+// @Tags movement-types
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Movement type ID"
+// @Success 200 {object} MessageResponse "Movement type deleted successfully"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /movement-types/{id} [delete]
+func (h *MovementCatalogHandler) DeleteMovementType(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movement type ID"})
+		return
+	}
+
+	var movementType models.MovementType
+	if err := h.db.First(&movementType, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Movement type not found"})
+		return
+	}
+	if movementType.IsSystem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "System movement types cannot be deleted"})
+		return
+	}
+
+	// Soft delete
+	if err := h.db.Model(&movementType).Update("is_active", false).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete movement type"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Movement type deleted successfully"})
+}
+
+// CreateReasonCodeRequest holds the fields for creating or updating a reason code
+type CreateReasonCodeRequest struct {
+	Code        string `json:"code" binding:"required,max=32"`
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// GetReasonCodes godoc
+// @Summary Get reason codes
+// @Description List the active reason codes
+// @Tags reason-codes
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.ReasonCode
+// @Router /reason-codes [get]
+func (h *MovementCatalogHandler) GetReasonCodes(c *gin.Context) {
+	var reasons []models.ReasonCode
+	h.db.Where("is_active = ?", true).Order("code").Find(&reasons)
+
+	c.JSON(http.StatusOK, reasons)
+}
+
+// CreateReasonCode godoc
+// @Summary Create a reason code
+// @Description Add a reason code (requires catalog:admin)
+// @Tags reason-codes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param reason_code body CreateReasonCodeRequest true "Reason code details"
+// @Success 201 {object} models.ReasonCode
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /reason-codes [post]
+func (h *MovementCatalogHandler) CreateReasonCode(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req CreateReasonCodeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+
+	// A deactivated code is brought back rather than duplicated
+	var reason models.ReasonCode
+	if err := h.db.Where("code = ?", code).First(&reason).Error; err == nil {
+		if reason.IsActive {
+			c.JSON(http.StatusConflict, gin.H{"error": "Reason code already exists"})
+			return
+		}
+	}
+
+	reason.Code = code
+	reason.Name = req.Name
+	reason.Description = req.Description
+	reason.IsActive = true
+	if reason.ID == 0 {
+		reason.CreatedBy = userID.(uint)
+	}
+	reason.UpdatedBy = userID.(uint)
+
+	if err := h.db.Save(&reason).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reason code"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, reason)
+}
+
+// UpdateReasonCode godoc
+// @Summary Update a reason code
+// @Description Update a reason code's name and description (requires catalog:admin)
+// @Tags reason-codes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Reason code ID"
+// @Param reason_code body CreateReasonCodeRequest true "Reason code details"
+// @Success 200 {object} models.ReasonCode
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /reason-codes/{id} [put]
+func (h *MovementCatalogHandler) UpdateReasonCode(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reason code ID"})
+		return
+	}
+
+	var req CreateReasonCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var reason models.ReasonCode
+	if err := h.db.First(&reason, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reason code not found"})
+		return
+	}
+
+	// The code is recorded on past movements so it cannot change
+	if strings.ToUpper(strings.TrimSpace(req.Code)) != reason.Code {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason code cannot be renamed"})
+		return
+	}
+
+	reason.Name = req.Name
+	reason.Description = req.Description
+	reason.UpdatedBy = userID.(uint)
+
+	if err := h.db.Save(&reason).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reason code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reason)
+}
+
+// DeleteReasonCode godoc
+// @Summary Delete a reason code
+// @Description Deactivate a reason code (requires catalog:admin). Past movements keep their reason.
+// @Tags reason-codes
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Reason code ID"
+// @Success 200 {object} MessageResponse "Reason code deleted successfully"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /reason-codes/{id} [delete]
+func (h *MovementCatalogHandler) DeleteReasonCode(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reason code ID"})
+		return
+	}
+
+	var reason models.ReasonCode
+	if err := h.db.First(&reason, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reason code not found"})
+		return
+	}
+
+	// Soft delete
+	if err := h.db.Model(&reason).Update("is_active", false).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete reason code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reason code deleted successfully"})
+}
@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/config"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/oidc"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const oauthNonceCookie = "oauth_nonce"
+
+// oidcProvider is a configured OpenID Connect identity provider
+type oidcProvider struct {
+	config      config.OIDCProviderConfig
+	redirectURL string
+	issuer      *oidc.Provider
+}
+
+// AuthProviderResponse is a way to sign in other than email and password
+type AuthProviderResponse struct {
+	Name     string `json:"name"`
+	LoginURL string `json:"login_url"`
+}
+
+// oidcIdentity is who the provider says signed in
+type oidcIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Role          string // mapped from the role claim, empty when the provider has no role claim
+}
+
+func newOIDCProviders(cfg *config.Config) map[string]*oidcProvider {
+	providers := map[string]*oidcProvider{}
+	for _, p := range cfg.OIDCProviders {
+		if p.Issuer == "" || p.ClientID == "" {
+			log.Printf("Warning: OIDC provider %s needs an issuer and client ID, skipping", p.Name)
+			continue
+		}
+		providers[p.Name] = &oidcProvider{
+			config:      p,
+			redirectURL: fmt.Sprintf("%s/api/v1/auth/oidc/%s/callback", cfg.FrontendURL, p.Name),
+			issuer:      oidc.NewProvider(p.Issuer, p.ClientID, nil),
+		}
+	}
+	return providers
+}
+
+// oauthConfig builds the OAuth2 client from the issuer's discovered endpoints
+func (p *oidcProvider) oauthConfig(c *gin.Context) (*oauth2.Config, error) {
+	d, err := p.issuer.Discover(c.Request.Context())
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.redirectURL,
+		Scopes:       p.config.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  d.AuthorizationEndpoint,
+			TokenURL: d.TokenEndpoint,
+		},
+	}, nil
+}
+
+// claimValues reads a claim that may be a single string or a list of strings
+func claimValues(claims jwt.MapClaims, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return strings.Fields(strings.ReplaceAll(v, ",", " "))
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// mapRole picks the user's role from the role claim: the first mapping the
+// claim contains, otherwise the default role
+func (p *oidcProvider) mapRole(claims jwt.MapClaims) string {
+	if p.config.RoleClaim == "" {
+		return ""
+	}
+	values := map[string]bool{}
+	for _, v := range claimValues(claims, p.config.RoleClaim) {
+		values[v] = true
+	}
+	for _, m := range p.config.RoleMap {
+		if values[m.Value] {
+			return m.Role
+		}
+	}
+	return p.config.DefaultRole
+}
+
+// identityFromClaims reads the standard claims of a verified ID token
+func (p *oidcProvider) identityFromClaims(claims jwt.MapClaims) oidcIdentity {
+	identity := oidcIdentity{Role: p.mapRole(claims)}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = v == "true" // some providers send it as a string
+	}
+	return identity
+}
+
+// GetAuthProviders godoc
+// @Summary Get sign-in providers
+// @Description List the external identity providers users can sign in with
+// @Tags auth
+// @Produce json
+// @Success 200 {array} AuthProviderResponse
+// @Router /auth/providers [get]
+func (h *AuthHandler) GetAuthProviders(c *gin.Context) {
+	providers := []AuthProviderResponse{}
+	if h.oauthConfig.ClientID != "" {
+		providers = append(providers, AuthProviderResponse{Name: "google", LoginURL: "/api/v1/auth/google"})
+	}
+	for _, p := range h.config.OIDCProviders {
+		if _, ok := h.providers[p.Name]; ok {
+			providers = append(providers, AuthProviderResponse{Name: p.Name, LoginURL: "/api/v1/auth/oidc/" + p.Name})
+		}
+	}
+	c.JSON(http.StatusOK, providers)
+}
+
+// OIDCLogin godoc
+// @Summary Login with an OpenID Connect provider
+// @Description Redirect to the provider's login page. State, nonce and a PKCE verifier are kept in short-lived cookies for the callback.
+// @Tags auth
+// @Param provider path string true "Provider name from GET /auth/providers"
+// @Success 307
+// @Failure 404 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Router /auth/oidc/{provider} [get]
+func (h *AuthHandler) OIDCLogin(c *gin.Context) {
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Identity provider not found"})
+		return
+	}
+
+	oauthConfig, err := provider.oauthConfig(c)
+	if err != nil {
+		log.Printf("OIDC discovery error for %s: %v", provider.config.Name, err)
+		c.JSON(http.StatusBadGateway, ErrorResponse{Error: "Identity provider is unavailable"})
+		return
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to start login"})
+		return
+	}
+	nonce, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to start login"})
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	setOAuthCookie(c, oauthStateCookie, state, oauthCookieMaxAge)
+	setOAuthCookie(c, oauthNonceCookie, nonce, oauthCookieMaxAge)
+	setOAuthCookie(c, oauthVerifierCookie, verifier, oauthCookieMaxAge)
+
+	url := oauthConfig.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("nonce", nonce))
+	c.Redirect(http.StatusTemporaryRedirect, url)
+}
+
+// OIDCCallback godoc
+// @Summary OpenID Connect callback
+// @Description Check the state cookie, exchange the code with the PKCE verifier, verify the ID token against the issuer's JWKS, then sign in the linked user, link the plain user with the same verified email, or create a new user. A new user's role comes from the provider's role claim when one is configured; existing users keep theirs unless the provider syncs roles. Accounts with any other role are never linked by email.
+// @Tags auth
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State from the login redirect"
+// @Success 200 {object} AuthResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Router /auth/oidc/{provider}/callback [get]
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	state, _ := c.Cookie(oauthStateCookie)
+	nonce, _ := c.Cookie(oauthNonceCookie)
+	verifier, _ := c.Cookie(oauthVerifierCookie)
+	// The cookies are single use
+	setOAuthCookie(c, oauthStateCookie, "", -1)
+	setOAuthCookie(c, oauthNonceCookie, "", -1)
+	setOAuthCookie(c, oauthVerifierCookie, "", -1)
+
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Identity provider not found"})
+		return
+	}
+	if errCode := c.Query("error"); errCode != "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Login failed: " + errCode})
+		return
+	}
+	if state == "" || nonce == "" || verifier == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid OAuth state"})
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Authorization code required"})
+		return
+	}
+
+	oauthConfig, err := provider.oauthConfig(c)
+	if err != nil {
+		log.Printf("OIDC discovery error for %s: %v", provider.config.Name, err)
+		c.JSON(http.StatusBadGateway, ErrorResponse{Error: "Identity provider is unavailable"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		log.Printf("OIDC token exchange error for %s: %v", provider.config.Name, err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Failed to exchange token"})
+		return
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		c.JSON(http.StatusBadGateway, ErrorResponse{Error: "Identity provider did not return an ID token"})
+		return
+	}
+
+	claims, err := provider.issuer.VerifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		log.Printf("OIDC ID token rejected for %s: %v", provider.config.Name, err)
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Invalid ID token"})
+		return
+	}
+	identity := provider.identityFromClaims(claims)
+	if identity.Subject == "" || identity.Email == "" {
+		c.JSON(http.StatusBadGateway, ErrorResponse{Error: "ID token has no subject or email, request the email scope"})
+		return
+	}
+
+	user, err := h.findOrCreateOIDCUser(provider, identity)
+	if err != nil {
+		if errors.As(err, new(forbiddenError)) {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to sign in"})
+		return
+	}
+
+	resp, err := h.startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to generate token"})
+		return
+	}
+
+	resp.Message = "Login successful"
+	c.JSON(http.StatusOK, resp)
+}
+
+// findOrCreateOIDCUser returns the user linked to a provider account. An
+// account seen for the first time is linked to the plain user with the same
+// email, but only when the provider has verified that email; otherwise a new
+// user is created with the role from the role claim.
+func (h *AuthHandler) findOrCreateOIDCUser(provider *oidcProvider, identity oidcIdentity) (models.User, error) {
+	var user models.User
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Only roles that exist can be assigned; an unknown one falls back to user
+		knownRole := func(name string) (string, error) {
+			var count int64
+			if err := tx.Model(&models.Role{}).Where("name = ?", name).Count(&count).Error; err != nil {
+				return "", err
+			}
+			if count == 0 {
+				log.Printf("Warning: OIDC provider %s maps to unknown role %q, using %q", provider.config.Name, name, models.RoleUser)
+				return models.RoleUser, nil
+			}
+			return name, nil
+		}
+		role := identity.Role
+		if role != "" {
+			var err error
+			if role, err = knownRole(role); err != nil {
+				return err
+			}
+		}
+
+		var link models.UserIdentity
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND subject = ?", provider.config.Name, identity.Subject).First(&link).Error
+		switch {
+		case err == nil:
+			if err := tx.First(&user, link.UserID).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("email = ?", identity.Email).First(&user).Error
+			switch {
+			case err == nil:
+				if !identity.EmailVerified {
+					return forbiddenError("Email is not verified by the identity provider, sign in with your password instead")
+				}
+				if err := checkEmailLink(user); err != nil {
+					return err
+				}
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				newRole := role
+				if newRole == "" {
+					if newRole, err = knownRole(provider.config.DefaultRole); err != nil {
+						return err
+					}
+				}
+				user = models.User{
+					Email:    identity.Email,
+					Name:     identity.Name,
+					Role:     newRole,
+					IsActive: true,
+				}
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+			default:
+				return err
+			}
+			link = models.UserIdentity{UserID: user.ID, Provider: provider.config.Name, Subject: identity.Subject}
+		default:
+			return err
+		}
+
+		if !user.IsActive {
+			return forbiddenError("Account is disabled")
+		}
+
+		// The role claim set the role of a new user; existing users keep
+		// theirs unless the provider is configured to sync roles
+		if provider.config.SyncRoles && role != "" && role != user.Role {
+			switch err := keepLastAdmin(tx, user, role); {
+			case err == nil:
+				user.Role = role
+			case errors.As(err, new(conflictError)):
+				log.Printf("Warning: OIDC provider %s maps the last admin %s to %q, keeping %q", provider.config.Name, user.Email, role, user.Role)
+			default:
+				return err
+			}
+		}
+		user.LastLoginAt = time.Now()
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+
+		link.Email = identity.Email
+		link.LastLoginAt = user.LastLoginAt
+		return tx.Save(&link).Error
+	})
+	return user, err
+}
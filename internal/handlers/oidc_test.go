@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/config"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	testOIDCClient = "test-client"
+	testOIDCNonce  = "test-nonce"
+	testOIDCKeyID  = "test-key"
+)
+
+// fakeIdP is an OpenID Connect provider serving discovery, a JWKS with one
+// RSA key and a token endpoint that signs whatever ID token claims are set
+type fakeIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	claims jwt.MapClaims
+	kid    string
+	signer *rsa.PrivateKey
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	idp := &fakeIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": testOIDCKeyID,
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "test-code" || r.FormValue("code_verifier") != "test-verifier" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		idToken, err := idp.idToken()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// issue sets the claims of the next ID token, signed with the published key.
+// Claims given as nil are removed from the defaults.
+func (idp *fakeIdP) issue(subject, email string, overrides jwt.MapClaims) {
+	claims := jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"aud":            testOIDCClient,
+		"sub":            subject,
+		"email":          email,
+		"email_verified": true,
+		"name":           "OIDC User",
+		"nonce":          testOIDCNonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.claims, idp.kid, idp.signer = claims, testOIDCKeyID, idp.key
+}
+
+// signWith makes the next ID token carry another key ID and signature
+func (idp *fakeIdP) signWith(kid string, key *rsa.PrivateKey) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.kid, idp.signer = kid, key
+}
+
+func (idp *fakeIdP) idToken() (string, error) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.claims)
+	token.Header["kid"] = idp.kid
+	return token.SignedString(idp.signer)
+}
+
+// newOIDCTestRouter serves the callback of a provider named "test" backed by
+// idp, mapping the groups claim "inventory-managers" to manager
+func newOIDCTestRouter(db *gorm.DB, idp *fakeIdP, syncRoles bool) *gin.Engine {
+	h := NewAuthHandler(db, nil, &config.Config{
+		JWTSecret: "oidc-test-secret",
+		OIDCProviders: []config.OIDCProviderConfig{{
+			Name:        "test",
+			Issuer:      idp.server.URL,
+			ClientID:    testOIDCClient,
+			Scopes:      []string{"openid", "email", "profile"},
+			RoleClaim:   "groups",
+			RoleMap:     []config.RoleMapping{{Value: "inventory-managers", Role: models.RoleManager}},
+			DefaultRole: models.RoleUser,
+			SyncRoles:   syncRoles,
+		}},
+	})
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/auth/oidc/:provider/callback", h.OIDCCallback)
+	return router
+}
+
+func oidcCallback(router *gin.Engine) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/test/callback?code=test-code&state=test-state", nil)
+	req.AddCookie(&http.Cookie{Name: oauthStateCookie, Value: "test-state"})
+	req.AddCookie(&http.Cookie{Name: oauthNonceCookie, Value: testOIDCNonce})
+	req.AddCookie(&http.Cookie{Name: oauthVerifierCookie, Value: "test-verifier"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestOIDCCallbackRejectsInvalidIDTokens checks that ID tokens for another
+// login, another client or signed with an unknown key never reach sign-in
+func TestOIDCCallbackRejectsInvalidIDTokens(t *testing.T) {
+	idp := newFakeIdP(t)
+	// No database: a token that got past verification would panic looking up the user
+	router := newOIDCTestRouter(nil, idp, false)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		setup func()
+	}{
+		{"nonce mismatch", func() { idp.issue("subject", "oidc@example.com", jwt.MapClaims{"nonce": "other-nonce"}) }},
+		{"missing nonce", func() { idp.issue("subject", "oidc@example.com", jwt.MapClaims{"nonce": nil}) }},
+		{"wrong audience", func() { idp.issue("subject", "oidc@example.com", jwt.MapClaims{"aud": "another-client"}) }},
+		{"wrong issuer", func() { idp.issue("subject", "oidc@example.com", jwt.MapClaims{"iss": "https://evil.example.com"}) }},
+		{"expired", func() {
+			idp.issue("subject", "oidc@example.com", jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})
+		}},
+		{"unknown key ID", func() {
+			idp.issue("subject", "oidc@example.com", nil)
+			idp.signWith("unknown-key", otherKey)
+		}},
+		{"known key ID, wrong key", func() {
+			idp.issue("subject", "oidc@example.com", nil)
+			idp.signWith(testOIDCKeyID, otherKey)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			if w := oidcCallback(router); w.Code != http.StatusUnauthorized {
+				t.Errorf("status %d, want 401: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
+
+// TestOIDCCallbackLogin signs in through the fake provider and checks how
+// users are created, linked and given roles
+func TestOIDCCallbackLogin(t *testing.T) {
+	db := openTestDB(t)
+	idp := newFakeIdP(t)
+	router := newOIDCTestRouter(db, idp, false)
+
+	login := func(t *testing.T, router *gin.Engine) AuthResponse {
+		t.Helper()
+		w := oidcCallback(router)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+		}
+		var resp AuthResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.Token == "" || resp.User == nil {
+			t.Fatalf("no token or user: %s", w.Body)
+		}
+		return resp
+	}
+
+	t.Run("new user gets the mapped role once", func(t *testing.T) {
+		subject, email := uniqueName("sub"), uniqueName("oidc")+"@example.com"
+		idp.issue(subject, email, jwt.MapClaims{"groups": []string{"inventory-managers"}})
+		resp := login(t, router)
+		if resp.User.Email != email || resp.User.Role != models.RoleManager {
+			t.Fatalf("got %s as %q, want %s as manager", resp.User.Email, resp.User.Role, email)
+		}
+
+		// The group is gone at the provider, but roles are not synced
+		idp.issue(subject, email, nil)
+		if resp := login(t, router); resp.User.Role != models.RoleManager {
+			t.Errorf("role changed to %q without sync_roles", resp.User.Role)
+		}
+
+		// With sync_roles the provider's answer wins
+		if resp := login(t, newOIDCTestRouter(db, idp, true)); resp.User.Role != models.RoleUser {
+			t.Errorf("role = %q with sync_roles, want user", resp.User.Role)
+		}
+	})
+
+	t.Run("links a plain user with the same verified email", func(t *testing.T) {
+		existing := createTestUser(t, db, models.RoleUser)
+		idp.issue(uniqueName("sub"), existing.Email, jwt.MapClaims{"groups": []string{"inventory-managers"}})
+		resp := login(t, router)
+		if resp.User.ID != existing.ID {
+			t.Fatalf("signed in as user %d, want %d", resp.User.ID, existing.ID)
+		}
+		if resp.User.Role != models.RoleUser {
+			t.Errorf("linking changed the role to %q", resp.User.Role)
+		}
+	})
+
+	t.Run("does not link an unverified email", func(t *testing.T) {
+		existing := createTestUser(t, db, models.RoleUser)
+		idp.issue(uniqueName("sub"), existing.Email, jwt.MapClaims{"email_verified": false})
+		if w := oidcCallback(router); w.Code != http.StatusForbidden {
+			t.Errorf("status %d, want 403: %s", w.Code, w.Body)
+		}
+	})
+
+	t.Run("does not link a privileged account", func(t *testing.T) {
+		for _, role := range []string{models.RoleAdmin, models.RoleManager} {
+			existing := createTestUser(t, db, role)
+			subject := uniqueName("sub")
+			idp.issue(subject, existing.Email, nil)
+			if w := oidcCallback(router); w.Code != http.StatusForbidden {
+				t.Errorf("%s: status %d, want 403: %s", role, w.Code, w.Body)
+			}
+			var links int64
+			db.Model(&models.UserIdentity{}).Where("provider = ? AND subject = ?", "test", subject).Count(&links)
+			if links != 0 {
+				t.Errorf("%s: provider account linked to %s", role, existing.Email)
+			}
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,33 +12,39 @@ import (
 	"github.com/impk123/Inventory-Management-Mini-System/pkg/cache"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ProductHandler holds dependencies for product handling
 type ProductHandler struct {
-	db    *gorm.DB
-	cache *redis.Client
+	db     *gorm.DB
+	cache  *redis.Client
+	ledger LedgerSettings
 }
 
-func NewProductHandler(db *gorm.DB, cache *redis.Client) *ProductHandler {
+func NewProductHandler(db *gorm.DB, cache *redis.Client, ledger LedgerSettings) *ProductHandler {
 	return &ProductHandler{
-		db:    db,
-		cache: cache,
+		db:     db,
+		cache:  cache,
+		ledger: ledger,
 	}
 }
 
 // CreateProduct creates a new product
 type CreateProductRequest struct {
-	SKU         string  `json:"sku" binding:"required"`
-	Name        string  `json:"name" binding:"required"`
-	Description string  `json:"description"`
-	Category    string  `json:"category"`
-	UnitPrice   float64 `json:"unit_price" binding:"required,gt=0"`
-	CostPrice   float64 `json:"cost_price"`
-	Quantity    int     `json:"quantity" binding:"min=0"`
-	MinQuantity int     `json:"min_quantity" binding:"min=0"`
-	MaxQuantity int     `json:"max_quantity" binding:"min=0"`
-	Location    string  `json:"location"`
+	SKU           string  `json:"sku" binding:"required"`
+	Name          string  `json:"name" binding:"required"`
+	Description   string  `json:"description"`
+	Category      string  `json:"category"`
+	UnitPrice     float64 `json:"unit_price" binding:"required,gt=0"`
+	CostPrice     float64 `json:"cost_price"`
+	Quantity      int     `json:"quantity" binding:"min=0"`
+	MinQuantity   int     `json:"min_quantity" binding:"min=0"`
+	MaxQuantity   int     `json:"max_quantity" binding:"min=0"`
+	Location      string  `json:"location"`
+	WarehouseID   uint    `json:"warehouse_id"` // where the opening quantity is held, defaults to the default warehouse
+	TrackingMode  string  `json:"tracking_mode" binding:"omitempty,oneof=none lot serial"`
+	CostingMethod string  `json:"costing_method" binding:"omitempty,oneof=FIFO AVERAGE STANDARD"` // empty uses the global default
 }
 
 // CreateProduct godoc
@@ -69,23 +76,63 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
+	if req.TrackingMode == "" {
+		req.TrackingMode = models.TrackingModeNone
+	}
+
+	// Tracked stock has to come in through the ledger with its lot or serials
+	if req.TrackingMode != models.TrackingModeNone && req.Quantity > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Opening quantity must be 0 for lot or serial tracked products"})
+		return
+	}
+
 	product := models.Product{
-		SKU:         req.SKU,
-		Name:        req.Name,
-		Description: req.Description,
-		Category:    req.Category,
-		UnitPrice:   req.UnitPrice,
-		CostPrice:   req.CostPrice,
-		Quantity:    req.Quantity,
-		MinQuantity: req.MinQuantity,
-		MaxQuantity: req.MaxQuantity,
-		Location:    req.Location,
-		IsActive:    true,
-		CreatedBy:   userID.(uint),
-		UpdatedBy:   userID.(uint),
+		SKU:           req.SKU,
+		Name:          req.Name,
+		Description:   req.Description,
+		Category:      req.Category,
+		UnitPrice:     req.UnitPrice,
+		CostPrice:     req.CostPrice,
+		MinQuantity:   req.MinQuantity,
+		MaxQuantity:   req.MaxQuantity,
+		Location:      req.Location,
+		TrackingMode:  req.TrackingMode,
+		CostingMethod: req.CostingMethod,
+		IsActive:      true,
+		CreatedBy:     userID.(uint),
+		UpdatedBy:     userID.(uint),
 	}
 
-	if err := h.db.Create(&product).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		if err := recordEvent(tx, models.EventProductCreated, "product", product.ID, product, userID.(uint)); err != nil {
+			return err
+		}
+
+		if req.Quantity == 0 {
+			return nil
+		}
+
+		// Opening quantity goes through the ledger so it is costed and has history
+		_, err := postStockMovement(tx, h.ledger, stockMovementInput{
+			ProductID:   product.ID,
+			WarehouseID: req.WarehouseID,
+			Type:        "IN",
+			Quantity:    req.Quantity,
+			UnitCost:    req.CostPrice,
+			Notes:       "Opening balance",
+			UserID:      userID.(uint),
+		})
+		return err
+	})
+
+	if errors.Is(err, errWarehouseNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
 		return
 	}
@@ -214,6 +261,7 @@ func (h *ProductHandler) GetProductByID(c *gin.Context) {
 // @Success 200 {object} MessageResponse "Product updated successfully"
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /products/{id} [put]
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
@@ -236,17 +284,52 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	product.Name = req.Name
-	product.Description = req.Description
-	product.Category = req.Category
-	product.UnitPrice = req.UnitPrice
-	product.CostPrice = req.CostPrice
-	product.MinQuantity = req.MinQuantity
-	product.MaxQuantity = req.MaxQuantity
-	product.Location = req.Location
-	product.UpdatedBy = userID.(uint)
-
-	if err := h.db.Save(&product).Error; err != nil {
+	// Only the fields the request carries are written, so columns owned elsewhere
+	// (quantity and average cost by the stock ledger, abc_class by the cycle count classification,
+	// is_active by delete) keep their current values
+	updates := map[string]interface{}{
+		"name":         req.Name,
+		"description":  req.Description,
+		"category":     req.Category,
+		"unit_price":   req.UnitPrice,
+		"cost_price":   req.CostPrice,
+		"min_quantity": req.MinQuantity,
+		"max_quantity": req.MaxQuantity,
+		"location":     req.Location,
+		"updated_by":   userID.(uint),
+	}
+	// An omitted costing method keeps the product's current one
+	if req.CostingMethod != "" {
+		updates["costing_method"] = req.CostingMethod
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// Tracking mode can only change while nothing is on hand; lock the row so
+		// no movement can post between the check and the update
+		if req.TrackingMode != "" && req.TrackingMode != product.TrackingMode {
+			var locked models.Product
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, product.ID).Error; err != nil {
+				return err
+			}
+			if locked.Quantity != 0 {
+				return conflictError("Tracking mode can only be changed when the product has no stock")
+			}
+			updates["tracking_mode"] = req.TrackingMode
+		}
+
+		if err := tx.Model(&models.Product{}).Where("id = ?", product.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&product, product.ID).Error; err != nil {
+			return err
+		}
+		return recordEvent(tx, models.EventProductUpdated, "product", product.ID, product, userID.(uint))
+	})
+	if errors.As(err, new(conflictError)) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
 		return
 	}
@@ -274,6 +357,7 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 // @Failure 500 {object} ErrorResponse
 // @Router /products/{id} [delete]
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	userID, _ := c.Get("userID")
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
@@ -288,7 +372,13 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 
 	// Soft delete
 	product.IsActive = false
-	if err := h.db.Save(&product).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&product).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		return recordEvent(tx, models.EventProductDeleted, "product", product.ID, product, userID.(uint))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product"})
 		return
 	}
@@ -0,0 +1,659 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	errPurchaseOrderNotFound = notFoundError("Purchase order not found")
+	errSupplierNotFound      = notFoundError("Supplier not found")
+)
+
+// receiptToleranceUnits is the tolerance for a line in whole units, given the
+// tolerance in percent of the ordered quantity
+func receiptToleranceUnits(ordered int, percent float64) int {
+	return int(math.Floor(float64(ordered) * percent / 100))
+}
+
+// PurchaseOrderHandler holds dependencies for purchase order handling
+type PurchaseOrderHandler struct {
+	db     *gorm.DB
+	cache  *redis.Client
+	ledger LedgerSettings
+}
+
+func NewPurchaseOrderHandler(db *gorm.DB, cache *redis.Client, ledger LedgerSettings) *PurchaseOrderHandler {
+	return &PurchaseOrderHandler{
+		db:     db,
+		cache:  cache,
+		ledger: ledger,
+	}
+}
+
+// PurchaseOrderLineRequest is one product ordered from the supplier
+type PurchaseOrderLineRequest struct {
+	ProductID uint    `json:"product_id" binding:"required"`
+	Quantity  int     `json:"quantity" binding:"required,gt=0"`
+	UnitCost  float64 `json:"unit_cost" binding:"min=0"`
+}
+
+// CreatePurchaseOrderRequest holds the fields for creating or updating a draft purchase order
+type CreatePurchaseOrderRequest struct {
+	SupplierID   uint                       `json:"supplier_id" binding:"required"`
+	WarehouseID  uint                       `json:"warehouse_id"` // optional, defaults to the default warehouse
+	ExpectedDate *time.Time                 `json:"expected_date"`
+	Notes        string                     `json:"notes"`
+	Lines        []PurchaseOrderLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+// ReceivePurchaseOrderLineRequest is the quantity of a purchase order line arriving
+type ReceivePurchaseOrderLineRequest struct {
+	LineID   uint    `json:"line_id" binding:"required"`
+	Quantity int     `json:"quantity" binding:"required,gt=0"`
+	UnitCost float64 `json:"unit_cost" binding:"min=0"` // optional, defaults to the ordered unit cost
+
+	// Lot and serial details of the goods received, as on a stock IN
+	LotNumber       string     `json:"lot_number"`
+	ManufactureDate *time.Time `json:"manufacture_date"`
+	ExpiryDate      *time.Time `json:"expiry_date"`
+	Serials         []string   `json:"serials" binding:"omitempty,dive,required"`
+}
+
+// ReceivePurchaseOrderRequest records a (possibly partial) delivery
+type ReceivePurchaseOrderRequest struct {
+	Lines []ReceivePurchaseOrderLineRequest `json:"lines" binding:"required,min=1,dive"`
+	Notes string                            `json:"notes"`
+}
+
+// ClosePurchaseOrderRequest closes or cancels a purchase order
+type ClosePurchaseOrderRequest struct {
+	Notes string `json:"notes"`
+}
+
+// buildPurchaseOrderLines checks the supplier and products and returns the lines to store
+func buildPurchaseOrderLines(tx *gorm.DB, req CreatePurchaseOrderRequest) ([]models.PurchaseOrderLine, error) {
+	var supplier models.Supplier
+	if err := tx.Where("id = ? AND is_active = ?", req.SupplierID, true).First(&supplier).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errSupplierNotFound
+		}
+		return nil, err
+	}
+
+	lines := make([]models.PurchaseOrderLine, 0, len(req.Lines))
+	seen := make(map[uint]bool, len(req.Lines))
+	for _, l := range req.Lines {
+		if seen[l.ProductID] {
+			return nil, validationError(fmt.Sprintf("Product %d appears on more than one line", l.ProductID))
+		}
+		seen[l.ProductID] = true
+
+		var product models.Product
+		if err := tx.Where("id = ? AND is_active = ?", l.ProductID, true).First(&product).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errProductNotFound
+			}
+			return nil, err
+		}
+
+		unitCost := l.UnitCost
+		if unitCost == 0 {
+			unitCost = product.CostPrice
+		}
+		lines = append(lines, models.PurchaseOrderLine{
+			ProductID:       l.ProductID,
+			QuantityOrdered: l.Quantity,
+			UnitCost:        unitCost,
+		})
+	}
+	return lines, nil
+}
+
+// createPurchaseOrder stores a draft purchase order and assigns its number
+func createPurchaseOrder(tx *gorm.DB, req CreatePurchaseOrderRequest, userID uint) (*models.PurchaseOrder, error) {
+	lines, err := buildPurchaseOrderLines(tx, req)
+	if err != nil {
+		return nil, err
+	}
+	warehouse, err := resolveWarehouse(tx, req.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+
+	order := models.PurchaseOrder{
+		SupplierID:   req.SupplierID,
+		WarehouseID:  warehouse.ID,
+		Status:       models.PurchaseOrderStatusDraft,
+		ExpectedDate: req.ExpectedDate,
+		Notes:        req.Notes,
+		CreatedBy:    userID,
+		UpdatedBy:    userID,
+		Lines:        lines,
+	}
+	if err := tx.Create(&order).Error; err != nil {
+		return nil, err
+	}
+
+	order.PONumber = fmt.Sprintf("PO-%06d", order.ID)
+	if err := tx.Model(&order).Update("po_number", order.PONumber).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// loadPurchaseOrder locks a purchase order and reads its lines (ordered by product) inside tx
+func loadPurchaseOrder(tx *gorm.DB, id int) (*models.PurchaseOrder, error) {
+	var order models.PurchaseOrder
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("product_id, id") }).
+		First(&order, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errPurchaseOrderNotFound
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// refreshPurchaseOrderStatus moves an open order between APPROVED and
+// PARTIALLY_RECEIVED, and closes it once every line is received to within
+// tolerance percent
+func refreshPurchaseOrderStatus(order *models.PurchaseOrder, tolerance float64) {
+	received := 0
+	complete := true
+	for _, l := range order.Lines {
+		received += l.QuantityReceived
+		if l.QuantityReceived < l.QuantityOrdered-receiptToleranceUnits(l.QuantityOrdered, tolerance) {
+			complete = false
+		}
+	}
+
+	switch {
+	case complete:
+		now := time.Now()
+		order.Status = models.PurchaseOrderStatusClosed
+		order.ClosedAt = &now
+	case received > 0:
+		order.Status = models.PurchaseOrderStatusPartiallyReceived
+	default:
+		order.Status = models.PurchaseOrderStatusApproved
+	}
+}
+
+// unreceivePurchaseOrderLine takes a reversed receipt off its purchase order
+// line. An order that is still open drops back to the matching status; a
+// closed order stays closed.
+func unreceivePurchaseOrderLine(tx *gorm.DB, ledger LedgerSettings, lineID uint, quantity int, userID uint) error {
+	var line models.PurchaseOrderLine
+	if err := tx.First(&line, lineID).Error; err != nil {
+		return err
+	}
+
+	order, err := loadPurchaseOrder(tx, int(line.PurchaseOrderID))
+	if err != nil {
+		return err
+	}
+
+	for i := range order.Lines {
+		if order.Lines[i].ID != lineID {
+			continue
+		}
+		order.Lines[i].QuantityReceived = max(order.Lines[i].QuantityReceived-quantity, 0)
+		if err := tx.Save(&order.Lines[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	if order.Status != models.PurchaseOrderStatusPartiallyReceived {
+		return nil
+	}
+	refreshPurchaseOrderStatus(order, ledger.ReceiptTolerance)
+	order.UpdatedBy = userID
+	return tx.Omit("Lines").Save(order).Error
+}
+
+// CreatePurchaseOrder godoc
+// @Summary Create a purchase order
+// @Description Create a draft purchase order for a supplier
+// @Tags purchase-orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param order body CreatePurchaseOrderRequest true "Purchase order details"
+// @Success 201 {object} models.PurchaseOrder
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /purchase-orders [post]
+func (h *PurchaseOrderHandler) CreatePurchaseOrder(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req CreatePurchaseOrderRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var order *models.PurchaseOrder
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = createPurchaseOrder(tx, req, userID.(uint))
+		return err
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, order)
+}
+
+// GetPurchaseOrders godoc
+// @Summary Get purchase orders
+// @Description List purchase orders, optionally filtered by status or supplier
+// @Tags purchase-orders
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Param status query string false "DRAFT, APPROVED, PARTIALLY_RECEIVED, CLOSED or CANCELLED"
+// @Param supplier_id query int false "Supplier filter"
+// @Success 200 {object} map[string]interface{}
+// @Router /purchase-orders [get]
+func (h *PurchaseOrderHandler) GetPurchaseOrders(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	status := c.Query("status")
+	supplierID := c.Query("supplier_id")
+	offset := (page - 1) * limit
+
+	query := h.db.Model(&models.PurchaseOrder{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if supplierID != "" {
+		query = query.Where("supplier_id = ?", supplierID)
+	}
+
+	var total int64
+	var orders []models.PurchaseOrder
+	query.Count(&total)
+	query.Preload("Lines").Preload("Supplier").Offset(offset).Limit(limit).Order("created_at DESC").Find(&orders)
+
+	c.JSON(http.StatusOK, gin.H{
+		"purchase_orders": orders,
+		"total":           total,
+		"page":            page,
+		"limit":           limit,
+	})
+}
+
+// GetPurchaseOrderByID godoc
+// @Summary Get a purchase order
+// @Description Get a purchase order with its lines and the receipts posted against it
+// @Tags purchase-orders
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Purchase order ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /purchase-orders/{id} [get]
+func (h *PurchaseOrderHandler) GetPurchaseOrderByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase order ID"})
+		return
+	}
+
+	var order models.PurchaseOrder
+	if err := h.db.Preload("Lines.Product").Preload("Supplier").Preload("Warehouse").
+		First(&order, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+		return
+	}
+
+	lineIDs := make([]uint, 0, len(order.Lines))
+	for _, l := range order.Lines {
+		lineIDs = append(lineIDs, l.ID)
+	}
+
+	// Receipts and their reversals
+	var movements []models.Stock
+	h.db.Preload("Lots.Lot").Preload("Serials.Serial").Where("po_line_id IN ?", lineIDs).Order("created_at").Find(&movements)
+
+	c.JSON(http.StatusOK, gin.H{
+		"purchase_order": order,
+		"movements":      movements,
+	})
+}
+
+// UpdatePurchaseOrder godoc
+// @Summary Update a purchase order
+// @Description Replace the details and lines of a draft purchase order
+// @Tags purchase-orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Purchase order ID"
+// @Param order body CreatePurchaseOrderRequest true "Purchase order details"
+// @Success 200 {object} models.PurchaseOrder
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /purchase-orders/{id} [put]
+func (h *PurchaseOrderHandler) UpdatePurchaseOrder(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase order ID"})
+		return
+	}
+
+	var req CreatePurchaseOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var order *models.PurchaseOrder
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = loadPurchaseOrder(tx, id)
+		if err != nil {
+			return err
+		}
+		if order.Status != models.PurchaseOrderStatusDraft {
+			return conflictError("Only draft purchase orders can be changed")
+		}
+
+		lines, err := buildPurchaseOrderLines(tx, req)
+		if err != nil {
+			return err
+		}
+		warehouse, err := resolveWarehouse(tx, req.WarehouseID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("purchase_order_id = ?", order.ID).Delete(&models.PurchaseOrderLine{}).Error; err != nil {
+			return err
+		}
+		for i := range lines {
+			lines[i].PurchaseOrderID = order.ID
+		}
+		if err := tx.Create(&lines).Error; err != nil {
+			return err
+		}
+
+		order.SupplierID = req.SupplierID
+		order.WarehouseID = warehouse.ID
+		order.ExpectedDate = req.ExpectedDate
+		order.Notes = req.Notes
+		order.UpdatedBy = userID.(uint)
+		order.Lines = lines
+		return tx.Omit("Lines").Save(order).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// ApprovePurchaseOrder godoc
+// @Summary Approve a purchase order
+// @Description Approve a draft purchase order so it can be received
+// @Tags purchase-orders
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Purchase order ID"
+// @Success 200 {object} models.PurchaseOrder
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /purchase-orders/{id}/approve [post]
+func (h *PurchaseOrderHandler) ApprovePurchaseOrder(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase order ID"})
+		return
+	}
+
+	var order *models.PurchaseOrder
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = loadPurchaseOrder(tx, id)
+		if err != nil {
+			return err
+		}
+		if order.Status != models.PurchaseOrderStatusDraft {
+			return conflictError("Only draft purchase orders can be approved")
+		}
+
+		now := time.Now()
+		approver := userID.(uint)
+		order.Status = models.PurchaseOrderStatusApproved
+		order.ApprovedBy = &approver
+		order.ApprovedAt = &now
+		order.UpdatedBy = approver
+		return tx.Omit("Lines").Save(order).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// ReceivePurchaseOrder godoc
+// @Summary Receive a purchase order
+// @Description Receive goods against an approved purchase order, posting an IN movement per line referenced by the PO number
+// @Tags purchase-orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Purchase order ID"
+// @Param receipt body ReceivePurchaseOrderRequest true "Received quantities"
+// @Success 200 {object} models.PurchaseOrder
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /purchase-orders/{id}/receive [post]
+func (h *PurchaseOrderHandler) ReceivePurchaseOrder(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase order ID"})
+		return
+	}
+
+	var req ReceivePurchaseOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var order *models.PurchaseOrder
+	var productIDs []uint
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = loadPurchaseOrder(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if order.Status != models.PurchaseOrderStatusApproved && order.Status != models.PurchaseOrderStatusPartiallyReceived {
+			return conflictError("Purchase order is not open for receiving")
+		}
+
+		lineIndex := make(map[uint]int, len(order.Lines))
+		for i, l := range order.Lines {
+			lineIndex[l.ID] = i
+		}
+
+		// Post in product order so locks are taken in a stable order
+		receipts := append([]ReceivePurchaseOrderLineRequest(nil), req.Lines...)
+		for _, r := range receipts {
+			if _, ok := lineIndex[r.LineID]; !ok {
+				return validationError(fmt.Sprintf("Line %d does not belong to this purchase order", r.LineID))
+			}
+		}
+		sort.SliceStable(receipts, func(i, j int) bool { return lineIndex[receipts[i].LineID] < lineIndex[receipts[j].LineID] })
+
+		for _, r := range receipts {
+			line := &order.Lines[lineIndex[r.LineID]]
+
+			limit := line.QuantityOrdered + receiptToleranceUnits(line.QuantityOrdered, h.ledger.ReceiptTolerance)
+			if line.QuantityReceived+r.Quantity > limit {
+				return validationError(fmt.Sprintf("Line %d would be over-received: ordered %d, received %d, at most %d accepted",
+					line.ID, line.QuantityOrdered, line.QuantityReceived, limit))
+			}
+
+			unitCost := r.UnitCost
+			if unitCost == 0 {
+				unitCost = line.UnitCost
+			}
+
+			if _, err := postStockMovement(tx, h.ledger, stockMovementInput{
+				ProductID:       line.ProductID,
+				WarehouseID:     order.WarehouseID,
+				Type:            "IN",
+				Quantity:        r.Quantity,
+				Reference:       order.PONumber,
+				UnitCost:        unitCost,
+				POLineID:        &line.ID,
+				Notes:           req.Notes,
+				UserID:          userID.(uint),
+				LotNumber:       r.LotNumber,
+				ManufactureDate: r.ManufactureDate,
+				ExpiryDate:      r.ExpiryDate,
+				Serials:         r.Serials,
+			}); err != nil {
+				return err
+			}
+
+			line.QuantityReceived += r.Quantity
+			if err := tx.Save(line).Error; err != nil {
+				return err
+			}
+			productIDs = append(productIDs, line.ProductID)
+		}
+
+		refreshPurchaseOrderStatus(order, h.ledger.ReceiptTolerance)
+		order.UpdatedBy = userID.(uint)
+		return tx.Omit("Lines").Save(order).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	invalidateStockCaches(h.cache, c.Request.Context(), productIDs...)
+
+	c.JSON(http.StatusOK, order)
+}
+
+// ClosePurchaseOrder godoc
+// @Summary Close a purchase order
+// @Description Short-close an open purchase order; anything not yet received is no longer expected
+// @Tags purchase-orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Purchase order ID"
+// @Param close body ClosePurchaseOrderRequest false "Closing notes"
+// @Success 200 {object} models.PurchaseOrder
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /purchase-orders/{id}/close [post]
+func (h *PurchaseOrderHandler) ClosePurchaseOrder(c *gin.Context) {
+	h.finishPurchaseOrder(c, models.PurchaseOrderStatusClosed)
+}
+
+// CancelPurchaseOrder godoc
+// @Summary Cancel a purchase order
+// @Description Cancel a draft or approved purchase order that has not been received against
+// @Tags purchase-orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Purchase order ID"
+// @Param close body ClosePurchaseOrderRequest false "Cancellation notes"
+// @Success 200 {object} models.PurchaseOrder
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /purchase-orders/{id}/cancel [post]
+func (h *PurchaseOrderHandler) CancelPurchaseOrder(c *gin.Context) {
+	h.finishPurchaseOrder(c, models.PurchaseOrderStatusCancelled)
+}
+
+// finishPurchaseOrder moves a purchase order to CLOSED or CANCELLED
+func (h *PurchaseOrderHandler) finishPurchaseOrder(c *gin.Context, status string) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase order ID"})
+		return
+	}
+
+	var req ClosePurchaseOrderRequest
+	_ = c.ShouldBindJSON(&req)
+
+	var order *models.PurchaseOrder
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = loadPurchaseOrder(tx, id)
+		if err != nil {
+			return err
+		}
+
+		switch status {
+		case models.PurchaseOrderStatusClosed:
+			if order.Status != models.PurchaseOrderStatusApproved && order.Status != models.PurchaseOrderStatusPartiallyReceived {
+				return conflictError("Only approved or partially received purchase orders can be closed")
+			}
+		case models.PurchaseOrderStatusCancelled:
+			if order.Status != models.PurchaseOrderStatusDraft && order.Status != models.PurchaseOrderStatusApproved {
+				return conflictError("Only draft or approved purchase orders can be cancelled")
+			}
+		}
+
+		now := time.Now()
+		order.Status = status
+		order.ClosedAt = &now
+		if req.Notes != "" {
+			order.Notes = req.Notes
+		}
+		order.UpdatedBy = userID.(uint)
+		return tx.Omit("Lines").Save(order).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
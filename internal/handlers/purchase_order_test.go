@@ -0,0 +1,24 @@
+package handlers
+
+import "testing"
+
+func TestReceiptToleranceUnits(t *testing.T) {
+	tests := []struct {
+		ordered int
+		percent float64
+		want    int
+	}{
+		{100, 5, 5},
+		{10, 5, 0},  // half a unit rounds down
+		{19, 10, 1}, // 1.9 units
+		{20, 10, 2},
+		{7, 0, 0},
+		{0, 5, 0},
+		{3, 100, 3},
+	}
+	for _, tt := range tests {
+		if got := receiptToleranceUnits(tt.ordered, tt.percent); got != tt.want {
+			t.Errorf("receiptToleranceUnits(%d, %v) = %d, want %d", tt.ordered, tt.percent, got, tt.want)
+		}
+	}
+}
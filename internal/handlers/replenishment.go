@@ -0,0 +1,455 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const errReplenishmentRunNotFound = notFoundError("Replenishment run not found")
+
+// ReplenishmentHandler holds dependencies for replenishment handling
+type ReplenishmentHandler struct {
+	db *gorm.DB
+}
+
+func NewReplenishmentHandler(db *gorm.DB) *ReplenishmentHandler {
+	return &ReplenishmentHandler{
+		db: db,
+	}
+}
+
+// CreateReplenishmentRunRequest selects the products to check. With no
+// filters every active product with a reorder point or maximum is checked.
+type CreateReplenishmentRunRequest struct {
+	WarehouseID uint   `json:"warehouse_id"` // optional, defaults to the default warehouse
+	ProductIDs  []uint `json:"product_ids"`
+	Category    string `json:"category"`
+	Notes       string `json:"notes"`
+}
+
+// ReplenishmentOrderLineRequest accepts one suggestion, optionally with a different quantity
+type ReplenishmentOrderLineRequest struct {
+	SuggestionID uint `json:"suggestion_id" binding:"required"`
+	Quantity     int  `json:"quantity" binding:"min=0"` // optional, defaults to the suggested quantity
+}
+
+// CreateReplenishmentOrdersRequest turns accepted suggestions into draft
+// purchase orders. With no lines every orderable suggestion is accepted.
+type CreateReplenishmentOrdersRequest struct {
+	Lines []ReplenishmentOrderLineRequest `json:"lines" binding:"omitempty,dive"`
+	Notes string                          `json:"notes"`
+}
+
+// replenishmentQuantity is what to order to bring the stock position up to
+// target, raised to the supplier's minimum order and rounded up to whole packs
+func replenishmentQuantity(position, target int, item *models.SupplierProduct) int {
+	quantity := max(target-position, 1)
+	if item == nil {
+		return quantity
+	}
+	quantity = max(quantity, item.MinOrderQty)
+	if item.PackSize > 1 {
+		quantity = (quantity + item.PackSize - 1) / item.PackSize * item.PackSize
+	}
+	return quantity
+}
+
+// pickSupplierProduct chooses who to order from: the preferred supplier,
+// otherwise the cheapest, using the product's cost price where none is agreed
+func pickSupplierProduct(items []models.SupplierProduct, product models.Product) *models.SupplierProduct {
+	if len(items) == 0 {
+		return nil
+	}
+	cost := func(sp models.SupplierProduct) float64 {
+		if sp.UnitCost > 0 {
+			return sp.UnitCost
+		}
+		return product.CostPrice
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].IsPreferred != items[j].IsPreferred {
+			return items[i].IsPreferred
+		}
+		if cost(items[i]) != cost(items[j]) {
+			return cost(items[i]) < cost(items[j])
+		}
+		return items[i].SupplierID < items[j].SupplierID
+	})
+	item := items[0]
+	item.UnitCost = cost(item)
+	return &item
+}
+
+// runReplenishment compares each product's stock position (available to
+// promise plus open purchase orders and transfers in transit, across all
+// warehouses) with its reorder point, and stores a suggestion for every
+// product at or below it
+func runReplenishment(tx *gorm.DB, warehouseID uint, products []models.Product, notes string, userID uint) (*models.ReplenishmentRun, error) {
+	warehouse, err := resolveWarehouse(tx, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+
+	productIDs := make([]uint, 0, len(products))
+	for _, p := range products {
+		productIDs = append(productIDs, p.ID)
+	}
+
+	var available []ProductQuantity
+	var onOrder []ProductQuantity
+	var inTransit []ProductQuantity
+	var items []models.SupplierProduct
+	if len(productIDs) > 0 {
+		if err := tx.Table("stock_balances").
+			Select("product_id, SUM(quantity - reserved_quantity - quarantined_quantity) AS quantity").
+			Where("product_id IN ?", productIDs).
+			Group("product_id").
+			Scan(&available).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Table("purchase_order_lines").
+			Select("purchase_order_lines.product_id, SUM(GREATEST(purchase_order_lines.quantity_ordered - purchase_order_lines.quantity_received, 0)) AS quantity").
+			Joins("JOIN purchase_orders ON purchase_orders.id = purchase_order_lines.purchase_order_id").
+			Where("purchase_orders.status IN ?", []string{
+				models.PurchaseOrderStatusDraft,
+				models.PurchaseOrderStatusApproved,
+				models.PurchaseOrderStatusPartiallyReceived,
+			}).
+			Where("purchase_order_lines.product_id IN ?", productIDs).
+			Group("purchase_order_lines.product_id").
+			Scan(&onOrder).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Table("stock_transfer_lines").
+			Select("stock_transfer_lines.product_id, SUM(stock_transfer_lines.quantity_shipped - stock_transfer_lines.quantity_received - stock_transfer_lines.quantity_discrepancy) AS quantity").
+			Joins("JOIN stock_transfers ON stock_transfers.id = stock_transfer_lines.transfer_id").
+			Where("stock_transfers.status IN ?", []string{models.TransferStatusInTransit, models.TransferStatusPartiallyReceived}).
+			Where("stock_transfer_lines.product_id IN ?", productIDs).
+			Group("stock_transfer_lines.product_id").
+			Scan(&inTransit).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Joins("JOIN suppliers ON suppliers.id = supplier_products.supplier_id").
+			Where("suppliers.is_active = ? AND supplier_products.product_id IN ?", true, productIDs).
+			Find(&items).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	availableBy := make(map[uint]int, len(available))
+	for _, q := range available {
+		availableBy[q.ProductID] = q.Quantity
+	}
+	inboundBy := make(map[uint]int, len(onOrder)+len(inTransit))
+	for _, q := range onOrder {
+		inboundBy[q.ProductID] += q.Quantity
+	}
+	for _, q := range inTransit {
+		inboundBy[q.ProductID] += q.Quantity
+	}
+	itemsBy := make(map[uint][]models.SupplierProduct)
+	for _, sp := range items {
+		itemsBy[sp.ProductID] = append(itemsBy[sp.ProductID], sp)
+	}
+
+	run := models.ReplenishmentRun{
+		WarehouseID: warehouse.ID,
+		Notes:       notes,
+		CreatedBy:   userID,
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	for _, p := range products {
+		if p.MinQuantity <= 0 && p.MaxQuantity <= 0 {
+			continue // no replenishment policy
+		}
+		position := availableBy[p.ID] + inboundBy[p.ID]
+		if position > p.MinQuantity {
+			continue
+		}
+
+		target := max(p.MaxQuantity, p.MinQuantity)
+		item := pickSupplierProduct(itemsBy[p.ID], p)
+		suggestion := models.ReplenishmentSuggestion{
+			ProductID:         p.ID,
+			Available:         availableBy[p.ID],
+			Inbound:           inboundBy[p.ID],
+			ReorderPoint:      p.MinQuantity,
+			TargetQuantity:    target,
+			SuggestedQuantity: replenishmentQuantity(position, target, item),
+			UnitCost:          p.CostPrice,
+			Status:            models.ReplenishmentStatusProposed,
+			UpdatedBy:         userID,
+		}
+		if item != nil {
+			suggestion.SupplierID = &item.SupplierID
+			suggestion.UnitCost = item.UnitCost
+		}
+		run.Suggestions = append(run.Suggestions, suggestion)
+	}
+
+	if err := tx.Create(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// CreateReplenishmentRun godoc
+// @Summary Run replenishment
+// @Description Compare each product's available stock plus open purchase orders and transfers in transit with its reorder point (min_quantity) and propose an order up to its max_quantity, rounded to the supplier's minimum order and pack size
+// @Tags replenishment
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param run body CreateReplenishmentRunRequest true "Products to check"
+// @Success 201 {object} models.ReplenishmentRun
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /replenishment/runs [post]
+func (h *ReplenishmentHandler) CreateReplenishmentRun(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req CreateReplenishmentRunRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var run *models.ReplenishmentRun
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("is_active = ?", true)
+		if len(req.ProductIDs) > 0 {
+			query = query.Where("id IN ?", req.ProductIDs)
+		}
+		if req.Category != "" {
+			query = query.Where("category = ?", req.Category)
+		}
+
+		var products []models.Product
+		if err := query.Find(&products).Error; err != nil {
+			return err
+		}
+
+		var err error
+		run, err = runReplenishment(tx, req.WarehouseID, products, req.Notes, userID.(uint))
+		return err
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, run)
+}
+
+// GetReplenishmentRuns godoc
+// @Summary Get replenishment runs
+// @Description List replenishment runs, newest first
+// @Tags replenishment
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Router /replenishment/runs [get]
+func (h *ReplenishmentHandler) GetReplenishmentRuns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	var total int64
+	var runs []models.ReplenishmentRun
+	h.db.Model(&models.ReplenishmentRun{}).Count(&total)
+	h.db.Preload("Suggestions").Offset(offset).Limit(limit).Order("created_at DESC").Find(&runs)
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs":  runs,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetReplenishmentRunByID godoc
+// @Summary Get a replenishment run
+// @Description Get a replenishment run with its suggestions
+// @Tags replenishment
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Replenishment run ID"
+// @Success 200 {object} models.ReplenishmentRun
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /replenishment/runs/{id} [get]
+func (h *ReplenishmentHandler) GetReplenishmentRunByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid replenishment run ID"})
+		return
+	}
+
+	var run models.ReplenishmentRun
+	if err := h.db.Preload("Suggestions", func(db *gorm.DB) *gorm.DB { return db.Order("product_id, id") }).
+		Preload("Suggestions.Product").Preload("Suggestions.Supplier").Preload("Warehouse").
+		First(&run, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Replenishment run not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// CreateReplenishmentOrders godoc
+// @Summary Order replenishment suggestions
+// @Description Turn accepted suggestions into draft purchase orders, one per supplier, received at the run's warehouse. Suggestions without a supplier cannot be ordered.
+// @Tags replenishment
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Replenishment run ID"
+// @Param order body CreateReplenishmentOrdersRequest false "Suggestions to order"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /replenishment/runs/{id}/purchase-orders [post]
+func (h *ReplenishmentHandler) CreateReplenishmentOrders(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid replenishment run ID"})
+		return
+	}
+
+	var req CreateReplenishmentOrdersRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var orders []*models.PurchaseOrder
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var run models.ReplenishmentRun
+		if err := tx.First(&run, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errReplenishmentRunNotFound
+			}
+			return err
+		}
+
+		var suggestions []models.ReplenishmentSuggestion
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("run_id = ?", run.ID).Order("product_id, id").Find(&suggestions).Error; err != nil {
+			return err
+		}
+
+		// Accepted suggestions and the quantity to order for each
+		accepted := make(map[uint]int)
+		if len(req.Lines) == 0 {
+			for _, s := range suggestions {
+				if s.Status == models.ReplenishmentStatusProposed && s.SupplierID != nil {
+					accepted[s.ID] = s.SuggestedQuantity
+				}
+			}
+		}
+		for _, l := range req.Lines {
+			if _, dup := accepted[l.SuggestionID]; dup {
+				return validationError(fmt.Sprintf("Suggestion %d appears on more than one line", l.SuggestionID))
+			}
+			accepted[l.SuggestionID] = l.Quantity
+		}
+
+		bySupplier := make(map[uint][]*models.ReplenishmentSuggestion)
+		supplierIDs := []uint{}
+		found := 0
+		for i := range suggestions {
+			s := &suggestions[i]
+			quantity, ok := accepted[s.ID]
+			if !ok {
+				continue
+			}
+			found++
+			if s.Status != models.ReplenishmentStatusProposed {
+				return conflictError(fmt.Sprintf("Suggestion %d has already been ordered", s.ID))
+			}
+			if s.SupplierID == nil {
+				return validationError(fmt.Sprintf("Suggestion %d has no supplier for product %d", s.ID, s.ProductID))
+			}
+			if quantity > 0 {
+				s.SuggestedQuantity = quantity
+			}
+			if _, seen := bySupplier[*s.SupplierID]; !seen {
+				supplierIDs = append(supplierIDs, *s.SupplierID)
+			}
+			bySupplier[*s.SupplierID] = append(bySupplier[*s.SupplierID], s)
+		}
+		if found != len(accepted) {
+			return notFoundError("Suggestion not found on this replenishment run")
+		}
+		if found == 0 {
+			return validationError("No suggestions to order")
+		}
+
+		sort.Slice(supplierIDs, func(i, j int) bool { return supplierIDs[i] < supplierIDs[j] })
+		for _, supplierID := range supplierIDs {
+			var supplier models.Supplier
+			if err := tx.First(&supplier, supplierID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return errSupplierNotFound
+				}
+				return err
+			}
+
+			po := CreatePurchaseOrderRequest{
+				SupplierID:  supplierID,
+				WarehouseID: run.WarehouseID,
+				Notes:       req.Notes,
+			}
+			if supplier.LeadTimeDays > 0 {
+				expected := time.Now().AddDate(0, 0, supplier.LeadTimeDays)
+				po.ExpectedDate = &expected
+			}
+			for _, s := range bySupplier[supplierID] {
+				po.Lines = append(po.Lines, PurchaseOrderLineRequest{
+					ProductID: s.ProductID,
+					Quantity:  s.SuggestedQuantity,
+					UnitCost:  s.UnitCost,
+				})
+			}
+
+			order, err := createPurchaseOrder(tx, po, userID.(uint))
+			if err != nil {
+				return err
+			}
+			orders = append(orders, order)
+
+			for _, s := range bySupplier[supplierID] {
+				s.Status = models.ReplenishmentStatusOrdered
+				s.PurchaseOrderID = &order.ID
+				s.UpdatedBy = userID.(uint)
+				if err := tx.Omit(clause.Associations).Save(s).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"purchase_orders": orders})
+}
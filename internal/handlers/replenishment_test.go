@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+)
+
+func TestReplenishmentQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		position int
+		target   int
+		item     *models.SupplierProduct
+		want     int
+	}{
+		{"no supplier terms", 4, 20, nil, 16},
+		{"at target orders one", 20, 20, nil, 1},
+		{"above target orders one", 25, 20, nil, 1},
+		{"negative position", -5, 20, nil, 25},
+		{"raised to the minimum order", 15, 20, &models.SupplierProduct{MinOrderQty: 12}, 12},
+		{"minimum already met", 0, 20, &models.SupplierProduct{MinOrderQty: 12}, 20},
+		{"rounded up to whole packs", 4, 20, &models.SupplierProduct{PackSize: 6}, 18},
+		{"exact packs", 8, 20, &models.SupplierProduct{PackSize: 6}, 12},
+		{"minimum then packs", 15, 20, &models.SupplierProduct{MinOrderQty: 10, PackSize: 4}, 12},
+		{"pack size of one", 4, 20, &models.SupplierProduct{PackSize: 1}, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replenishmentQuantity(tt.position, tt.target, tt.item); got != tt.want {
+				t.Errorf("replenishmentQuantity(%d, %d) = %d, want %d", tt.position, tt.target, got, tt.want)
+			}
+		})
+	}
+}
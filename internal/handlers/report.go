@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+)
+
+// ReportHandler holds dependencies for inventory reports
+type ReportHandler struct {
+	db     *gorm.DB
+	ledger LedgerSettings
+}
+
+func NewReportHandler(db *gorm.DB, ledger LedgerSettings) *ReportHandler {
+	return &ReportHandler{
+		db:     db,
+		ledger: ledger,
+	}
+}
+
+// ValuationLine is the valuation of one product
+type ValuationLine struct {
+	ProductID      uint    `json:"product_id"`
+	SKU            string  `json:"sku"`
+	Name           string  `json:"name"`
+	Category       string  `json:"category"`
+	CostingMethod  string  `json:"costing_method"`
+	OnHandQuantity int     `json:"on_hand_quantity"`
+	OnHandValue    float64 `json:"on_hand_value"`
+	COGS           float64 `json:"cogs"`
+}
+
+// ValuationResponse is the inventory valuation as of To and the COGS between From and To
+type ValuationResponse struct {
+	From             string          `json:"from"`
+	To               string          `json:"to"`
+	Products         []ValuationLine `json:"products"`
+	TotalOnHandValue float64         `json:"total_on_hand_value"`
+	TotalCOGS        float64         `json:"total_cogs"`
+}
+
+// parseDateRange reads from/to (YYYY-MM-DD) query params, defaulting to the
+// current month so far. The returned end is exclusive.
+func parseDateRange(c *gin.Context) (time.Time, time.Time, bool) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	if v := c.Query("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, now.Location())
+		if err != nil {
+			return from, to, false
+		}
+		from = t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, now.Location())
+		if err != nil {
+			return from, to, false
+		}
+		to = t
+	}
+
+	return from, to.AddDate(0, 0, 1), true
+}
+
+// GetValuation godoc
+// @Summary Inventory valuation and COGS
+// @Description On-hand quantity and value as of the end date and cost of goods sold within the range, computed from the stock ledger
+// @Tags reports
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Start date (YYYY-MM-DD), defaults to the first of this month"
+// @Param to query string false "End date (YYYY-MM-DD), defaults to today"
+// @Param product_id query int false "Product filter"
+// @Param category query string false "Category filter"
+// @Success 200 {object} ValuationResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /reports/valuation [get]
+func (h *ReportHandler) GetValuation(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return
+	}
+
+	// Value moves with the sign of the balance change; COGS is the cost of
+	// OUT movements, less the reversals of those movements and customer returns
+	query := h.db.Table("stocks").
+		Select(`products.id AS product_id, products.sku, products.name, products.category,
+			COALESCE(NULLIF(products.costing_method, ''), @method) AS costing_method,
+			COALESCE(SUM(CASE WHEN stocks.created_at < @to THEN stocks.new_quantity - stocks.old_quantity ELSE 0 END), 0) AS on_hand_quantity,
+			COALESCE(SUM(CASE WHEN stocks.created_at < @to THEN
+				CASE WHEN stocks.new_quantity > stocks.old_quantity THEN stocks.total_cost
+				     WHEN stocks.new_quantity < stocks.old_quantity THEN -stocks.total_cost
+				     ELSE 0 END
+				ELSE 0 END), 0) AS on_hand_value,
+			COALESCE(SUM(CASE WHEN stocks.created_at >= @from AND stocks.created_at < @to THEN
+				CASE WHEN stocks.type = 'OUT' THEN stocks.total_cost
+				     WHEN reversed.type = 'OUT' OR stocks.type = 'RETURN_IN' THEN -stocks.total_cost
+				     ELSE 0 END
+				ELSE 0 END), 0) AS cogs`,
+			sql.Named("method", h.ledger.defaultCostingMethod()), sql.Named("from", from), sql.Named("to", to)).
+		Joins("JOIN products ON products.id = stocks.product_id").
+		Joins("LEFT JOIN stocks AS reversed ON reversed.id = stocks.reversal_of").
+		Where("stocks.status = ?", models.StockStatusPosted).
+		Group("products.id, products.sku, products.name, products.category, products.costing_method").
+		Order("products.sku")
+
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("products.id = ?", productID)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("products.category = ?", category)
+	}
+
+	lines := []ValuationLine{}
+	if err := query.Scan(&lines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute valuation"})
+		return
+	}
+
+	response := ValuationResponse{
+		From:     from.Format("2006-01-02"),
+		To:       to.AddDate(0, 0, -1).Format("2006-01-02"),
+		Products: lines,
+	}
+	for _, l := range lines {
+		response.TotalOnHandValue += l.OnHandValue
+		response.TotalCOGS += l.COGS
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ShrinkageLine is the stock lost under one reason code
+type ShrinkageLine struct {
+	ReasonCode string  `json:"reason_code"`
+	ReasonName string  `json:"reason_name"`
+	Movements  int     `json:"movements"`
+	Quantity   int     `json:"quantity"` // units lost, net of gains booked under the same reason
+	Value      float64 `json:"value"`
+}
+
+// ShrinkageResponse is the shrinkage by reason code between From and To
+type ShrinkageResponse struct {
+	From          string          `json:"from"`
+	To            string          `json:"to"`
+	Reasons       []ShrinkageLine `json:"reasons"`
+	TotalQuantity int             `json:"total_quantity"`
+	TotalValue    float64         `json:"total_value"`
+}
+
+// GetShrinkage godoc
+// @Summary Shrinkage by reason
+// @Description Quantity and value lost through movements booked with a reason code, grouped by reason. Reversals net out against the movement they undo.
+// @Tags reports
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Start date (YYYY-MM-DD), defaults to the first of this month"
+// @Param to query string false "End date (YYYY-MM-DD), defaults to today"
+// @Param warehouse_id query int false "Warehouse filter"
+// @Param product_id query int false "Product filter"
+// @Success 200 {object} ShrinkageResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /reports/shrinkage [get]
+func (h *ReportHandler) GetShrinkage(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return
+	}
+
+	// A loss is a fall in balance, or stock lost in transit that had already
+	// left the balance; gains under the same reason (e.g. a count variance
+	// found later) offset it
+	query := h.db.Table("stocks").
+		Select(`stocks.reason_code, COALESCE(MAX(reason_codes.name), stocks.reason_code) AS reason_name,
+			COUNT(*) AS movements,
+			COALESCE(SUM(CASE WHEN stocks.type = 'TRANSIT_LOSS' THEN stocks.quantity ELSE stocks.old_quantity - stocks.new_quantity END), 0) AS quantity,
+			COALESCE(SUM(CASE WHEN stocks.new_quantity < stocks.old_quantity OR stocks.type = 'TRANSIT_LOSS' THEN stocks.total_cost ELSE -stocks.total_cost END), 0) AS value`).
+		Joins("LEFT JOIN reason_codes ON reason_codes.code = stocks.reason_code").
+		Where("stocks.reason_code <> '' AND stocks.status = ? AND stocks.created_at >= ? AND stocks.created_at < ?",
+			models.StockStatusPosted, from, to).
+		Group("stocks.reason_code").
+		Order("value DESC")
+
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		query = query.Where("stocks.warehouse_id = ?", warehouseID)
+	}
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("stocks.product_id = ?", productID)
+	}
+
+	lines := []ShrinkageLine{}
+	if err := query.Scan(&lines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute shrinkage"})
+		return
+	}
+
+	response := ShrinkageResponse{
+		From:    from.Format("2006-01-02"),
+		To:      to.AddDate(0, 0, -1).Format("2006-01-02"),
+		Reasons: lines,
+	}
+	for _, l := range lines {
+		response.TotalQuantity += l.Quantity
+		response.TotalValue += l.Value
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CountAccuracyLine is the count accuracy of one ABC class in one month
+type CountAccuracyLine struct {
+	Period           string  `json:"period"` // YYYY-MM
+	Class            string  `json:"abc_class"`
+	LinesCounted     int     `json:"lines_counted"`
+	AccurateLines    int     `json:"accurate_lines"` // counted with no variance
+	AccuracyPercent  float64 `json:"accuracy_percent"`
+	AbsoluteVariance int     `json:"absolute_variance"` // units, ignoring sign
+	AbsoluteValue    float64 `json:"absolute_value"`
+}
+
+// GetCountAccuracy godoc
+// @Summary Cycle count accuracy by ABC class
+// @Description Share of counted lines without a variance, per ABC class and month, over stocktakes and cycle counts approved in the range
+// @Tags reports
+// @Produce json
+// @Security BearerAuth
+// @Param from query string false "Start date (YYYY-MM-DD), defaults to the first of this month"
+// @Param to query string false "End date (YYYY-MM-DD), defaults to today"
+// @Param warehouse_id query int false "Warehouse filter"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Router /reports/count-accuracy [get]
+func (h *ReportHandler) GetCountAccuracy(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return
+	}
+
+	// The class is the one the product had when the count was opened
+	query := h.db.Table("stocktake_lines").
+		Select(`TO_CHAR(stocktakes.approved_at, 'YYYY-MM') AS period,
+			stocktake_lines.abc_class AS class,
+			COUNT(*) AS lines_counted,
+			COUNT(*) FILTER (WHERE stocktake_lines.variance = 0) AS accurate_lines,
+			100.0 * COUNT(*) FILTER (WHERE stocktake_lines.variance = 0) / COUNT(*) AS accuracy_percent,
+			COALESCE(SUM(ABS(stocktake_lines.variance)), 0) AS absolute_variance,
+			COALESCE(SUM(ABS(stocktake_lines.variance_value)), 0) AS absolute_value`).
+		Joins("JOIN stocktakes ON stocktakes.id = stocktake_lines.stocktake_id").
+		Where("stocktakes.status = ? AND stocktakes.approved_at >= ? AND stocktakes.approved_at < ?",
+			models.StocktakeStatusPosted, from, to).
+		Group("period, stocktake_lines.abc_class").
+		Order("period, class")
+
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		query = query.Where("stocktakes.warehouse_id = ?", warehouseID)
+	}
+
+	lines := []CountAccuracyLine{}
+	if err := query.Scan(&lines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute count accuracy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":  from.Format("2006-01-02"),
+		"to":    to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"lines": lines,
+	})
+}
@@ -24,10 +24,39 @@ type StockHistoryResponse struct {
 
 // CurrentStockResponse represents the response for getting current stock levels
 type CurrentStockResponse struct {
-	Products      []models.Product `json:"products"`
-	Total         int64            `json:"total"`
-	Page          int              `json:"page"`
-	Limit         int              `json:"limit"`
-	LowStock      []models.Product `json:"low_stock"`
-	LowStockCount int              `json:"low_stock_count"`
+	Products        []models.Product      `json:"products"`
+	Total           int64                 `json:"total"`
+	Page            int                   `json:"page"`
+	Limit           int                   `json:"limit"`
+	LowStock        []models.Product      `json:"low_stock"`
+	LowStockCount   int                   `json:"low_stock_count"`
+	Balances        []models.StockBalance `json:"balances"`
+	WarehouseTotals []WarehouseStockTotal `json:"warehouse_totals"`
+	InTransit       []ProductQuantity     `json:"in_transit"`
+	Availability    []StockAvailability   `json:"availability"`
+}
+
+// StockAvailability splits a product's on-hand quantity into what is reserved
+// for open sales orders, what is quarantined after a return and what is still
+// available to promise
+type StockAvailability struct {
+	ProductID   uint `json:"product_id"`
+	OnHand      int  `json:"on_hand"`
+	Reserved    int  `json:"reserved"`
+	Quarantined int  `json:"quarantined"`
+	Available   int  `json:"available"`
+}
+
+// ProductQuantity is a quantity of a single product
+type ProductQuantity struct {
+	ProductID uint `json:"product_id"`
+	Quantity  int  `json:"quantity"`
+}
+
+// WarehouseStockTotal is the aggregated on-hand quantity held at one warehouse
+type WarehouseStockTotal struct {
+	WarehouseID uint   `json:"warehouse_id"`
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Quantity    int    `json:"quantity"`
 }
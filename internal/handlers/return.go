@@ -0,0 +1,752 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const errReturnNotFound = notFoundError("Return not found")
+
+// ReturnHandler holds dependencies for customer returns (RMA)
+type ReturnHandler struct {
+	db     *gorm.DB
+	cache  *redis.Client
+	ledger LedgerSettings
+}
+
+func NewReturnHandler(db *gorm.DB, cache *redis.Client, ledger LedgerSettings) *ReturnHandler {
+	return &ReturnHandler{
+		db:     db,
+		cache:  cache,
+		ledger: ledger,
+	}
+}
+
+// ReturnLineRequest is one product the customer is authorised to send back
+type ReturnLineRequest struct {
+	ProductID uint   `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,gt=0"`
+	Reason    string `json:"reason"`
+}
+
+// CreateReturnRequest holds the fields for creating an RMA
+type CreateReturnRequest struct {
+	SalesReference string              `json:"sales_reference" binding:"required"` // SO number or external invoice
+	CustomerName   string              `json:"customer_name"`
+	WarehouseID    uint                `json:"warehouse_id"` // optional, defaults to the sales order's or the default warehouse
+	Notes          string              `json:"notes"`
+	Lines          []ReturnLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+// ReceiveReturnLineRequest is the quantity of a return line arriving
+type ReceiveReturnLineRequest struct {
+	LineID          uint       `json:"line_id" binding:"required"`
+	Quantity        int        `json:"quantity" binding:"required,gt=0"`
+	LotNumber       string     `json:"lot_number"`
+	ManufactureDate *time.Time `json:"manufacture_date"`
+	ExpiryDate      *time.Time `json:"expiry_date"`
+	Serials         []string   `json:"serials" binding:"omitempty,dive,required"`
+}
+
+// ReceiveReturnRequest records goods arriving into quarantine
+type ReceiveReturnRequest struct {
+	Lines []ReceiveReturnLineRequest `json:"lines" binding:"required,min=1,dive"`
+	Notes string                     `json:"notes"`
+}
+
+// InspectReturnLineRequest is the inspection result of a return line
+type InspectReturnLineRequest struct {
+	LineID    uint   `json:"line_id" binding:"required"`
+	Condition string `json:"condition" binding:"required,oneof=GOOD DAMAGED DEFECTIVE"`
+	Notes     string `json:"notes"`
+}
+
+// InspectReturnRequest records inspection results
+type InspectReturnRequest struct {
+	Lines []InspectReturnLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+// DispositionLineRequest decides what happens to quarantined units of a line
+type DispositionLineRequest struct {
+	LineID     uint     `json:"line_id" binding:"required"`
+	Action     string   `json:"action" binding:"required,oneof=RESTOCK SCRAP RTV"`
+	Quantity   int      `json:"quantity" binding:"required,gt=0"`
+	SupplierID uint     `json:"supplier_id" binding:"required_if=Action RTV"`
+	LotNumber  string   `json:"lot_number"`
+	Serials    []string `json:"serials" binding:"omitempty,dive,required"`
+}
+
+// DispositionReturnRequest restocks, scraps or returns quarantined goods to the vendor
+type DispositionReturnRequest struct {
+	Lines []DispositionLineRequest `json:"lines" binding:"required,min=1,dive"`
+	Notes string                   `json:"notes"`
+}
+
+// CloseReturnRequest closes or cancels an RMA
+type CloseReturnRequest struct {
+	Notes string `json:"notes"`
+}
+
+// loadReturn locks an RMA and reads its lines (ordered by product) inside tx
+func loadReturn(tx *gorm.DB, id int) (*models.ReturnAuthorization, error) {
+	var rma models.ReturnAuthorization
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("product_id, id") }).
+		First(&rma, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errReturnNotFound
+		}
+		return nil, err
+	}
+	return &rma, nil
+}
+
+// refreshReturnStatus closes an RMA once everything authorised has arrived
+// and left quarantine
+func refreshReturnStatus(rma *models.ReturnAuthorization) {
+	received := 0
+	complete := true
+	for _, l := range rma.Lines {
+		received += l.QuantityReceived
+		if l.QuantityReceived < l.QuantityAuthorized || l.InQuarantine() > 0 {
+			complete = false
+		}
+	}
+
+	switch {
+	case complete:
+		now := time.Now()
+		rma.Status = models.ReturnStatusClosed
+		rma.ClosedAt = &now
+	case received > 0:
+		rma.Status = models.ReturnStatusReceived
+	default:
+		rma.Status = models.ReturnStatusAuthorized
+	}
+}
+
+// indexReturnLines maps line IDs to their position and rejects lines from other RMAs
+func indexReturnLines(rma *models.ReturnAuthorization, lineIDs []uint) (map[uint]int, error) {
+	index := make(map[uint]int, len(rma.Lines))
+	for i, l := range rma.Lines {
+		index[l.ID] = i
+	}
+	for _, id := range lineIDs {
+		if _, ok := index[id]; !ok {
+			return nil, validationError(fmt.Sprintf("Line %d does not belong to this return", id))
+		}
+	}
+	return index, nil
+}
+
+// returnUnitCost is the average cost the product was shipped at on a sales
+// order, so a return puts it back on the books at the same value. It is 0
+// when unknown, which values the return at the current cost.
+func returnUnitCost(tx *gorm.DB, salesOrderID *uint, productID uint) (float64, error) {
+	if salesOrderID == nil {
+		return 0, nil
+	}
+	var cost float64
+	err := tx.Table("stocks").
+		Select("COALESCE(SUM(stocks.total_cost) / NULLIF(SUM(stocks.quantity), 0), 0)").
+		Joins("JOIN sales_order_lines ON sales_order_lines.id = stocks.so_line_id").
+		Where("sales_order_lines.sales_order_id = ? AND stocks.product_id = ? AND stocks.type = ? AND stocks.reversed_by IS NULL",
+			*salesOrderID, productID, "OUT").
+		Scan(&cost).Error
+	return cost, err
+}
+
+// CreateReturn godoc
+// @Summary Create a return (RMA)
+// @Description Authorise a customer return against a sales reference. When the reference is a sales order, quantities are checked against what was shipped.
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param rma body CreateReturnRequest true "Return details"
+// @Success 201 {object} models.ReturnAuthorization
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /returns [post]
+func (h *ReturnHandler) CreateReturn(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req CreateReturnRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rma models.ReturnAuthorization
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		rma = models.ReturnAuthorization{
+			SalesReference: req.SalesReference,
+			CustomerName:   req.CustomerName,
+			Status:         models.ReturnStatusAuthorized,
+			Notes:          req.Notes,
+			CreatedBy:      userID.(uint),
+			UpdatedBy:      userID.(uint),
+		}
+
+		// Returns against one of our sales orders cannot exceed what was shipped
+		var order models.SalesOrder
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Lines").Where("so_number = ?", req.SalesReference).First(&order).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			rma.SalesOrderID = &order.ID
+			if rma.CustomerName == "" {
+				rma.CustomerName = order.CustomerName
+			}
+			if req.WarehouseID == 0 {
+				req.WarehouseID = order.WarehouseID
+			}
+
+			shipped := make(map[uint]int, len(order.Lines))
+			for _, l := range order.Lines {
+				shipped[l.ProductID] += l.QuantityFulfilled
+			}
+
+			type authorised struct {
+				ProductID uint
+				Quantity  int
+			}
+			var previous []authorised
+			if err := tx.Table("return_lines").
+				Select("return_lines.product_id, SUM(return_lines.quantity_authorized) AS quantity").
+				Joins("JOIN return_authorizations ON return_authorizations.id = return_lines.return_id").
+				Where("return_authorizations.sales_order_id = ? AND return_authorizations.status <> ?", order.ID, models.ReturnStatusCancelled).
+				Group("return_lines.product_id").
+				Scan(&previous).Error; err != nil {
+				return err
+			}
+			for _, p := range previous {
+				shipped[p.ProductID] -= p.Quantity
+			}
+
+			for _, l := range req.Lines {
+				if l.Quantity > shipped[l.ProductID] {
+					return validationError(fmt.Sprintf("Product %d: only %d can still be returned against %s",
+						l.ProductID, max(shipped[l.ProductID], 0), order.SONumber))
+				}
+				shipped[l.ProductID] -= l.Quantity
+			}
+		}
+
+		warehouse, err := resolveWarehouse(tx, req.WarehouseID)
+		if err != nil {
+			return err
+		}
+		rma.WarehouseID = warehouse.ID
+
+		for _, l := range req.Lines {
+			var product models.Product
+			if err := tx.First(&product, l.ProductID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return errProductNotFound
+				}
+				return err
+			}
+			rma.Lines = append(rma.Lines, models.ReturnLine{
+				ProductID:          l.ProductID,
+				Reason:             l.Reason,
+				QuantityAuthorized: l.Quantity,
+			})
+		}
+
+		if err := tx.Create(&rma).Error; err != nil {
+			return err
+		}
+
+		rma.RMANumber = fmt.Sprintf("RMA-%06d", rma.ID)
+		return tx.Model(&rma).Update("rma_number", rma.RMANumber).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, rma)
+}
+
+// GetReturns godoc
+// @Summary Get returns
+// @Description List RMAs, optionally filtered by status or sales reference
+// @Tags returns
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Param status query string false "AUTHORIZED, RECEIVED, CLOSED or CANCELLED"
+// @Param sales_reference query string false "Sales reference filter"
+// @Success 200 {object} map[string]interface{}
+// @Router /returns [get]
+func (h *ReturnHandler) GetReturns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	status := c.Query("status")
+	salesReference := c.Query("sales_reference")
+	offset := (page - 1) * limit
+
+	query := h.db.Model(&models.ReturnAuthorization{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if salesReference != "" {
+		query = query.Where("sales_reference = ?", salesReference)
+	}
+
+	var total int64
+	var returns []models.ReturnAuthorization
+	query.Count(&total)
+	query.Preload("Lines").Offset(offset).Limit(limit).Order("created_at DESC").Find(&returns)
+
+	c.JSON(http.StatusOK, gin.H{
+		"returns": returns,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// GetReturnByID godoc
+// @Summary Get a return
+// @Description Get an RMA with its lines and the RETURN_IN, RESTOCK, SCRAP and RTV movements posted for it
+// @Tags returns
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /returns/{id} [get]
+func (h *ReturnHandler) GetReturnByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return ID"})
+		return
+	}
+
+	var rma models.ReturnAuthorization
+	if err := h.db.Preload("Lines.Product").Preload("Warehouse").First(&rma, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Return not found"})
+		return
+	}
+
+	var movements []models.Stock
+	h.db.Preload("Lots.Lot").Preload("Serials.Serial").
+		Where("reference = ? AND type IN ?", rma.RMANumber, []string{"RETURN_IN", "RESTOCK", "SCRAP", "RTV"}).
+		Order("created_at").Find(&movements)
+
+	c.JSON(http.StatusOK, gin.H{
+		"return":    rma,
+		"movements": movements,
+	})
+}
+
+// ReceiveReturn godoc
+// @Summary Receive a return
+// @Description Receive returned goods into quarantine with RETURN_IN movements; quarantined stock is not available until restocked
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param receipt body ReceiveReturnRequest true "Received quantities"
+// @Success 200 {object} models.ReturnAuthorization
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /returns/{id}/receive [post]
+func (h *ReturnHandler) ReceiveReturn(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return ID"})
+		return
+	}
+
+	var req ReceiveReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rma *models.ReturnAuthorization
+	var productIDs []uint
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		rma, err = loadReturn(tx, id)
+		if err != nil {
+			return err
+		}
+		if rma.Status != models.ReturnStatusAuthorized && rma.Status != models.ReturnStatusReceived {
+			return conflictError("Return is not open for receiving")
+		}
+
+		lineIDs := make([]uint, 0, len(req.Lines))
+		for _, r := range req.Lines {
+			lineIDs = append(lineIDs, r.LineID)
+		}
+		index, err := indexReturnLines(rma, lineIDs)
+		if err != nil {
+			return err
+		}
+
+		// Post in product order so locks are taken in a stable order
+		receipts := append([]ReceiveReturnLineRequest(nil), req.Lines...)
+		sort.SliceStable(receipts, func(i, j int) bool { return index[receipts[i].LineID] < index[receipts[j].LineID] })
+
+		for _, r := range receipts {
+			line := &rma.Lines[index[r.LineID]]
+			if line.QuantityReceived+r.Quantity > line.QuantityAuthorized {
+				return validationError(fmt.Sprintf("Line %d has only %d left to receive", line.ID, line.QuantityAuthorized-line.QuantityReceived))
+			}
+
+			unitCost, err := returnUnitCost(tx, rma.SalesOrderID, line.ProductID)
+			if err != nil {
+				return err
+			}
+
+			if _, err := postStockMovement(tx, h.ledger, stockMovementInput{
+				ProductID:       line.ProductID,
+				WarehouseID:     rma.WarehouseID,
+				Type:            "RETURN_IN",
+				Quantity:        r.Quantity,
+				Reference:       rma.RMANumber,
+				UnitCost:        unitCost,
+				Notes:           req.Notes,
+				UserID:          userID.(uint),
+				Quarantine:      true,
+				LotNumber:       r.LotNumber,
+				ManufactureDate: r.ManufactureDate,
+				ExpiryDate:      r.ExpiryDate,
+				Serials:         r.Serials,
+			}); err != nil {
+				return err
+			}
+
+			line.QuantityReceived += r.Quantity
+			if err := tx.Save(line).Error; err != nil {
+				return err
+			}
+			productIDs = append(productIDs, line.ProductID)
+		}
+
+		refreshReturnStatus(rma)
+		rma.UpdatedBy = userID.(uint)
+		return tx.Omit("Lines").Save(rma).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	invalidateStockCaches(h.cache, c.Request.Context(), productIDs...)
+
+	c.JSON(http.StatusOK, rma)
+}
+
+// InspectReturn godoc
+// @Summary Inspect a return
+// @Description Record the condition of received return lines; a line must be inspected before it is dispositioned
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param inspection body InspectReturnRequest true "Inspection results"
+// @Success 200 {object} models.ReturnAuthorization
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /returns/{id}/inspect [post]
+func (h *ReturnHandler) InspectReturn(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return ID"})
+		return
+	}
+
+	var req InspectReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rma *models.ReturnAuthorization
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		rma, err = loadReturn(tx, id)
+		if err != nil {
+			return err
+		}
+		if rma.Status != models.ReturnStatusReceived {
+			return conflictError("Return has nothing waiting for inspection")
+		}
+
+		lineIDs := make([]uint, 0, len(req.Lines))
+		for _, r := range req.Lines {
+			lineIDs = append(lineIDs, r.LineID)
+		}
+		index, err := indexReturnLines(rma, lineIDs)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		inspector := userID.(uint)
+		for _, r := range req.Lines {
+			line := &rma.Lines[index[r.LineID]]
+			if line.InQuarantine() == 0 {
+				return validationError(fmt.Sprintf("Line %d has nothing in quarantine", line.ID))
+			}
+
+			line.Condition = r.Condition
+			line.InspectionNotes = r.Notes
+			line.InspectedBy = &inspector
+			line.InspectedAt = &now
+			if err := tx.Save(line).Error; err != nil {
+				return err
+			}
+		}
+
+		rma.UpdatedBy = inspector
+		return tx.Omit("Lines").Save(rma).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rma)
+}
+
+// DispositionReturn godoc
+// @Summary Disposition a return
+// @Description Restock (RESTOCK), scrap (SCRAP) or return to vendor (RTV) inspected goods held in quarantine
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param disposition body DispositionReturnRequest true "Disposition per line"
+// @Success 200 {object} models.ReturnAuthorization
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /returns/{id}/disposition [post]
+func (h *ReturnHandler) DispositionReturn(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return ID"})
+		return
+	}
+
+	var req DispositionReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rma *models.ReturnAuthorization
+	var productIDs []uint
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		rma, err = loadReturn(tx, id)
+		if err != nil {
+			return err
+		}
+		if rma.Status != models.ReturnStatusReceived {
+			return conflictError("Return has nothing waiting for disposition")
+		}
+
+		lineIDs := make([]uint, 0, len(req.Lines))
+		for _, r := range req.Lines {
+			lineIDs = append(lineIDs, r.LineID)
+		}
+		index, err := indexReturnLines(rma, lineIDs)
+		if err != nil {
+			return err
+		}
+
+		// Post in product order so locks are taken in a stable order
+		dispositions := append([]DispositionLineRequest(nil), req.Lines...)
+		sort.SliceStable(dispositions, func(i, j int) bool { return index[dispositions[i].LineID] < index[dispositions[j].LineID] })
+
+		for _, d := range dispositions {
+			line := &rma.Lines[index[d.LineID]]
+			switch {
+			case line.Condition == "":
+				return validationError(fmt.Sprintf("Line %d must be inspected first", line.ID))
+			case d.Quantity > line.InQuarantine():
+				return validationError(fmt.Sprintf("Line %d has only %d in quarantine", line.ID, line.InQuarantine()))
+			case d.Action == models.DispositionRestock && line.Condition != models.ReturnConditionGood:
+				return validationError(fmt.Sprintf("Line %d was inspected as %s and cannot be restocked", line.ID, line.Condition))
+			}
+
+			notes := req.Notes
+			if d.Action == models.DispositionRTV {
+				var supplier models.Supplier
+				if err := tx.First(&supplier, d.SupplierID).Error; err != nil {
+					if errors.Is(err, gorm.ErrRecordNotFound) {
+						return errSupplierNotFound
+					}
+					return err
+				}
+				line.SupplierID = &supplier.ID
+				if notes == "" {
+					notes = fmt.Sprintf("Returned to %s", supplier.Code)
+				}
+			}
+
+			if _, err := postStockMovement(tx, h.ledger, stockMovementInput{
+				ProductID:   line.ProductID,
+				WarehouseID: rma.WarehouseID,
+				Type:        d.Action,
+				Quantity:    d.Quantity,
+				Reference:   rma.RMANumber,
+				Notes:       notes,
+				UserID:      userID.(uint),
+				Quarantine:  true,
+				LotNumber:   d.LotNumber,
+				Serials:     d.Serials,
+			}); err != nil {
+				return err
+			}
+
+			switch d.Action {
+			case models.DispositionRestock:
+				line.QuantityRestocked += d.Quantity
+			case models.DispositionScrap:
+				line.QuantityScrapped += d.Quantity
+			case models.DispositionRTV:
+				line.QuantityRTV += d.Quantity
+			}
+			if err := tx.Save(line).Error; err != nil {
+				return err
+			}
+			productIDs = append(productIDs, line.ProductID)
+		}
+
+		refreshReturnStatus(rma)
+		rma.UpdatedBy = userID.(uint)
+		return tx.Omit("Lines").Save(rma).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	invalidateStockCaches(h.cache, c.Request.Context(), productIDs...)
+
+	c.JSON(http.StatusOK, rma)
+}
+
+// CloseReturn godoc
+// @Summary Close a return
+// @Description Close an RMA before everything authorised has arrived; nothing may be left in quarantine
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param close body CloseReturnRequest false "Closing notes"
+// @Success 200 {object} models.ReturnAuthorization
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /returns/{id}/close [post]
+func (h *ReturnHandler) CloseReturn(c *gin.Context) {
+	h.finishReturn(c, models.ReturnStatusClosed)
+}
+
+// CancelReturn godoc
+// @Summary Cancel a return
+// @Description Cancel an RMA before anything has been received
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param close body CloseReturnRequest false "Cancellation notes"
+// @Success 200 {object} models.ReturnAuthorization
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /returns/{id}/cancel [post]
+func (h *ReturnHandler) CancelReturn(c *gin.Context) {
+	h.finishReturn(c, models.ReturnStatusCancelled)
+}
+
+// finishReturn moves an RMA to CLOSED or CANCELLED
+func (h *ReturnHandler) finishReturn(c *gin.Context, status string) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return ID"})
+		return
+	}
+
+	var req CloseReturnRequest
+	_ = c.ShouldBindJSON(&req)
+
+	var rma *models.ReturnAuthorization
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		rma, err = loadReturn(tx, id)
+		if err != nil {
+			return err
+		}
+
+		switch status {
+		case models.ReturnStatusClosed:
+			if rma.Status != models.ReturnStatusAuthorized && rma.Status != models.ReturnStatusReceived {
+				return conflictError("Return is not open")
+			}
+			for _, l := range rma.Lines {
+				if l.InQuarantine() > 0 {
+					return conflictError("Return still has goods in quarantine")
+				}
+			}
+		case models.ReturnStatusCancelled:
+			if rma.Status != models.ReturnStatusAuthorized {
+				return conflictError("Only returns with nothing received can be cancelled")
+			}
+		}
+
+		now := time.Now()
+		rma.Status = status
+		rma.ClosedAt = &now
+		if req.Notes != "" {
+			rma.Notes = req.Notes
+		}
+		rma.UpdatedBy = userID.(uint)
+		return tx.Omit("Lines").Save(rma).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rma)
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+)
+
+// RoleHandler manages roles, their permissions and which users hold them
+type RoleHandler struct {
+	db *gorm.DB
+}
+
+func NewRoleHandler(db *gorm.DB) *RoleHandler {
+	return &RoleHandler{db: db}
+}
+
+// RoleRequest holds the fields for creating or updating a role
+type RoleRequest struct {
+	Name        string   `json:"name" binding:"required,max=32"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// UserRoleResponse is a user and the role assigned to them
+type UserRoleResponse struct {
+	ID        uint   `json:"id"`
+	Email     string `json:"email"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	IsActive  bool   `json:"is_active"`
+	IsService bool   `json:"is_service"`
+}
+
+// hasPermission reports whether the user's role has a permission. It relies
+// on RequirePermission having loaded the permissions for the route.
+func hasPermission(c *gin.Context, permission string) bool {
+	v, _ := c.Get("permissions")
+	granted, _ := v.(map[string]bool)
+	return granted[permission]
+}
+
+// respondAccessError maps errors from user, role and service account changes
+// onto HTTP responses, reporting anything unexpected as fallback
+func respondAccessError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.As(err, new(validationError)):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, new(conflictError)):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, new(notFoundError)):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.As(err, new(forbiddenError)):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// validatePermissions rejects permissions the API does not know about
+func validatePermissions(permissions []string) error {
+	known := map[string]bool{}
+	for _, p := range models.Permissions() {
+		known[p] = true
+	}
+	for _, p := range permissions {
+		if !known[p] {
+			return validationError("Unknown permission: " + p)
+		}
+	}
+	return nil
+}
+
+// setRolePermissions replaces the permissions granted to a role
+func setRolePermissions(tx *gorm.DB, roleID uint, permissions []string) error {
+	if err := tx.Where("role_id = ?", roleID).Delete(&models.RolePermission{}).Error; err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for _, p := range permissions {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if err := tx.Create(&models.RolePermission{RoleID: roleID, Permission: p}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPermissions godoc
+// @Summary Get permissions
+// @Description List every permission that can be granted to a role (requires user:admin)
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} string
+// @Failure 403 {object} ErrorResponse
+// @Router /permissions [get]
+func (h *RoleHandler) GetPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, models.Permissions())
+}
+
+// GetRoles godoc
+// @Summary Get roles
+// @Description List roles with their permissions (requires user:admin)
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Role
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /roles [get]
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := h.db.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get roles"})
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+// CreateRole godoc
+// @Summary Create a role
+// @Description Create a custom role with a set of permissions (requires user:admin)
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body RoleRequest true "Role"
+// @Success 201 {object} models.Role
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /roles [post]
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role := models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedBy:   userID.(uint),
+		UpdatedBy:   userID.(uint),
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := validatePermissions(req.Permissions); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.Role{}).Where("name = ?", req.Name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return conflictError("Role already exists")
+		}
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		return setRolePermissions(tx, role.ID, req.Permissions)
+	})
+	if err != nil {
+		respondAccessError(c, err, "Failed to create role")
+		return
+	}
+
+	h.db.Preload("Permissions").First(&role, role.ID)
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole godoc
+// @Summary Update a role
+// @Description Change a role's description and replace its permissions (requires user:admin). The admin role cannot be changed and system roles cannot be renamed.
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Param request body RoleRequest true "Role"
+// @Success 200 {object} models.Role
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /roles/{id} [put]
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var role models.Role
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&role, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return notFoundError("Role not found")
+			}
+			return err
+		}
+		if role.Name == models.RoleAdmin {
+			return validationError("The admin role cannot be changed")
+		}
+		if role.IsSystem && req.Name != role.Name {
+			return validationError("System roles cannot be renamed")
+		}
+		if err := validatePermissions(req.Permissions); err != nil {
+			return err
+		}
+
+		if req.Name != role.Name {
+			var count int64
+			if err := tx.Model(&models.Role{}).Where("name = ?", req.Name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return conflictError("Role already exists")
+			}
+			// Users refer to their role by name
+			if err := tx.Model(&models.User{}).Where("role = ?", role.Name).Update("role", req.Name).Error; err != nil {
+				return err
+			}
+		}
+
+		role.Name = req.Name
+		role.Description = req.Description
+		role.UpdatedBy = userID.(uint)
+		if err := tx.Omit("Permissions").Save(&role).Error; err != nil {
+			return err
+		}
+		return setRolePermissions(tx, role.ID, req.Permissions)
+	})
+	if err != nil {
+		respondAccessError(c, err, "Failed to update role")
+		return
+	}
+
+	h.db.Preload("Permissions").First(&role, role.ID)
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole godoc
+// @Summary Delete a role
+// @Description Delete a custom role that no user holds (requires user:admin)
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Success 200 {object} MessageResponse "Role deleted successfully"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /roles/{id} [delete]
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.First(&role, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return notFoundError("Role not found")
+			}
+			return err
+		}
+		if role.IsSystem {
+			return validationError("System roles cannot be deleted")
+		}
+
+		var holders int64
+		if err := tx.Model(&models.User{}).Where("role = ?", role.Name).Count(&holders).Error; err != nil {
+			return err
+		}
+		if holders > 0 {
+			return conflictError("Role is still assigned to users")
+		}
+
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		respondAccessError(c, err, "Failed to delete role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// GetUsers godoc
+// @Summary Get users
+// @Description List users and their roles (requires user:admin)
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Param role query string false "Role name"
+// @Success 200 {array} UserRoleResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users [get]
+func (h *RoleHandler) GetUsers(c *gin.Context) {
+	query := h.db.Model(&models.User{})
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	users := []UserRoleResponse{}
+	if err := query.Order("id").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users"})
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
@@ -0,0 +1,607 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/cache"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const errSalesOrderNotFound = notFoundError("Sales order not found")
+
+// SalesOrderHandler holds dependencies for sales order handling
+type SalesOrderHandler struct {
+	db             *gorm.DB
+	cache          *redis.Client
+	reservationTTL time.Duration
+	ledger         LedgerSettings
+}
+
+// NewSalesOrderHandler creates the handler. reservationTTL is how long a new
+// order holds its stock when the request does not say; 0 means until the
+// order is fulfilled or cancelled.
+func NewSalesOrderHandler(db *gorm.DB, cache *redis.Client, reservationTTL time.Duration, ledger LedgerSettings) *SalesOrderHandler {
+	return &SalesOrderHandler{
+		db:             db,
+		cache:          cache,
+		reservationTTL: reservationTTL,
+		ledger:         ledger,
+	}
+}
+
+// SalesOrderLineRequest is one product ordered by the customer
+type SalesOrderLineRequest struct {
+	ProductID uint    `json:"product_id" binding:"required"`
+	Quantity  int     `json:"quantity" binding:"required,gt=0"`
+	UnitPrice float64 `json:"unit_price" binding:"min=0"` // optional, defaults to the product's unit price
+}
+
+// CreateSalesOrderRequest holds the fields for creating a sales order
+type CreateSalesOrderRequest struct {
+	CustomerName      string                  `json:"customer_name" binding:"required"`
+	CustomerReference string                  `json:"customer_reference"`
+	WarehouseID       uint                    `json:"warehouse_id"`       // optional, defaults to the default warehouse
+	ExpiresInMinutes  *int                    `json:"expires_in_minutes"` // optional, 0 never expires, omitted uses RESERVATION_TTL_MINUTES
+	Notes             string                  `json:"notes"`
+	Lines             []SalesOrderLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+// FulfillSalesOrderLineRequest is the quantity of a sales order line shipped
+type FulfillSalesOrderLineRequest struct {
+	LineID    uint     `json:"line_id" binding:"required"`
+	Quantity  int      `json:"quantity" binding:"required,gt=0"`
+	LotNumber string   `json:"lot_number"` // optional, ships this lot instead of FEFO
+	Serials   []string `json:"serials" binding:"omitempty,dive,required"`
+}
+
+// FulfillSalesOrderRequest ships some or all of an order; no lines ships everything outstanding
+type FulfillSalesOrderRequest struct {
+	Lines []FulfillSalesOrderLineRequest `json:"lines" binding:"omitempty,dive"`
+	Notes string                         `json:"notes"`
+}
+
+// CancelSalesOrderRequest cancels a sales order
+type CancelSalesOrderRequest struct {
+	Notes string `json:"notes"`
+}
+
+// loadSalesOrder locks a sales order and reads its lines (ordered by product) inside tx
+func loadSalesOrder(tx *gorm.DB, id int) (*models.SalesOrder, error) {
+	var order models.SalesOrder
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("product_id, id") }).
+		First(&order, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errSalesOrderNotFound
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// refreshSalesOrderStatus marks an open order FULFILLED once nothing is outstanding
+func refreshSalesOrderStatus(order *models.SalesOrder) {
+	outstanding := 0
+	fulfilled := 0
+	for _, l := range order.Lines {
+		outstanding += l.Outstanding()
+		fulfilled += l.QuantityFulfilled
+	}
+
+	switch {
+	case outstanding == 0:
+		now := time.Now()
+		order.Status = models.SalesOrderStatusFulfilled
+		order.ClosedAt = &now
+	case fulfilled > 0:
+		order.Status = models.SalesOrderStatusPartiallyFulfilled
+	default:
+		order.Status = models.SalesOrderStatusOpen
+	}
+}
+
+// releaseSalesOrder gives back everything an open order still holds and
+// moves it to status (CANCELLED or EXPIRED)
+func releaseSalesOrder(tx *gorm.DB, order *models.SalesOrder, status string, userID uint) error {
+	for _, l := range order.Lines {
+		if l.Outstanding() == 0 {
+			continue
+		}
+		if err := releaseStock(tx, l.ProductID, order.WarehouseID, l.Outstanding()); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	order.Status = status
+	order.ClosedAt = &now
+	order.UpdatedBy = userID
+	return tx.Omit("Lines").Save(order).Error
+}
+
+// unfulfillSalesOrderLine takes a reversed shipment off its sales order line.
+// An order that is still open reserves the returned quantity again; a
+// finished order is left as it is.
+func unfulfillSalesOrderLine(tx *gorm.DB, lineID uint, quantity int, userID uint) error {
+	var line models.SalesOrderLine
+	if err := tx.First(&line, lineID).Error; err != nil {
+		return err
+	}
+
+	order, err := loadSalesOrder(tx, int(line.SalesOrderID))
+	if err != nil {
+		return err
+	}
+	if !order.IsOpen() {
+		return nil
+	}
+
+	for i := range order.Lines {
+		if order.Lines[i].ID != lineID {
+			continue
+		}
+		quantity = min(quantity, order.Lines[i].QuantityFulfilled)
+		order.Lines[i].QuantityFulfilled -= quantity
+		if err := tx.Save(&order.Lines[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := reserveStock(tx, line.ProductID, order.WarehouseID, quantity); err != nil {
+		return err
+	}
+
+	refreshSalesOrderStatus(order)
+	order.UpdatedBy = userID
+	return tx.Omit("Lines").Save(order).Error
+}
+
+// CreateSalesOrder godoc
+// @Summary Create a sales order
+// @Description Create a sales order and reserve its stock at the warehouse without moving it
+// @Tags sales-orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param order body CreateSalesOrderRequest true "Sales order details"
+// @Success 201 {object} models.SalesOrder
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /sales-orders [post]
+func (h *SalesOrderHandler) CreateSalesOrder(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req CreateSalesOrderRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ttl := h.reservationTTL
+	if req.ExpiresInMinutes != nil {
+		if *req.ExpiresInMinutes < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_minutes must not be negative"})
+			return
+		}
+		ttl = time.Duration(*req.ExpiresInMinutes) * time.Minute
+	}
+
+	// Reserve in a stable product order so concurrent orders cannot deadlock
+	lines := append([]SalesOrderLineRequest(nil), req.Lines...)
+	sort.Slice(lines, func(i, j int) bool { return lines[i].ProductID < lines[j].ProductID })
+
+	var order models.SalesOrder
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		warehouse, err := resolveWarehouse(tx, req.WarehouseID)
+		if err != nil {
+			return err
+		}
+
+		order = models.SalesOrder{
+			CustomerName:      req.CustomerName,
+			CustomerReference: req.CustomerReference,
+			WarehouseID:       warehouse.ID,
+			Status:            models.SalesOrderStatusOpen,
+			Notes:             req.Notes,
+			CreatedBy:         userID.(uint),
+			UpdatedBy:         userID.(uint),
+		}
+		if ttl > 0 {
+			expiresAt := time.Now().Add(ttl)
+			order.ExpiresAt = &expiresAt
+		}
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+
+		order.SONumber = fmt.Sprintf("SO-%06d", order.ID)
+		if err := tx.Model(&order).Update("so_number", order.SONumber).Error; err != nil {
+			return err
+		}
+
+		for i, l := range lines {
+			if i > 0 && lines[i-1].ProductID == l.ProductID {
+				return validationError(fmt.Sprintf("Product %d appears on more than one line", l.ProductID))
+			}
+
+			if err := reserveStock(tx, l.ProductID, warehouse.ID, l.Quantity); err != nil {
+				return err
+			}
+
+			unitPrice := l.UnitPrice
+			if unitPrice == 0 {
+				if err := tx.Model(&models.Product{}).Where("id = ?", l.ProductID).
+					Select("unit_price").Scan(&unitPrice).Error; err != nil {
+					return err
+				}
+			}
+
+			line := models.SalesOrderLine{
+				SalesOrderID:    order.ID,
+				ProductID:       l.ProductID,
+				QuantityOrdered: l.Quantity,
+				UnitPrice:       unitPrice,
+			}
+			if err := tx.Create(&line).Error; err != nil {
+				return err
+			}
+			order.Lines = append(order.Lines, line)
+		}
+		return nil
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	if order.ExpiresAt != nil {
+		cache.ScheduleReservationExpiry(h.cache, c.Request.Context(), order.ID, *order.ExpiresAt)
+	}
+	// Available-to-promise is part of the stock list
+	invalidateStockCaches(h.cache, c.Request.Context())
+
+	c.JSON(http.StatusCreated, order)
+}
+
+// GetSalesOrders godoc
+// @Summary Get sales orders
+// @Description List sales orders, optionally filtered by status or warehouse
+// @Tags sales-orders
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Param status query string false "OPEN, PARTIALLY_FULFILLED, FULFILLED, CANCELLED or EXPIRED"
+// @Param warehouse_id query int false "Warehouse filter"
+// @Success 200 {object} map[string]interface{}
+// @Router /sales-orders [get]
+func (h *SalesOrderHandler) GetSalesOrders(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	status := c.Query("status")
+	warehouseID := c.Query("warehouse_id")
+	offset := (page - 1) * limit
+
+	query := h.db.Model(&models.SalesOrder{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+	}
+
+	var total int64
+	var orders []models.SalesOrder
+	query.Count(&total)
+	query.Preload("Lines").Offset(offset).Limit(limit).Order("created_at DESC").Find(&orders)
+
+	c.JSON(http.StatusOK, gin.H{
+		"sales_orders": orders,
+		"total":        total,
+		"page":         page,
+		"limit":        limit,
+	})
+}
+
+// GetSalesOrderByID godoc
+// @Summary Get a sales order
+// @Description Get a sales order with its lines and the shipments posted against it
+// @Tags sales-orders
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Sales order ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /sales-orders/{id} [get]
+func (h *SalesOrderHandler) GetSalesOrderByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sales order ID"})
+		return
+	}
+
+	var order models.SalesOrder
+	if err := h.db.Preload("Lines.Product").Preload("Warehouse").First(&order, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sales order not found"})
+		return
+	}
+
+	lineIDs := make([]uint, 0, len(order.Lines))
+	for _, l := range order.Lines {
+		lineIDs = append(lineIDs, l.ID)
+	}
+
+	// Shipments and their reversals
+	var movements []models.Stock
+	h.db.Preload("Lots.Lot").Preload("Serials.Serial").Where("so_line_id IN ?", lineIDs).Order("created_at").Find(&movements)
+
+	c.JSON(http.StatusOK, gin.H{
+		"sales_order": order,
+		"movements":   movements,
+	})
+}
+
+// FulfillSalesOrder godoc
+// @Summary Fulfill a sales order
+// @Description Ship reserved stock, converting the reservation into OUT movements referenced by the SO number. Without lines every outstanding quantity is shipped.
+// @Tags sales-orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Sales order ID"
+// @Param fulfillment body FulfillSalesOrderRequest false "Shipped quantities"
+// @Success 200 {object} models.SalesOrder
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /sales-orders/{id}/fulfill [post]
+func (h *SalesOrderHandler) FulfillSalesOrder(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sales order ID"})
+		return
+	}
+
+	var req FulfillSalesOrderRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var order *models.SalesOrder
+	var productIDs []uint
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = loadSalesOrder(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if !order.IsOpen() {
+			return conflictError("Sales order is not open")
+		}
+
+		shipments := req.Lines
+		if len(shipments) == 0 {
+			for _, l := range order.Lines {
+				if l.Outstanding() > 0 {
+					shipments = append(shipments, FulfillSalesOrderLineRequest{LineID: l.ID, Quantity: l.Outstanding()})
+				}
+			}
+		}
+
+		lineIndex := make(map[uint]int, len(order.Lines))
+		for i, l := range order.Lines {
+			lineIndex[l.ID] = i
+		}
+		for _, s := range shipments {
+			if _, ok := lineIndex[s.LineID]; !ok {
+				return validationError(fmt.Sprintf("Line %d does not belong to this sales order", s.LineID))
+			}
+		}
+
+		// Post in product order so locks are taken in a stable order
+		shipments = append([]FulfillSalesOrderLineRequest(nil), shipments...)
+		sort.SliceStable(shipments, func(i, j int) bool { return lineIndex[shipments[i].LineID] < lineIndex[shipments[j].LineID] })
+
+		for _, s := range shipments {
+			line := &order.Lines[lineIndex[s.LineID]]
+			if s.Quantity > line.Outstanding() {
+				return validationError(fmt.Sprintf("Line %d has only %d outstanding", line.ID, line.Outstanding()))
+			}
+
+			if _, err := postStockMovement(tx, h.ledger, stockMovementInput{
+				ProductID:   line.ProductID,
+				WarehouseID: order.WarehouseID,
+				Type:        "OUT",
+				Quantity:    s.Quantity,
+				Reference:   order.SONumber,
+				SOLineID:    &line.ID,
+				Notes:       req.Notes,
+				UserID:      userID.(uint),
+				Reservation: line.Outstanding(),
+				LotNumber:   s.LotNumber,
+				Serials:     s.Serials,
+			}); err != nil {
+				return err
+			}
+
+			line.QuantityFulfilled += s.Quantity
+			if err := tx.Save(line).Error; err != nil {
+				return err
+			}
+			productIDs = append(productIDs, line.ProductID)
+		}
+
+		refreshSalesOrderStatus(order)
+		order.UpdatedBy = userID.(uint)
+		return tx.Omit("Lines").Save(order).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	if !order.IsOpen() {
+		cache.ClearReservationExpiry(h.cache, c.Request.Context(), order.ID)
+	}
+	invalidateStockCaches(h.cache, c.Request.Context(), productIDs...)
+
+	c.JSON(http.StatusOK, order)
+}
+
+// CancelSalesOrder godoc
+// @Summary Cancel a sales order
+// @Description Cancel an open sales order, releasing whatever it still has reserved
+// @Tags sales-orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Sales order ID"
+// @Param cancel body CancelSalesOrderRequest false "Cancellation notes"
+// @Success 200 {object} models.SalesOrder
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /sales-orders/{id}/cancel [post]
+func (h *SalesOrderHandler) CancelSalesOrder(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sales order ID"})
+		return
+	}
+
+	var req CancelSalesOrderRequest
+	_ = c.ShouldBindJSON(&req)
+
+	var order *models.SalesOrder
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = loadSalesOrder(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if !order.IsOpen() {
+			return conflictError("Sales order is not open")
+		}
+
+		if req.Notes != "" {
+			order.Notes = req.Notes
+		}
+		return releaseSalesOrder(tx, order, models.SalesOrderStatusCancelled, userID.(uint))
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	cache.ClearReservationExpiry(h.cache, c.Request.Context(), order.ID)
+	invalidateStockCaches(h.cache, c.Request.Context())
+
+	c.JSON(http.StatusOK, order)
+}
+
+// ExpireReservations releases the stock held by sales orders whose
+// reservation has passed its expiry time. Expiry times are scheduled in a
+// Redis sorted set, rebuilt from the database on start; each run also checks
+// the database, so orders created while Redis was unavailable still expire.
+// It runs until ctx is done.
+func (h *SalesOrderHandler) ExpireReservations(ctx context.Context, interval time.Duration) {
+	var pending []models.SalesOrder
+	h.db.Where("status IN ? AND expires_at IS NOT NULL",
+		[]string{models.SalesOrderStatusOpen, models.SalesOrderStatusPartiallyFulfilled}).Find(&pending)
+	for _, o := range pending {
+		cache.ScheduleReservationExpiry(h.cache, ctx, o.ID, *o.ExpiresAt)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.expireDueReservations(ctx)
+		}
+	}
+}
+
+// expireDueReservations expires every order that is due now. Orders are found
+// through the Redis schedule and in the database, so an order whose expiry
+// never reached Redis still expires.
+func (h *SalesOrderHandler) expireDueReservations(ctx context.Context) {
+	now := time.Now()
+	ids, err := cache.DueReservationExpiries(h.cache, ctx, now)
+	if err != nil {
+		log.Printf("Warning: Failed to read reservation expiries: %v", err)
+	}
+	var overdue []uint
+	if err := h.db.Model(&models.SalesOrder{}).
+		Where("status IN ? AND expires_at <= ?",
+			[]string{models.SalesOrderStatusOpen, models.SalesOrderStatusPartiallyFulfilled}, now).
+		Pluck("id", &overdue).Error; err != nil {
+		log.Printf("Warning: Failed to find expired sales orders: %v", err)
+	}
+
+	expired := false
+	seen := map[uint]bool{}
+	for _, id := range append(ids, overdue...) {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		var notYetDue *time.Time
+		err := h.db.Transaction(func(tx *gorm.DB) error {
+			order, err := loadSalesOrder(tx, int(id))
+			if err != nil {
+				return err
+			}
+			// Already finished or never expires
+			if !order.IsOpen() || order.ExpiresAt == nil {
+				return nil
+			}
+			// The schedule is kept in whole seconds
+			if order.ExpiresAt.After(time.Now()) {
+				notYetDue = order.ExpiresAt
+				return nil
+			}
+			expired = true
+			return releaseSalesOrder(tx, order, models.SalesOrderStatusExpired, order.UpdatedBy)
+		})
+		if err != nil && !errors.Is(err, errSalesOrderNotFound) {
+			log.Printf("Warning: Failed to expire sales order %d: %v", id, err)
+			continue
+		}
+		if notYetDue != nil {
+			cache.ScheduleReservationExpiry(h.cache, ctx, id, notYetDue.Add(time.Second))
+			continue
+		}
+		cache.ClearReservationExpiry(h.cache, ctx, id)
+	}
+
+	if expired {
+		invalidateStockCaches(h.cache, ctx)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+)
+
+// TestExpireReservationsWithoutRedis creates an order while Redis is down, so
+// its expiry is never scheduled, and checks that it still expires
+func TestExpireReservationsWithoutRedis(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, models.RoleUser)
+	product := createTestProduct(t, db, user.ID)
+	if _, err := postTestMovement(db, stockMovementInput{ProductID: product.ID, Type: "IN", Quantity: 10, UserID: user.ID}); err != nil {
+		t.Fatalf("stock in: %v", err)
+	}
+
+	h := NewSalesOrderHandler(db, unreachableRedis(t), time.Hour, LedgerSettings{})
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/sales-orders", func(c *gin.Context) {
+		c.Set("userID", user.ID)
+		h.CreateSalesOrder(c)
+	})
+
+	body, _ := json.Marshal(CreateSalesOrderRequest{
+		CustomerName: "Customer",
+		Lines:        []SalesOrderLineRequest{{ProductID: product.ID, Quantity: 4}},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/sales-orders", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create sales order: status %d, want 201: %s", w.Code, w.Body)
+	}
+	var order models.SalesOrder
+	if err := json.Unmarshal(w.Body.Bytes(), &order); err != nil {
+		t.Fatalf("decode order: %v", err)
+	}
+
+	reserved := func() int {
+		var balance models.StockBalance
+		db.Where("product_id = ? AND warehouse_id = ?", product.ID, order.WarehouseID).First(&balance)
+		return balance.ReservedQuantity
+	}
+	if got := reserved(); got != 4 {
+		t.Fatalf("reserved = %d, want 4", got)
+	}
+
+	// Not due yet: nothing happens
+	h.expireDueReservations(context.Background())
+	db.First(&order, order.ID)
+	if order.Status != models.SalesOrderStatusOpen {
+		t.Fatalf("order is %s before its expiry, want OPEN", order.Status)
+	}
+
+	db.Model(&order).Update("expires_at", time.Now().Add(-time.Minute))
+	h.expireDueReservations(context.Background())
+	db.First(&order, order.ID)
+	if order.Status != models.SalesOrderStatusExpired {
+		t.Errorf("order is %s after its expiry, want %s", order.Status, models.SalesOrderStatusExpired)
+	}
+	if got := reserved(); got != 0 {
+		t.Errorf("reserved after expiry = %d, want 0", got)
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+)
+
+// SerialHandler holds dependencies for serial number lookups
+type SerialHandler struct {
+	db *gorm.DB
+}
+
+func NewSerialHandler(db *gorm.DB) *SerialHandler {
+	return &SerialHandler{
+		db: db,
+	}
+}
+
+// GetSerial godoc
+// @Summary Look up a serial number
+// @Description Get the product, current status and full movement chain of a serialized unit
+// @Tags serials
+// @Produce json
+// @Security BearerAuth
+// @Param serial path string true "Serial number"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} ErrorResponse
+// @Router /serials/{serial} [get]
+func (h *SerialHandler) GetSerial(c *gin.Context) {
+	var serial models.Serial
+	if err := h.db.Preload("Product").Preload("Warehouse").
+		Where("serial_number = ?", c.Param("serial")).
+		First(&serial).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Serial not found"})
+		return
+	}
+
+	var movements []models.Stock
+	h.db.Preload("Warehouse").
+		Where("id IN (?)", h.db.Model(&models.StockSerial{}).Select("stock_id").Where("serial_id = ?", serial.ID)).
+		Order("created_at, id").
+		Find(&movements)
+
+	c.JSON(http.StatusOK, gin.H{
+		"serial":    serial,
+		"movements": movements,
+	})
+}
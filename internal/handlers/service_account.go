@@ -0,0 +1,480 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/apikey"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// serviceAccountDomain is the email domain of service accounts. .invalid can
+// never receive mail or match an address from Google or an OIDC provider.
+const serviceAccountDomain = "service-accounts.invalid"
+
+// ServiceAccountHandler manages service accounts and their API keys
+type ServiceAccountHandler struct {
+	db     *gorm.DB
+	maxTTL time.Duration
+}
+
+func NewServiceAccountHandler(db *gorm.DB, maxTTL time.Duration) *ServiceAccountHandler {
+	return &ServiceAccountHandler{db: db, maxTTL: maxTTL}
+}
+
+// ServiceAccountRequest holds the fields for creating a service account
+type ServiceAccountRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Role string `json:"role" binding:"required,max=32"`
+}
+
+// APIKeyRequest holds the fields for issuing an API key. Scopes must be
+// permissions the service account's role has.
+type APIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1"` // defaults to API_KEY_MAX_TTL_DAYS
+}
+
+// RotateAPIKeyRequest holds how long the replaced key keeps working
+type RotateAPIKeyRequest struct {
+	GracePeriodHours int `json:"grace_period_hours" binding:"omitempty,min=0,max=168"` // 0 revokes it at once
+}
+
+// APIKeyResponse is a newly issued API key. Key is only ever returned here.
+type APIKeyResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// scopeList normalises comma-separated scopes
+func scopeList(scopes string) []string {
+	list := []string{}
+	for _, s := range strings.Split(scopes, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// rolePermissionSet returns the permissions a role grants
+func rolePermissionSet(tx *gorm.DB, role string) (map[string]bool, error) {
+	var granted []string
+	if err := tx.Table("role_permissions").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.name = ?", role).
+		Pluck("role_permissions.permission", &granted).Error; err != nil {
+		return nil, err
+	}
+	has := make(map[string]bool, len(granted))
+	for _, p := range granted {
+		has[p] = true
+	}
+	return has, nil
+}
+
+// validateScopes rejects scopes the API does not know or the role lacks
+func validateScopes(tx *gorm.DB, role string, scopes []string) error {
+	if err := validatePermissions(scopes); err != nil {
+		return err
+	}
+	has, err := rolePermissionSet(tx, role)
+	if err != nil {
+		return err
+	}
+	for _, s := range scopes {
+		if !has[s] {
+			return validationError("Role " + role + " does not have permission " + s)
+		}
+	}
+	return nil
+}
+
+// issueAPIKey creates a key for a service account and returns it with the raw key
+func issueAPIKey(tx *gorm.DB, userID uint, name string, scopes []string, expiresAt time.Time, createdBy uint, rotatedFrom *uint) (APIKeyResponse, error) {
+	raw, publicID, hash, err := apikey.Generate()
+	if err != nil {
+		return APIKeyResponse{}, err
+	}
+
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, s := range scopes {
+		if !seen[s] {
+			seen[s] = true
+			unique = append(unique, s)
+		}
+	}
+
+	key := models.APIKey{
+		UserID:      userID,
+		Name:        name,
+		PublicID:    publicID,
+		KeyHash:     hash,
+		Scopes:      strings.Join(unique, ","),
+		ExpiresAt:   &expiresAt,
+		RotatedFrom: rotatedFrom,
+		CreatedBy:   createdBy,
+		UpdatedBy:   createdBy,
+	}
+	if err := tx.Create(&key).Error; err != nil {
+		return APIKeyResponse{}, err
+	}
+	return APIKeyResponse{APIKey: key, Key: raw}, nil
+}
+
+// findServiceAccount loads an active service account, locking it
+func findServiceAccount(tx *gorm.DB, id int) (models.User, error) {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("is_service = ?", true).First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, notFoundError("Service account not found")
+		}
+		return user, err
+	}
+	if !user.IsActive {
+		return user, validationError("Service account is disabled")
+	}
+	return user, nil
+}
+
+// GetServiceAccounts godoc
+// @Summary Get service accounts
+// @Description List service accounts (requires user:admin)
+// @Tags service-accounts
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} UserRoleResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /service-accounts [get]
+func (h *ServiceAccountHandler) GetServiceAccounts(c *gin.Context) {
+	accounts := []UserRoleResponse{}
+	if err := h.db.Model(&models.User{}).Where("is_service = ?", true).Order("id").Find(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get service accounts"})
+		return
+	}
+	c.JSON(http.StatusOK, accounts)
+}
+
+// CreateServiceAccount godoc
+// @Summary Create a service account
+// @Description Create a user for machine clients. Service accounts cannot log in and authenticate with API keys only (requires user:admin).
+// @Tags service-accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ServiceAccountRequest true "Service account"
+// @Success 201 {object} UserRoleResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /service-accounts [post]
+func (h *ServiceAccountHandler) CreateServiceAccount(c *gin.Context) {
+	var req ServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create service account"})
+		return
+	}
+
+	user := models.User{
+		Email:     "svc-" + hex.EncodeToString(suffix) + "@" + serviceAccountDomain,
+		Name:      req.Name,
+		Role:      req.Role,
+		IsActive:  true,
+		IsService: true,
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var roles int64
+		if err := tx.Model(&models.Role{}).Where("name = ?", req.Role).Count(&roles).Error; err != nil {
+			return err
+		}
+		if roles == 0 {
+			return validationError("Role not found")
+		}
+		return tx.Create(&user).Error
+	})
+	if err != nil {
+		respondAccessError(c, err, "Failed to create service account")
+		return
+	}
+
+	c.JSON(http.StatusCreated, UserRoleResponse{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Role:      user.Role,
+		IsActive:  user.IsActive,
+		IsService: user.IsService,
+	})
+}
+
+// DeleteServiceAccount godoc
+// @Summary Disable a service account
+// @Description Deactivate a service account and revoke all of its API keys (requires user:admin)
+// @Tags service-accounts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Success 200 {object} MessageResponse "Service account disabled"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /service-accounts/{id} [delete]
+func (h *ServiceAccountHandler) DeleteServiceAccount(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service account ID"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		user, err := findServiceAccount(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.APIKey{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Updates(map[string]interface{}{"revoked_at": time.Now(), "updated_by": userID}).Error
+	})
+	if err != nil {
+		respondAccessError(c, err, "Failed to delete service account")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Service account disabled"})
+}
+
+// GetAPIKeys godoc
+// @Summary Get a service account's API keys
+// @Description List the API keys of a service account, including revoked and expired ones. Keys themselves are never shown again (requires user:admin).
+// @Tags service-accounts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Success 200 {array} models.APIKey
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /service-accounts/{id}/api-keys [get]
+func (h *ServiceAccountHandler) GetAPIKeys(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service account ID"})
+		return
+	}
+
+	keys := []models.APIKey{}
+	if err := h.db.Where("user_id = ?", id).Order("id DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get API keys"})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Issue an API key for a service account. The key is returned once and only its hash is stored; send it as X-API-Key or as a Bearer token (requires user:admin).
+// @Tags service-accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Param request body APIKeyRequest true "API key"
+// @Success 201 {object} APIKeyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /service-accounts/{id}/api-keys [post]
+func (h *ServiceAccountHandler) CreateAPIKey(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service account ID"})
+		return
+	}
+
+	var req APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ttl := h.maxTTL
+	if req.ExpiresInDays > 0 {
+		ttl = time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	}
+	if ttl > h.maxTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days exceeds the maximum of " + strconv.Itoa(int(h.maxTTL.Hours()/24))})
+		return
+	}
+
+	var resp APIKeyResponse
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		user, err := findServiceAccount(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := validateScopes(tx, user.Role, req.Scopes); err != nil {
+			return err
+		}
+		resp, err = issueAPIKey(tx, user.ID, req.Name, req.Scopes, time.Now().Add(ttl), userID.(uint), nil)
+		return err
+	})
+	if err != nil {
+		respondAccessError(c, err, "Failed to create API key")
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// RotateAPIKey godoc
+// @Summary Rotate an API key
+// @Description Replace an API key with a new one with the same name, scopes and lifetime. The old key stops working at once or after the grace period (requires user:admin).
+// @Tags service-accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "API key ID"
+// @Param request body RotateAPIKeyRequest false "Grace period"
+// @Success 201 {object} APIKeyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api-keys/{id}/rotate [post]
+func (h *ServiceAccountHandler) RotateAPIKey(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	var req RotateAPIKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var resp APIKeyResponse
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var old models.APIKey
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&old, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return notFoundError("API key not found")
+			}
+			return err
+		}
+		now := time.Now()
+		if old.RevokedAt != nil || (old.ExpiresAt != nil && now.After(*old.ExpiresAt)) {
+			return validationError("Only a live API key can be rotated")
+		}
+
+		user, err := findServiceAccount(tx, int(old.UserID))
+		if err != nil {
+			return err
+		}
+		// Scopes the role has lost since the key was issued are dropped
+		has, err := rolePermissionSet(tx, user.Role)
+		if err != nil {
+			return err
+		}
+		scopes := []string{}
+		for _, s := range scopeList(old.Scopes) {
+			if has[s] {
+				scopes = append(scopes, s)
+			}
+		}
+		if len(scopes) == 0 {
+			return validationError("Role " + user.Role + " no longer has any of the key's scopes")
+		}
+
+		ttl := h.maxTTL
+		if old.ExpiresAt != nil && old.ExpiresAt.Sub(old.CreatedAt) < ttl {
+			ttl = old.ExpiresAt.Sub(old.CreatedAt)
+		}
+		resp, err = issueAPIKey(tx, user.ID, old.Name, scopes, now.Add(ttl), userID.(uint), &old.ID)
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"updated_by": userID}
+		if req.GracePeriodHours == 0 {
+			updates["revoked_at"] = now
+		} else if graceEnd := now.Add(time.Duration(req.GracePeriodHours) * time.Hour); old.ExpiresAt == nil || graceEnd.Before(*old.ExpiresAt) {
+			updates["expires_at"] = graceEnd
+		}
+		return tx.Model(&old).Updates(updates).Error
+	})
+	if err != nil {
+		respondAccessError(c, err, "Failed to rotate API key")
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revoke an API key immediately (requires user:admin)
+// @Tags service-accounts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "API key ID"
+// @Success 200 {object} MessageResponse "API key revoked"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api-keys/{id} [delete]
+func (h *ServiceAccountHandler) RevokeAPIKey(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	result := h.db.Model(&models.APIKey{}).Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "updated_by": userID})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		h.db.Model(&models.APIKey{}).Where("id = ?", id).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
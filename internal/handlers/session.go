@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/cache"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInvalidRefreshToken = errors.New("Invalid refresh token")
+
+// RefreshRequest holds the refresh token to exchange
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// randomToken returns n random bytes encoded for use in URLs and headers
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how refresh tokens are stored and looked up
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateToken issues a short-lived access token for a session. The jti
+// lets a single token be revoked and sid the whole session.
+func (h *AuthHandler) generateToken(user models.User, sessionID string) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id": user.ID,
+		"email":   user.Email,
+		"role":    user.Role,
+		"jti":     jti,
+		"sid":     sessionID,
+		"iat":     now.Unix(),
+		"exp":     now.Add(h.config.AccessTokenTTL).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(h.config.JWTSecret))
+}
+
+// issueRefreshToken stores the next refresh token of a session and returns it
+func (h *AuthHandler) issueRefreshToken(tx *gorm.DB, c *gin.Context, userID uint, familyID string) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	record := models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(h.config.RefreshTokenTTL),
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// startSession opens a new session for a user who has just authenticated and
+// returns its first access and refresh tokens
+func (h *AuthHandler) startSession(c *gin.Context, user models.User) (AuthResponse, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	refreshToken, err := h.issueRefreshToken(h.db, c, user.ID, familyID)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	accessToken, err := h.generateToken(user, familyID)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+
+	return AuthResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.config.AccessTokenTTL.Seconds()),
+		User: &UserResponse{
+			ID:    user.ID,
+			Email: user.Email,
+			Name:  user.Name,
+			Role:  user.Role,
+		},
+	}, nil
+}
+
+// revokeSessions marks every live refresh token of the given sessions revoked
+func revokeSessions(tx *gorm.DB, familyIDs []string, reason string) error {
+	if len(familyIDs) == 0 {
+		return nil
+	}
+	return tx.Model(&models.RefreshToken{}).
+		Where("family_id IN ? AND revoked_at IS NULL", familyIDs).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason}).Error
+}
+
+// revokeSessionAccess makes the access tokens already issued to the given
+// sessions unusable. They expire within AccessTokenTTL, so the keys do too.
+func (h *AuthHandler) revokeSessionAccess(ctx context.Context, familyIDs ...string) error {
+	for _, id := range familyIDs {
+		if err := h.cache.Set(ctx, cache.GenerateRevokedSessionKey(id), "1", h.config.AccessTokenTTL).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeCurrentToken puts the access token of this request on the revocation list until it expires
+func (h *AuthHandler) revokeCurrentToken(c *gin.Context) error {
+	jti := c.GetString("tokenID")
+	if jti == "" {
+		return nil
+	}
+	ttl := time.Until(c.GetTime("tokenExpiresAt"))
+	if ttl <= 0 {
+		return nil
+	}
+	return h.cache.Set(c.Request.Context(), cache.GenerateRevokedTokenKey(jti), "1", ttl).Err()
+}
+
+// Refresh godoc
+// @Summary Refresh an access token
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token works once; presenting one that was already used revokes the whole session.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest true "Refresh token"
+// @Success 200 {object} AuthResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var user models.User
+	var familyID, refreshToken, reusedFamily string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var token models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(req.RefreshToken)).First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidRefreshToken
+			}
+			return err
+		}
+
+		now := time.Now()
+		switch {
+		case token.RevokedAt != nil:
+			return errInvalidRefreshToken
+		case token.UsedAt != nil:
+			// Only a copy of a rotated token can be presented twice; stop both holders
+			reusedFamily = token.FamilyID
+			return revokeSessions(tx, []string{token.FamilyID}, models.TokenRevokedReuse)
+		case now.After(token.ExpiresAt):
+			return errInvalidRefreshToken
+		}
+
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			return errInvalidRefreshToken
+		}
+		if !user.IsActive {
+			return errInvalidRefreshToken
+		}
+
+		if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		familyID = token.FamilyID
+		var err error
+		refreshToken, err = h.issueRefreshToken(tx, c, user.ID, familyID)
+		return err
+	})
+	if errors.Is(err, errInvalidRefreshToken) {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to refresh token"})
+		return
+	}
+
+	if reusedFamily != "" {
+		log.Printf("Warning: Refresh token reuse detected, session %s revoked", reusedFamily)
+		if err := h.revokeSessionAccess(c.Request.Context(), reusedFamily); err != nil {
+			log.Printf("Warning: Failed to revoke access tokens of session %s: %v", reusedFamily, err)
+		}
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Refresh token reuse detected, session revoked"})
+		return
+	}
+
+	accessToken, err := h.generateToken(user, familyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{
+		Message:      "Token refreshed successfully",
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.config.AccessTokenTTL.Seconds()),
+		User: &UserResponse{
+			ID:    user.ID,
+			Email: user.Email,
+			Name:  user.Name,
+			Role:  user.Role,
+		},
+	})
+}
+
+// Logout godoc
+// @Summary Log out
+// @Description Revoke the current session: its refresh token and every access token issued to it
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} MessageResponse "Logged out successfully"
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	sessionID := c.GetString("sessionID")
+
+	if sessionID != "" {
+		if err := revokeSessions(h.db, []string{sessionID}, models.TokenRevokedLogout); err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to log out"})
+			return
+		}
+		if err := h.revokeSessionAccess(c.Request.Context(), sessionID); err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to log out"})
+			return
+		}
+	}
+	if err := h.revokeCurrentToken(c); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll godoc
+// @Summary Log out of all sessions
+// @Description Revoke every session of the current user on every device
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /logout/all [post]
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var familyIDs []string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+			Distinct().Pluck("family_id", &familyIDs).Error; err != nil {
+			return err
+		}
+		return revokeSessions(tx, familyIDs, models.TokenRevokedLogoutAll)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to log out"})
+		return
+	}
+
+	sessions := len(familyIDs)
+
+	// The current session may have no live refresh token left, revoke its access tokens anyway
+	if sessionID := c.GetString("sessionID"); sessionID != "" {
+		familyIDs = append(familyIDs, sessionID)
+	}
+	if err := h.revokeSessionAccess(c.Request.Context(), familyIDs...); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to log out"})
+		return
+	}
+	if err := h.revokeCurrentToken(c); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions", "sessions": sessions})
+}
+
+// PurgeRefreshTokens deletes expired refresh tokens every interval until ctx
+// is cancelled. An expired token is rejected whether or not its row exists.
+func (h *AuthHandler) PurgeRefreshTokens(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.db.Where("expires_at < ?", time.Now()).Delete(&models.RefreshToken{}).Error; err != nil {
+				log.Printf("Warning: Failed to purge refresh tokens: %v", err)
+			}
+		}
+	}
+}
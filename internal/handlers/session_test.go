@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/config"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/testutil"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/cache"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// sessionTest drives the refresh and logout endpoints of one AuthHandler.
+// The logout routes trust the user and session set by the test, standing in
+// for ValidateJWT.
+type sessionTest struct {
+	t      *testing.T
+	db     *gorm.DB
+	h      *AuthHandler
+	router *gin.Engine
+
+	userID    uint
+	sessionID string
+}
+
+func newSessionTest(t *testing.T, db *gorm.DB, redisClient *redis.Client) *sessionTest {
+	s := &sessionTest{t: t, db: db}
+	s.h = NewAuthHandler(db, redisClient, &config.Config{
+		JWTSecret:       "session-test-secret",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: time.Hour,
+	})
+
+	gin.SetMode(gin.TestMode)
+	s.router = gin.New()
+	s.router.POST("/refresh", s.h.Refresh)
+	authenticated := s.router.Group("", func(c *gin.Context) {
+		c.Set("userID", s.userID)
+		c.Set("sessionID", s.sessionID)
+		c.Next()
+	})
+	authenticated.POST("/logout/all", s.h.LogoutAll)
+	return s
+}
+
+// login opens a session for user as a successful sign-in would
+func (s *sessionTest) login(user models.User) AuthResponse {
+	s.t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/login", nil)
+	resp, err := s.h.startSession(c, user)
+	if err != nil {
+		s.t.Fatalf("start session: %v", err)
+	}
+	return resp
+}
+
+func (s *sessionTest) refresh(token string) (int, AuthResponse) {
+	s.t.Helper()
+	body, _ := json.Marshal(RefreshRequest{RefreshToken: token})
+	req := httptest.NewRequest(http.MethodPost, "/refresh", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	var resp AuthResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			s.t.Fatalf("decode refresh response: %v", err)
+		}
+	}
+	return w.Code, resp
+}
+
+// tokenRecord loads the stored row of a refresh token
+func (s *sessionTest) tokenRecord(token string) models.RefreshToken {
+	s.t.Helper()
+	var record models.RefreshToken
+	if err := s.db.Where("token_hash = ?", hashToken(token)).First(&record).Error; err != nil {
+		s.t.Fatalf("load refresh token: %v", err)
+	}
+	return record
+}
+
+// unreachableRedis is a client for tests whose Redis writes may fail
+func unreachableRedis(t *testing.T) *redis.Client {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// TestRefreshRotatesToken checks that a refresh token works once and is
+// replaced by the next token of the same session
+func TestRefreshRotatesToken(t *testing.T) {
+	db := openTestDB(t)
+	s := newSessionTest(t, db, unreachableRedis(t))
+	user := createTestUser(t, db, models.RoleUser)
+
+	first := s.login(user)
+	code, second := s.refresh(first.RefreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("refresh: status %d, want 200", code)
+	}
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh returned refresh token %q, want a new one", second.RefreshToken)
+	}
+	if second.Token == "" || second.User == nil || second.User.ID != user.ID {
+		t.Errorf("refresh returned no access token for user %d", user.ID)
+	}
+
+	old, next := s.tokenRecord(first.RefreshToken), s.tokenRecord(second.RefreshToken)
+	if old.UsedAt == nil {
+		t.Error("rotated token was not marked used")
+	}
+	if next.FamilyID != old.FamilyID {
+		t.Errorf("next token is in session %s, want %s", next.FamilyID, old.FamilyID)
+	}
+
+	if code, _ := s.refresh(second.RefreshToken); code != http.StatusOK {
+		t.Errorf("refresh with the new token: status %d, want 200", code)
+	}
+}
+
+// TestRefreshReuseRevokesSession presents a rotated token again and checks
+// that the whole session, including the token that replaced it, is revoked
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	db := openTestDB(t)
+	s := newSessionTest(t, db, unreachableRedis(t))
+	user := createTestUser(t, db, models.RoleUser)
+
+	first := s.login(user)
+	other := s.login(user) // another device, must not be affected
+	code, second := s.refresh(first.RefreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("refresh: status %d, want 200", code)
+	}
+
+	if code, _ := s.refresh(first.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("reusing the rotated token: status %d, want 401", code)
+	}
+	if code, _ := s.refresh(second.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("refresh with the replacement after reuse: status %d, want 401", code)
+	}
+
+	family := s.tokenRecord(first.RefreshToken).FamilyID
+	var live int64
+	db.Model(&models.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", family).Count(&live)
+	if live != 0 {
+		t.Errorf("%d tokens of the reused session still live", live)
+	}
+	if record := s.tokenRecord(second.RefreshToken); record.RevokedReason != models.TokenRevokedReuse {
+		t.Errorf("revoked reason = %q, want %s", record.RevokedReason, models.TokenRevokedReuse)
+	}
+
+	if code, _ := s.refresh(other.RefreshToken); code != http.StatusOK {
+		t.Errorf("refresh in another session: status %d, want 200", code)
+	}
+}
+
+// TestLogoutAllRevokesSessions checks that no session of the user can be
+// refreshed after logging out everywhere, and that their access tokens are
+// put on the revocation list
+func TestLogoutAllRevokesSessions(t *testing.T) {
+	db := openTestDB(t)
+	redisClient := testutil.OpenRedis(t)
+	s := newSessionTest(t, db, redisClient)
+	user := createTestUser(t, db, models.RoleUser)
+
+	sessions := []AuthResponse{s.login(user), s.login(user)}
+	s.userID = user.ID
+	s.sessionID = s.tokenRecord(sessions[0].RefreshToken).FamilyID
+
+	req := httptest.NewRequest(http.MethodPost, "/logout/all", nil)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("logout all: status %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Sessions int `json:"sessions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode logout response: %v", err)
+	}
+	if resp.Sessions != 2 {
+		t.Errorf("logged out of %d sessions, want 2", resp.Sessions)
+	}
+
+	for i, session := range sessions {
+		if code, _ := s.refresh(session.RefreshToken); code != http.StatusUnauthorized {
+			t.Errorf("session %d: refresh after logout all: status %d, want 401", i, code)
+		}
+		record := s.tokenRecord(session.RefreshToken)
+		if record.RevokedReason != models.TokenRevokedLogoutAll {
+			t.Errorf("session %d: revoked reason = %q, want %s", i, record.RevokedReason, models.TokenRevokedLogoutAll)
+		}
+		revoked, err := redisClient.Exists(req.Context(), cache.GenerateRevokedSessionKey(record.FamilyID)).Result()
+		if err != nil {
+			t.Fatalf("check revoked session: %v", err)
+		}
+		if revoked != 1 {
+			t.Errorf("session %d: access tokens not revoked", i)
+		}
+	}
+}
@@ -14,14 +14,20 @@ import (
 
 // StockHandler holds dependencies for stock handling
 type StockHandler struct {
-	db    *gorm.DB
-	cache *redis.Client
+	db                *gorm.DB
+	cache             *redis.Client
+	ledger            LedgerSettings
+	approvalThreshold float64
 }
 
-func NewStockHandler(db *gorm.DB, cache *redis.Client) *StockHandler {
+// NewStockHandler creates the handler. approvalThreshold is the value above
+// which a manual outbound movement is held for approval; 0 disables the check.
+func NewStockHandler(db *gorm.DB, cache *redis.Client, ledger LedgerSettings, approvalThreshold float64) *StockHandler {
 	return &StockHandler{
-		db:    db,
-		cache: cache,
+		db:                db,
+		cache:             cache,
+		ledger:            ledger,
+		approvalThreshold: max(approvalThreshold, 0),
 	}
 }
 
@@ -29,13 +35,14 @@ func NewStockHandler(db *gorm.DB, cache *redis.Client) *StockHandler {
 // POST /api/stock
 // CreateStockMovement godoc
 // @Summary Create a stock movement
-// @Description Record a new stock movement (IN, OUT, ADJUST)
+// @Description Record a new stock movement of any active manual movement type (IN, OUT, ADJUST or a custom type) or ship a TRANSFER to another warehouse
 // @Tags stocks
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param movement body models.StockUpdateRequest true "Stock movement details"
 // @Success 200 {object} MessageResponse "Stock updated successfully"
+// @Success 202 {object} MessageResponse "Stock movement is pending approval"
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
@@ -49,87 +56,176 @@ func (h *StockHandler) CreateStockMovement(c *gin.Context) {
 		return
 	}
 
-	// Get product info
-	var product models.Product
-	if err := h.db.First(&product, req.ProductID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
-		return
-	}
+	// A TRANSFER is shorthand for a single-line transfer document
+	if req.Type == "TRANSFER" {
+		if !hasPermission(c, models.PermTransferWrite) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions", "required": models.PermTransferWrite})
+			return
+		}
 
-	// Calculate new quantity
-	oldQty := product.Quantity
-	newQty := oldQty
-
-	switch req.Type {
-	case "IN":
-		newQty = oldQty + req.Quantity
-	case "OUT":
-		if oldQty < req.Quantity {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":     "Insufficient stock",
-				"available": oldQty,
-				"requested": req.Quantity,
-			})
+		var transfer *models.StockTransfer
+		err := h.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			transfer, err = shipTransfer(tx, h.ledger, req.WarehouseID, req.ToWarehouseID,
+				[]TransferLineRequest{{ProductID: req.ProductID, Quantity: req.Quantity, LotNumber: req.LotNumber, Serials: req.Serials}}, req.Notes, userID.(uint))
+			return err
+		})
+		if err != nil {
+			respondStockError(c, err)
 			return
 		}
-		newQty = oldQty - req.Quantity
-	case "ADJUST":
-		newQty = req.Quantity
+
+		invalidateStockCaches(h.cache, c.Request.Context(), req.ProductID)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Stock transfer shipped successfully", "transfer": transfer})
+		return
 	}
 
-	// Start transaction
+	var movement *models.Stock
+	pending := false
 	err := h.db.Transaction(func(tx *gorm.DB) error {
-		// Update product quantity
-		product.Quantity = newQty
-		if err := tx.Save(&product).Error; err != nil {
+		movementType, err := validateManualMovement(tx, req.Type, req.ReasonCode)
+		if err != nil {
 			return err
 		}
+		// Setting the balance outright can hide losses, so it needs its own permission
+		if movementType.Direction == models.MovementDirectionSet && !hasPermission(c, models.PermStockAdjust) {
+			return forbiddenError("Insufficient permissions: " + models.PermStockAdjust + " is required for " + movementType.Code)
+		}
 
-		// Create stock movement record
-		movement := models.Stock{
+		// Sensitive movements are held in the ledger until a manager approves them
+		pending, err = h.movementNeedsApproval(tx, movementType, req.ProductID, req.Quantity)
+		if err != nil {
+			return err
+		}
+		if pending {
+			movement, err = holdStockMovement(tx, req, userID.(uint))
+			return err
+		}
+
+		movement, err = postStockMovement(tx, h.ledger, stockMovementInput{
 			ProductID:   req.ProductID,
+			WarehouseID: req.WarehouseID,
 			Type:        req.Type,
 			Quantity:    req.Quantity,
-			OldQuantity: oldQty,
-			NewQuantity: newQty,
+			ReasonCode:  req.ReasonCode,
+			UnitCost:    req.UnitCost,
 			Notes:       req.Notes,
-			CreatedBy:   userID.(uint),
-			CreatedAt:   time.Now(),
-		}
+			UserID:      userID.(uint),
 
-		return tx.Create(&movement).Error
+			LotNumber:       req.LotNumber,
+			ManufactureDate: req.ManufactureDate,
+			ExpiryDate:      req.ExpiryDate,
+			Serials:         req.Serials,
+		})
+		return err
 	})
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock movement"})
+		respondStockError(c, err)
+		return
+	}
+
+	if pending {
+		c.JSON(http.StatusAccepted, gin.H{"message": "Stock movement is pending approval", "movement": movement})
 		return
 	}
 
 	// Invalidate Caches
-	// 1. Stock Lists (History & Current Stock) --> เพราะมี Movement ใหม่
-	cache.InvalidateStockList(h.cache, c.Request.Context())
+	invalidateStockCaches(h.cache, c.Request.Context(), req.ProductID)
 
-	// 2. Product Lists --> เพราะ Quantity เปลี่ยน
-	cache.InvalidateProductList(h.cache, c.Request.Context())
+	c.JSON(http.StatusOK, gin.H{"message": "Stock updated successfully", "movement": movement})
 
-	// 3. Specific Product --> เพราะ Quantity เปลี่ยน
-	productCacheKey := cache.GenerateProductKey(req.ProductID)
-	h.cache.Del(c.Request.Context(), productCacheKey)
+}
+
+// ReverseStockMovementRequest holds the optional reason for a reversal
+type ReverseStockMovementRequest struct {
+	Notes string `json:"notes"`
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Stock updated successfully"})
+// ReverseStockMovement voids a movement with a compensating ledger entry
+// POST /api/stock/:id/reverse
+// ReverseStockMovement godoc
+// @Summary Reverse a stock movement
+// @Description Write a compensating movement that undoes a previous one and mark the original as reversed. A reversal that needs approval is held as PENDING and answered with 202.
+// @Tags stocks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Stock movement ID"
+// @Param reversal body ReverseStockMovementRequest false "Reason for the reversal"
+// @Success 200 {object} MessageResponse "Stock movement reversed successfully"
+// @Success 202 {object} MessageResponse "Stock movement reversal is pending approval"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /stocks/{id}/reverse [post]
+func (h *StockHandler) ReverseStockMovement(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock movement ID"})
+		return
+	}
+
+	var req ReverseStockMovementRequest
+	_ = c.ShouldBindJSON(&req)
+
+	var movement *models.Stock
+	var pending bool
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		original, compensating, err := prepareReversal(tx, uint(id), req.Notes, userID.(uint))
+		if err != nil {
+			return err
+		}
+		// Undoing an adjustment sets the balance back, so it needs the same permission
+		if originalType, err := lookupMovementType(tx, original.Type); err == nil &&
+			originalType.Direction == models.MovementDirectionSet && !hasPermission(c, models.PermStockAdjust) {
+			return forbiddenError("Insufficient permissions: " + models.PermStockAdjust + " is required to reverse " + original.Type)
+		}
+
+		// Reversals are held for approval like the manual movements they resemble
+		pending, err = h.reversalNeedsApproval(tx, original, compensating)
+		if err != nil {
+			return err
+		}
+		if pending {
+			movement, err = holdReversal(tx, original, compensating)
+			return err
+		}
 
+		movement, err = postReversal(tx, h.ledger, original, compensating)
+		return err
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	if pending {
+		c.JSON(http.StatusAccepted, gin.H{"message": "Stock movement reversal is pending approval", "movement": movement})
+		return
+	}
+
+	// Invalidate Caches
+	invalidateStockCaches(h.cache, c.Request.Context(), movement.ProductID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stock movement reversed successfully", "movement": movement})
 }
 
 // GetStockHistory gets stock history for a product
 // GET /api/stock/product/:id
 // GetStockHistory godoc
 // @Summary Get stock history
-// @Description Get stock movement history for a specific product
+// @Description Get stock movement history for a specific product, including the lots each movement touched
 // @Tags stocks
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Product ID"
+// @Param type query string false "Movement type filter, e.g. RETURN_IN, SCRAP, RTV"
 // @Success 200 {object} StockHistoryResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
@@ -156,15 +252,19 @@ func (h *StockHandler) GetStockHistory(c *gin.Context) {
 		return
 	}
 
+	query := h.db.Preload("Lots.Lot").Preload("Serials.Serial").Where("product_id = ?", id)
+	if movementType := c.Query("type"); movementType != "" {
+		query = query.Where("type = ?", movementType)
+	}
+
 	var stock []models.Stock
-	if err := h.db.Where("product_id = ?", id).Find(&stock).Error; err != nil {
+	if err := query.Find(&stock).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Stock not found"})
 		return
 	}
 
 	var movements []models.Stock
-	h.db.Where("product_id = ?", id).
-		Order("created_at DESC").
+	query.Order("created_at DESC").
 		Limit(100).
 		Find(&movements)
 
@@ -182,44 +282,63 @@ func (h *StockHandler) GetStockHistory(c *gin.Context) {
 	})
 }
 
+// GetProductLots gets lot balances for a product
+// GET /api/stock/product/:id/lots
+// GetProductLots godoc
+// @Summary Get product lots
+// @Description Get the lots of a product held at each warehouse, earliest expiry first
+// @Tags stocks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param warehouse_id query int false "Warehouse filter"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Router /stocks/product/{id}/lots [get]
+func (h *StockHandler) GetProductLots(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	query := h.db.Preload("Lot").Where("product_id = ? AND quantity > 0", id)
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+	}
+
+	var lots []models.LotBalance
+	query.Find(&lots)
+	sortLotBalancesFEFO(lots)
+
+	c.JSON(http.StatusOK, gin.H{"lots": lots})
+}
+
 // GetCurrentStock gets current stock for all products
 // GET /api/stock
 // GetCurrentStock godoc
 // @Summary Get current stock
-// @Description Get current stock levels for all products
+// @Description Get current stock levels for all products, with per-warehouse balances and totals and the on-hand, reserved and available-to-promise quantity of each product
 // @Tags stocks
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param page query int false "Page number" default(1)
 // @Param limit query int false "Items per page" default(20)
+// @Param warehouse_id query int false "Only report balances held at this warehouse"
 // @Success 200 {object} CurrentStockResponse
 // @Router /stocks [get]
 func (h *StockHandler) GetCurrentStock(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	warehouseID, _ := strconv.Atoi(c.Query("warehouse_id"))
 	offset := (page - 1) * limit
 
 	// 1. Try Cache
 	cacheKey := cache.GenerateStockListKey(h.cache, c.Request.Context(), c.Request.URL.Query())
-	type CurrentStockResponse struct {
-		Products      []models.Product `json:"products"`
-		Total         int64            `json:"total"`
-		Page          int              `json:"page"`
-		Limit         int              `json:"limit"`
-		LowStock      []models.Product `json:"low_stock"`
-		LowStockCount int              `json:"low_stock_count"`
-	}
 	var cachedResp CurrentStockResponse
 	if err := cache.GetCached(h.cache, c.Request.Context(), cacheKey, &cachedResp); err == nil {
-		c.JSON(http.StatusOK, gin.H{
-			"products":        cachedResp.Products,
-			"total":           cachedResp.Total,
-			"page":            cachedResp.Page,
-			"limit":           cachedResp.Limit,
-			"low_stock":       cachedResp.LowStock,
-			"low_stock_count": cachedResp.LowStockCount,
-		})
+		c.JSON(http.StatusOK, cachedResp)
 		return
 	}
 
@@ -234,24 +353,74 @@ func (h *StockHandler) GetCurrentStock(c *gin.Context) {
 	var lowStock []models.Product
 	h.db.Where("quantity <= min_quantity").Find(&lowStock)
 
+	// Per-warehouse balances for the products on this page
+	productIDs := make([]uint, 0, len(products))
+	for _, p := range products {
+		productIDs = append(productIDs, p.ID)
+	}
+
+	balances := []models.StockBalance{}
+	balanceQuery := h.db.Preload("Warehouse").Where("product_id IN ?", productIDs)
+	if warehouseID > 0 {
+		balanceQuery = balanceQuery.Where("warehouse_id = ?", warehouseID)
+	}
+	if len(productIDs) > 0 {
+		balanceQuery.Order("product_id, warehouse_id").Find(&balances)
+	}
+
+	// Aggregated totals per warehouse across all products
+	warehouseTotals := []WarehouseStockTotal{}
+	totalsQuery := h.db.Table("stock_balances").
+		Select("warehouses.id AS warehouse_id, warehouses.code, warehouses.name, COALESCE(SUM(stock_balances.quantity), 0) AS quantity").
+		Joins("JOIN warehouses ON warehouses.id = stock_balances.warehouse_id").
+		Group("warehouses.id, warehouses.code, warehouses.name").
+		Order("warehouses.code")
+	if warehouseID > 0 {
+		totalsQuery = totalsQuery.Where("warehouses.id = ?", warehouseID)
+	}
+	totalsQuery.Scan(&warehouseTotals)
+
+	// Quantities shipped between warehouses but not yet received
+	inTransit := []ProductQuantity{}
+	if len(productIDs) > 0 {
+		h.db.Table("stock_transfer_lines").
+			Select("stock_transfer_lines.product_id, SUM(stock_transfer_lines.quantity_shipped - stock_transfer_lines.quantity_received - stock_transfer_lines.quantity_discrepancy) AS quantity").
+			Joins("JOIN stock_transfers ON stock_transfers.id = stock_transfer_lines.transfer_id").
+			Where("stock_transfers.status IN ?", []string{models.TransferStatusInTransit, models.TransferStatusPartiallyReceived}).
+			Where("stock_transfer_lines.product_id IN ?", productIDs).
+			Group("stock_transfer_lines.product_id").
+			Scan(&inTransit)
+	}
+
+	// On-hand, reserved and available-to-promise for the products on this page
+	availability := []StockAvailability{}
+	if len(productIDs) > 0 {
+		availabilityQuery := h.db.Table("stock_balances").
+			Select("product_id, SUM(quantity) AS on_hand, SUM(reserved_quantity) AS reserved, SUM(quarantined_quantity) AS quarantined, SUM(quantity - reserved_quantity - quarantined_quantity) AS available").
+			Where("product_id IN ?", productIDs).
+			Group("product_id").
+			Order("product_id")
+		if warehouseID > 0 {
+			availabilityQuery = availabilityQuery.Where("warehouse_id = ?", warehouseID)
+		}
+		availabilityQuery.Scan(&availability)
+	}
+
 	response := CurrentStockResponse{
-		Products:      products,
-		Total:         total,
-		Page:          page,
-		Limit:         limit,
-		LowStock:      lowStock,
-		LowStockCount: len(lowStock),
+		Products:        products,
+		Total:           total,
+		Page:            page,
+		Limit:           limit,
+		LowStock:        lowStock,
+		LowStockCount:   len(lowStock),
+		Balances:        balances,
+		WarehouseTotals: warehouseTotals,
+		InTransit:       inTransit,
+		Availability:    availability,
 	}
 
 	// 2. Set Cache
 	cache.SetCached(h.cache, c.Request.Context(), cacheKey, response, 30*time.Minute)
 
-	c.JSON(http.StatusOK, gin.H{
-		"products":        products,
-		"total":           total,
-		"page":            page,
-		"limit":           limit,
-		"low_stock":       lowStock,
-		"low_stock_count": len(lowStock),
-	})
+	c.JSON(http.StatusOK, response)
 }
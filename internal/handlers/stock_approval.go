@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReviewStockMovementRequest holds the reviewer's notes on a pending movement
+type ReviewStockMovementRequest struct {
+	Notes string `json:"notes"`
+}
+
+// movementNeedsApproval reports whether a manual movement must be held for a
+// manager: its type requires approval, or it takes out more than the
+// threshold's worth of stock at the current unit cost
+func (h *StockHandler) movementNeedsApproval(tx *gorm.DB, movementType models.MovementType, productID uint, quantity int) (bool, error) {
+	if movementType.RequiresApproval {
+		return true, nil
+	}
+	if movementType.Direction != models.MovementDirectionOut || h.approvalThreshold <= 0 {
+		return false, nil
+	}
+
+	var product models.Product
+	if err := tx.First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, errProductNotFound
+		}
+		return false, err
+	}
+	unitCost, err := currentUnitCost(tx, h.ledger.withCostingMethod(product))
+	if err != nil {
+		return false, err
+	}
+	return unitCost*float64(quantity) > h.approvalThreshold, nil
+}
+
+// holdStockMovement writes a PENDING ledger row for a movement waiting for
+// approval. The balance is untouched; the request is kept so it can be posted
+// as submitted once approved.
+func holdStockMovement(tx *gorm.DB, req models.StockUpdateRequest, userID uint) (*models.Stock, error) {
+	var product models.Product
+	if err := tx.Where("id = ? AND is_active = ?", req.ProductID, true).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errProductNotFound
+		}
+		return nil, err
+	}
+	warehouse, err := resolveWarehouse(tx, req.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+
+	req.WarehouseID = warehouse.ID
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	movement := models.Stock{
+		ProductID:      product.ID,
+		WarehouseID:    warehouse.ID,
+		Type:           req.Type,
+		Quantity:       req.Quantity,
+		ReasonCode:     req.ReasonCode,
+		UnitCost:       req.UnitCost,
+		Status:         models.StockStatusPending,
+		PendingRequest: payload,
+		Notes:          req.Notes,
+		CreatedBy:      userID,
+		UpdatedBy:      userID,
+		CreatedAt:      time.Now(),
+	}
+	if err := tx.Create(&movement).Error; err != nil {
+		return nil, err
+	}
+	if err := recordEvent(tx, models.EventStockMovementPending, "stock", movement.ID,
+		newStockMovedEvent(&movement, product.Quantity), userID); err != nil {
+		return nil, err
+	}
+	return &movement, nil
+}
+
+// reversalNeedsApproval reports whether a reversal must be held for a manager:
+// the original's type requires approval, or the compensating movement would on
+// its own
+func (h *StockHandler) reversalNeedsApproval(tx *gorm.DB, original *models.Stock, compensating stockMovementInput) (bool, error) {
+	if originalType, err := lookupMovementType(tx, original.Type); err == nil && originalType.RequiresApproval {
+		return true, nil
+	}
+	movementType, err := lookupMovementType(tx, compensating.Type)
+	if err != nil {
+		return false, err
+	}
+	return h.movementNeedsApproval(tx, movementType, compensating.ProductID, compensating.Quantity)
+}
+
+// holdReversal writes a PENDING ledger row for a reversal waiting for
+// approval. The compensating movement is rebuilt from the original when it is
+// approved, so only one reversal of a movement can be pending at a time.
+func holdReversal(tx *gorm.DB, original *models.Stock, compensating stockMovementInput) (*models.Stock, error) {
+	var held int64
+	if err := tx.Model(&models.Stock{}).
+		Where("reversal_of = ? AND status = ?", original.ID, models.StockStatusPending).
+		Count(&held).Error; err != nil {
+		return nil, err
+	}
+	if held > 0 {
+		return nil, conflictError("A reversal of this stock movement is already pending approval")
+	}
+
+	var product models.Product
+	if err := tx.First(&product, original.ProductID).Error; err != nil {
+		return nil, err
+	}
+
+	movement := models.Stock{
+		ProductID:   compensating.ProductID,
+		WarehouseID: compensating.WarehouseID,
+		Type:        compensating.Type,
+		Quantity:    compensating.Quantity,
+		Reference:   compensating.Reference,
+		ReasonCode:  compensating.ReasonCode,
+		UnitCost:    compensating.UnitCost,
+		ReversalOf:  &original.ID,
+		Status:      models.StockStatusPending,
+		Notes:       compensating.Notes,
+		CreatedBy:   compensating.UserID,
+		UpdatedBy:   compensating.UserID,
+		CreatedAt:   time.Now(),
+	}
+	if err := tx.Create(&movement).Error; err != nil {
+		return nil, err
+	}
+	if err := recordEvent(tx, models.EventStockMovementPending, "stock", movement.ID,
+		newStockMovedEvent(&movement, product.Quantity), compensating.UserID); err != nil {
+		return nil, err
+	}
+	return &movement, nil
+}
+
+// loadPendingMovement locks a movement and checks that it is still waiting for review
+func loadPendingMovement(tx *gorm.DB, id int, reviewerID uint) (*models.Stock, error) {
+	var movement models.Stock
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&movement, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errMovementNotFound
+		}
+		return nil, err
+	}
+	if movement.Status != models.StockStatusPending {
+		return nil, conflictError("Stock movement is not pending approval")
+	}
+	if movement.CreatedBy == reviewerID {
+		return nil, validationError("Stock movements cannot be reviewed by the user who requested them")
+	}
+	return &movement, nil
+}
+
+// postPendingMovement posts a held movement as it was submitted, into its
+// PENDING row. The catalogue is checked again, so a movement type or reason
+// code deactivated while the movement waited is refused.
+func postPendingMovement(tx *gorm.DB, ledger LedgerSettings, pending *models.Stock) (*models.Stock, error) {
+	var request models.StockUpdateRequest
+	if err := json.Unmarshal(pending.PendingRequest, &request); err != nil {
+		return nil, err
+	}
+	if _, err := validateManualMovement(tx, pending.Type, pending.ReasonCode); err != nil {
+		return nil, err
+	}
+
+	return postStockMovement(tx, ledger, stockMovementInput{
+		ProductID:   pending.ProductID,
+		WarehouseID: pending.WarehouseID,
+		Type:        pending.Type,
+		Quantity:    pending.Quantity,
+		ReasonCode:  pending.ReasonCode,
+		UnitCost:    request.UnitCost,
+		Notes:       pending.Notes,
+		UserID:      pending.CreatedBy,
+		PendingID:   &pending.ID,
+
+		LotNumber:       request.LotNumber,
+		ManufactureDate: request.ManufactureDate,
+		ExpiryDate:      request.ExpiryDate,
+		Serials:         request.Serials,
+	})
+}
+
+// GetPendingMovements godoc
+// @Summary Get pending stock movements
+// @Description List movements held for approval, oldest first (requires stock:approve)
+// @Tags stocks
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Param warehouse_id query int false "Warehouse filter"
+// @Param product_id query int false "Product filter"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} ErrorResponse
+// @Router /stocks/pending [get]
+func (h *StockHandler) GetPendingMovements(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	query := h.db.Model(&models.Stock{}).Where("status = ?", models.StockStatusPending)
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+	}
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+
+	var total int64
+	var movements []models.Stock
+	query.Count(&total)
+	query.Preload("Product").Preload("User").Preload("Warehouse").
+		Offset(offset).Limit(limit).Order("created_at").Find(&movements)
+
+	c.JSON(http.StatusOK, gin.H{
+		"movements": movements,
+		"total":     total,
+		"page":      page,
+		"limit":     limit,
+	})
+}
+
+// ApproveStockMovement godoc
+// @Summary Approve a pending stock movement
+// @Description Post a held movement to the ledger as it was submitted and record the approver (requires stock:approve). Requesters cannot approve their own movements. A movement whose type or reason code has been deactivated since it was submitted is refused with 400.
+// @Tags stocks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Stock movement ID"
+// @Param review body ReviewStockMovementRequest false "Approval notes"
+// @Success 200 {object} MessageResponse "Stock movement approved successfully"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /stocks/{id}/approve [post]
+func (h *StockHandler) ApproveStockMovement(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock movement ID"})
+		return
+	}
+
+	var req ReviewStockMovementRequest
+	_ = c.ShouldBindJSON(&req)
+
+	var movement *models.Stock
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		pending, err := loadPendingMovement(tx, id, userID.(uint))
+		if err != nil {
+			return err
+		}
+
+		// A held reversal is rebuilt from the original, which may have changed since
+		if pending.ReversalOf != nil {
+			original, compensating, err := prepareReversal(tx, *pending.ReversalOf, pending.Notes, pending.CreatedBy)
+			if err != nil {
+				return err
+			}
+			compensating.PendingID = &pending.ID
+			movement, err = postReversal(tx, h.ledger, original, compensating)
+		} else {
+			movement, err = postPendingMovement(tx, h.ledger, pending)
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		reviewer := userID.(uint)
+		movement.ReviewedBy = &reviewer
+		movement.ReviewedAt = &now
+		movement.ReviewNotes = req.Notes
+		return tx.Model(&models.Stock{ID: movement.ID}).Updates(map[string]interface{}{
+			"reviewed_by":  reviewer,
+			"reviewed_at":  now,
+			"review_notes": req.Notes,
+			"updated_by":   reviewer,
+		}).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	// The balance only changes now, so this is when caches go stale
+	invalidateStockCaches(h.cache, c.Request.Context(), movement.ProductID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stock movement approved successfully", "movement": movement})
+}
+
+// RejectStockMovement godoc
+// @Summary Reject a pending stock movement
+// @Description Reject a held movement so it is never applied, recording the reviewer (requires stock:approve).
+// @Tags stocks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Stock movement ID"
+// @Param review body ReviewStockMovementRequest false "Reason for rejecting"
+// @Success 200 {object} MessageResponse "Stock movement rejected"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /stocks/{id}/reject [post]
+func (h *StockHandler) RejectStockMovement(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock movement ID"})
+		return
+	}
+
+	var req ReviewStockMovementRequest
+	_ = c.ShouldBindJSON(&req)
+
+	var movement *models.Stock
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		movement, err = loadPendingMovement(tx, id, userID.(uint))
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		reviewer := userID.(uint)
+		movement.Status = models.StockStatusRejected
+		movement.ReviewedBy = &reviewer
+		movement.ReviewedAt = &now
+		movement.ReviewNotes = req.Notes
+		movement.UpdatedBy = reviewer
+		if err := tx.Omit(clause.Associations).Save(movement).Error; err != nil {
+			return err
+		}
+
+		var product models.Product
+		if err := tx.First(&product, movement.ProductID).Error; err != nil {
+			return err
+		}
+		return recordEvent(tx, models.EventStockMovementRejected, "stock", movement.ID,
+			newStockMovedEvent(movement, product.Quantity), reviewer)
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stock movement rejected", "movement": movement})
+}
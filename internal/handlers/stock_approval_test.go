@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+)
+
+// approvalTest drives the stock movement and review endpoints of one
+// StockHandler. Requests run as the user passed to do, with every permission,
+// standing in for ValidateJWT and RequirePermission.
+type approvalTest struct {
+	t      *testing.T
+	db     *gorm.DB
+	router *gin.Engine
+
+	requester models.User
+	manager   models.User
+	product   models.Product
+}
+
+// newApprovalTest sets up a product with 100 units on hand at a unit cost of 5,
+// and a handler holding outbound movements worth more than threshold
+func newApprovalTest(t *testing.T, threshold float64) *approvalTest {
+	db := openTestDB(t)
+	a := &approvalTest{t: t, db: db}
+	a.requester = createTestUser(t, db, models.RoleUser)
+	a.manager = createTestUser(t, db, models.RoleManager)
+	a.product = createTestProduct(t, db, a.requester.ID)
+	if _, err := postTestMovement(db, stockMovementInput{
+		ProductID: a.product.ID, Type: "IN", Quantity: 100, UserID: a.requester.ID,
+	}); err != nil {
+		t.Fatalf("stock in: %v", err)
+	}
+
+	h := NewStockHandler(db, unreachableRedis(t), LedgerSettings{}, threshold)
+	permissions := map[string]bool{}
+	for _, p := range models.Permissions() {
+		permissions[p] = true
+	}
+	gin.SetMode(gin.TestMode)
+	a.router = gin.New()
+	stocks := a.router.Group("/stocks", func(c *gin.Context) {
+		var id uint
+		fmt.Sscan(c.GetHeader("X-Test-User"), &id)
+		c.Set("userID", id)
+		c.Set("permissions", permissions)
+		c.Next()
+	})
+	stocks.POST("", h.CreateStockMovement)
+	stocks.POST("/:id/reverse", h.ReverseStockMovement)
+	stocks.POST("/:id/approve", h.ApproveStockMovement)
+	stocks.POST("/:id/reject", h.RejectStockMovement)
+	return a
+}
+
+// do sends a request as user and returns the status and the movement in the response
+func (a *approvalTest) do(user models.User, path string, body interface{}) (int, models.Stock) {
+	a.t.Helper()
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", fmt.Sprint(user.ID))
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+
+	var resp struct {
+		Movement models.Stock `json:"movement"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp.Movement
+}
+
+// hold submits a movement that must be held and returns the pending row
+func (a *approvalTest) hold(req models.StockUpdateRequest) models.Stock {
+	a.t.Helper()
+	req.ProductID = a.product.ID
+	code, movement := a.do(a.requester, "/stocks", req)
+	if code != http.StatusAccepted {
+		a.t.Fatalf("submit %s %d: status %d, want 202", req.Type, req.Quantity, code)
+	}
+	if movement.Status != models.StockStatusPending {
+		a.t.Fatalf("submitted movement is %s, want PENDING", movement.Status)
+	}
+	return movement
+}
+
+func (a *approvalTest) approve(user models.User, id uint) int {
+	a.t.Helper()
+	code, _ := a.do(user, fmt.Sprintf("/stocks/%d/approve", id), ReviewStockMovementRequest{Notes: "ok"})
+	return code
+}
+
+// onHand reloads the product's quantity
+func (a *approvalTest) onHand() int {
+	a.t.Helper()
+	var product models.Product
+	if err := a.db.First(&product, a.product.ID).Error; err != nil {
+		a.t.Fatalf("load product: %v", err)
+	}
+	return product.Quantity
+}
+
+func (a *approvalTest) status(id uint) string {
+	a.t.Helper()
+	var movement models.Stock
+	if err := a.db.First(&movement, id).Error; err != nil {
+		a.t.Fatalf("load movement: %v", err)
+	}
+	return movement.Status
+}
+
+// TestApprovalValueThreshold checks that only outbound movements worth more
+// than the threshold are held, and that the requester cannot approve their own
+func TestApprovalValueThreshold(t *testing.T) {
+	a := newApprovalTest(t, 100)
+
+	req := models.StockUpdateRequest{ProductID: a.product.ID, Type: "OUT", Quantity: 20} // worth 100
+	if code, _ := a.do(a.requester, "/stocks", req); code != http.StatusOK {
+		t.Fatalf("OUT at the threshold: status %d, want 200", code)
+	}
+
+	pending := a.hold(models.StockUpdateRequest{Type: "OUT", Quantity: 21})
+	if got := a.onHand(); got != 80 {
+		t.Fatalf("held movement changed the balance to %d, want 80", got)
+	}
+
+	if code := a.approve(a.requester, pending.ID); code != http.StatusBadRequest {
+		t.Errorf("self-approval: status %d, want 400", code)
+	}
+	if got := a.status(pending.ID); got != models.StockStatusPending {
+		t.Fatalf("after self-approval the movement is %s, want PENDING", got)
+	}
+
+	if code := a.approve(a.manager, pending.ID); code != http.StatusOK {
+		t.Fatalf("approve: status %d, want 200", code)
+	}
+	var movement models.Stock
+	a.db.First(&movement, pending.ID)
+	if movement.Status != models.StockStatusPosted || movement.ReviewedBy == nil || *movement.ReviewedBy != a.manager.ID {
+		t.Errorf("approved movement is %s reviewed by %v, want POSTED by %d", movement.Status, movement.ReviewedBy, a.manager.ID)
+	}
+	if got := a.onHand(); got != 59 {
+		t.Errorf("on hand after approval = %d, want 59", got)
+	}
+
+	if code := a.approve(a.manager, pending.ID); code != http.StatusConflict {
+		t.Errorf("approving twice: status %d, want 409", code)
+	}
+}
+
+// TestApproveHeldReversal reverses a receipt whose compensating OUT is over
+// the threshold, and checks it only takes effect once approved
+func TestApproveHeldReversal(t *testing.T) {
+	a := newApprovalTest(t, 100)
+	receipt, err := postTestMovement(a.db, stockMovementInput{
+		ProductID: a.product.ID, Type: "IN", Quantity: 30, UserID: a.requester.ID,
+	})
+	if err != nil {
+		t.Fatalf("stock in: %v", err)
+	}
+
+	code, pending := a.do(a.requester, fmt.Sprintf("/stocks/%d/reverse", receipt.ID), ReverseStockMovementRequest{})
+	if code != http.StatusAccepted {
+		t.Fatalf("reverse: status %d, want 202", code)
+	}
+	if got := a.onHand(); got != 130 {
+		t.Fatalf("held reversal changed the balance to %d, want 130", got)
+	}
+
+	if code := a.approve(a.requester, pending.ID); code != http.StatusBadRequest {
+		t.Errorf("self-approval: status %d, want 400", code)
+	}
+	if code := a.approve(a.manager, pending.ID); code != http.StatusOK {
+		t.Fatalf("approve: status %d, want 200", code)
+	}
+	if got := a.onHand(); got != 100 {
+		t.Errorf("on hand after approval = %d, want 100", got)
+	}
+	var original models.Stock
+	a.db.First(&original, receipt.ID)
+	if original.ReversedBy == nil || *original.ReversedBy != pending.ID {
+		t.Errorf("receipt reversed by %v, want %d", original.ReversedBy, pending.ID)
+	}
+}
+
+// TestRejectStockMovement checks that a rejected movement never reaches the
+// balance and can no longer be approved
+func TestRejectStockMovement(t *testing.T) {
+	a := newApprovalTest(t, 100)
+	pending := a.hold(models.StockUpdateRequest{Type: "OUT", Quantity: 50})
+
+	if code, _ := a.do(a.manager, fmt.Sprintf("/stocks/%d/reject", pending.ID), ReviewStockMovementRequest{Notes: "no"}); code != http.StatusOK {
+		t.Fatalf("reject: status %d, want 200", code)
+	}
+	if got := a.status(pending.ID); got != models.StockStatusRejected {
+		t.Errorf("rejected movement is %s, want REJECTED", got)
+	}
+	if code := a.approve(a.manager, pending.ID); code != http.StatusConflict {
+		t.Errorf("approving a rejected movement: status %d, want 409", code)
+	}
+	if got := a.onHand(); got != 100 {
+		t.Errorf("on hand = %d, want 100", got)
+	}
+}
+
+// TestApproveRefusesDeactivatedCatalogue deactivates the movement type or
+// reason code of a held movement and checks that approving it is refused
+func TestApproveRefusesDeactivatedCatalogue(t *testing.T) {
+	a := newApprovalTest(t, 0)
+
+	movementType := models.MovementType{
+		Code: uniqueName("APPR"), Name: "Needs approval", Direction: models.MovementDirectionOut,
+		AffectsValuation: true, RequiresApproval: true, Manual: true, IsActive: true,
+	}
+	reason := models.ReasonCode{Code: uniqueName("REASON"), Name: "Test reason", IsActive: true}
+	if err := a.db.Create(&movementType).Error; err != nil {
+		t.Fatalf("create movement type: %v", err)
+	}
+	if err := a.db.Create(&reason).Error; err != nil {
+		t.Fatalf("create reason code: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		deactivate interface{}
+	}{
+		{"movement type", &movementType},
+		{"reason code", &reason},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.db.Model(&movementType).Update("is_active", true)
+			a.db.Model(&reason).Update("is_active", true)
+			pending := a.hold(models.StockUpdateRequest{Type: movementType.Code, Quantity: 5, ReasonCode: reason.Code})
+
+			if err := a.db.Model(tt.deactivate).Update("is_active", false).Error; err != nil {
+				t.Fatalf("deactivate: %v", err)
+			}
+			if code := a.approve(a.manager, pending.ID); code != http.StatusBadRequest {
+				t.Errorf("approve: status %d, want 400", code)
+			}
+			if got := a.status(pending.ID); got != models.StockStatusPending {
+				t.Errorf("movement is %s, want PENDING", got)
+			}
+			if got := a.onHand(); got != 100 {
+				t.Errorf("on hand = %d, want 100", got)
+			}
+		})
+	}
+}
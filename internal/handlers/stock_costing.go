@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/impk123/Inventory-Management-Mini-System/internal/config"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+)
+
+// LedgerSettings are the deployment-wide settings stock movements are posted with
+type LedgerSettings struct {
+	CostingMethod    string        // FIFO, AVERAGE or STANDARD for products without their own method
+	ReceiptTolerance float64       // percent a PO line may be over-received by, and short by when the order closes itself
+	AlertCooldown    time.Duration // quiet period after a stock alert resolves before it notifies again
+}
+
+// NewLedgerSettings reads the ledger settings from the configuration. An
+// unknown costing method falls back to AVERAGE, and a negative tolerance or
+// cooldown to none.
+func NewLedgerSettings(cfg *config.Config) LedgerSettings {
+	settings := LedgerSettings{CostingMethod: models.CostingMethodAverage}
+	switch m := strings.ToUpper(cfg.CostingMethod); m {
+	case models.CostingMethodFIFO, models.CostingMethodAverage, models.CostingMethodStandard:
+		settings.CostingMethod = m
+	}
+	if cfg.POReceiptTolerance >= 0 {
+		settings.ReceiptTolerance = cfg.POReceiptTolerance
+	}
+	if cfg.AlertCooldown >= 0 {
+		settings.AlertCooldown = cfg.AlertCooldown
+	}
+	return settings
+}
+
+// defaultCostingMethod is the method products without their own are valued with
+func (s LedgerSettings) defaultCostingMethod() string {
+	if s.CostingMethod != "" {
+		return s.CostingMethod
+	}
+	return models.CostingMethodAverage
+}
+
+// withCostingMethod returns product with the method it is valued with filled in
+func (s LedgerSettings) withCostingMethod(product models.Product) models.Product {
+	if product.CostingMethod == "" {
+		product.CostingMethod = s.defaultCostingMethod()
+	}
+	return product
+}
+
+// costingMethod returns the method used to value a product loaded through
+// LedgerSettings.withCostingMethod
+func costingMethod(product models.Product) string {
+	if product.CostingMethod != "" {
+		return product.CostingMethod
+	}
+	return models.CostingMethodAverage
+}
+
+// currentUnitCost is the cost a unit would be valued at right now, without
+// consuming anything
+func currentUnitCost(tx *gorm.DB, product models.Product) (float64, error) {
+	switch costingMethod(product) {
+	case models.CostingMethodAverage:
+		if product.AverageCost > 0 {
+			return product.AverageCost, nil
+		}
+	case models.CostingMethodFIFO:
+		var layer models.CostLayer
+		err := tx.Where("product_id = ? AND quantity_remaining > 0", product.ID).
+			Order("id").Limit(1).Find(&layer).Error
+		if err != nil {
+			return 0, err
+		}
+		if layer.ID != 0 {
+			return layer.UnitCost, nil
+		}
+	}
+	return product.CostPrice, nil
+}
+
+// receiptUnitCost is the cost an inbound movement is valued at
+func receiptUnitCost(tx *gorm.DB, product models.Product, given float64) (float64, error) {
+	if costingMethod(product) == models.CostingMethodStandard {
+		return product.CostPrice, nil
+	}
+	if given > 0 {
+		return given, nil
+	}
+	return currentUnitCost(tx, product)
+}
+
+// issueUnitCost consumes qty units from the product's FIFO layers and returns
+// the unit cost the issue is valued at under the product's costing method.
+// Layers are consumed whatever the method so they always match the stock on hand.
+func issueUnitCost(tx *gorm.DB, product models.Product, qty int) (float64, error) {
+	var layers []models.CostLayer
+	if err := tx.Where("product_id = ? AND quantity_remaining > 0", product.ID).
+		Order("id").Find(&layers).Error; err != nil {
+		return 0, err
+	}
+
+	consumedCost := 0.0
+	remaining := qty
+	for i := range layers {
+		if remaining == 0 {
+			break
+		}
+		take := min(layers[i].QuantityRemaining, remaining)
+		layers[i].QuantityRemaining -= take
+		consumedCost += float64(take) * layers[i].UnitCost
+		remaining -= take
+		if err := tx.Save(&layers[i]).Error; err != nil {
+			return 0, err
+		}
+	}
+	// Stock received before costing was recorded has no layer
+	consumedCost += float64(remaining) * product.CostPrice
+
+	switch costingMethod(product) {
+	case models.CostingMethodFIFO:
+		if qty == 0 {
+			return 0, nil
+		}
+		return consumedCost / float64(qty), nil
+	case models.CostingMethodAverage:
+		if product.AverageCost > 0 {
+			return product.AverageCost, nil
+		}
+	}
+	return product.CostPrice, nil
+}
+
+// recordReceiptCost adds a FIFO layer for an inbound movement and folds it
+// into the product's moving average, recording ProductUpdated when the average
+// changes. onHand is the product quantity before the movement.
+func recordReceiptCost(tx *gorm.DB, product models.Product, movement *models.Stock, qty int, onHand int) error {
+	layer := models.CostLayer{
+		ProductID:         product.ID,
+		StockID:           movement.ID,
+		UnitCost:          movement.UnitCost,
+		QuantityReceived:  qty,
+		QuantityRemaining: qty,
+	}
+	if err := tx.Create(&layer).Error; err != nil {
+		return err
+	}
+
+	average := movement.UnitCost
+	if onHand > 0 {
+		previous := product.AverageCost
+		if previous == 0 {
+			previous = product.CostPrice
+		}
+		average = (float64(onHand)*previous + float64(qty)*movement.UnitCost) / float64(onHand+qty)
+	}
+	if average == product.AverageCost {
+		return nil
+	}
+	if err := tx.Model(&models.Product{}).Where("id = ?", product.ID).Update("average_cost", average).Error; err != nil {
+		return err
+	}
+
+	var updated models.Product
+	if err := tx.First(&updated, product.ID).Error; err != nil {
+		return err
+	}
+	return recordEvent(tx, models.EventProductUpdated, "product", updated.ID, updated, movement.CreatedBy)
+}
@@ -0,0 +1,526 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/cache"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	errProductNotFound   = notFoundError("Product not found")
+	errWarehouseNotFound = notFoundError("Warehouse not found")
+	errMovementNotFound  = notFoundError("Stock movement not found")
+)
+
+// notFoundError is reported back to the client as 404
+type notFoundError string
+
+func (e notFoundError) Error() string {
+	return string(e)
+}
+
+// validationError is a business rule violation that the client can fix
+type validationError string
+
+func (e validationError) Error() string {
+	return string(e)
+}
+
+// conflictError means the request clashes with the current state of a record
+type conflictError string
+
+func (e conflictError) Error() string {
+	return string(e)
+}
+
+// forbiddenError means the user's role lacks a permission the request needs
+type forbiddenError string
+
+func (e forbiddenError) Error() string {
+	return string(e)
+}
+
+// insufficientStockError is returned when an outbound movement asks for more
+// than the warehouse currently holds
+type insufficientStockError struct {
+	WarehouseID uint
+	LotNumber   string
+	Available   int
+	Requested   int
+}
+
+func (e *insufficientStockError) Error() string {
+	return "Insufficient stock"
+}
+
+// stockMovementInput describes a single posting to the stock ledger
+type stockMovementInput struct {
+	ProductID   uint
+	WarehouseID uint // 0 means the default warehouse
+	Type        string
+	Quantity    int
+	Reference   string
+	ReasonCode  string
+	UnitCost    float64 // inbound cost, 0 means the product's current cost; for TRANSIT_LOSS, the cost the caller issued
+	TransferID  *uint
+	POLineID    *uint
+	SOLineID    *uint
+	ReversalOf  *uint
+	Notes       string
+	UserID      uint
+
+	// PendingID applies a movement that was held for approval, turning its
+	// PENDING ledger row into the posted movement instead of adding a new row
+	PendingID *uint
+
+	// Reservation is how many units are reserved for the order line an
+	// outbound movement ships. A movement with a reservation draws on it,
+	// releasing Quantity units, instead of drawing on unreserved stock.
+	Reservation int
+
+	// Unvalued books the movement at zero cost whatever its type, so reversing
+	// a movement that did not affect valuation does not affect it either
+	Unvalued bool
+
+	// Quarantine puts inbound stock into quarantine and takes outbound stock
+	// from it; RESTOCK releases quarantined stock without moving it
+	Quarantine bool
+
+	// Lot tracking: an IN names the lot it creates or adds to, an OUT may name
+	// the lot to pick instead of FEFO. Lots overrides both with exact quantities.
+	LotNumber       string
+	ManufactureDate *time.Time
+	ExpiryDate      *time.Time
+	Lots            []lotQuantity
+
+	// Serial tracking: the serial number of every unit moved
+	Serials []string
+}
+
+// postStockMovement applies a movement to the warehouse balance, re-derives
+// Product.Quantity and writes the ledger row. It must run inside a transaction.
+//
+// The product row is locked FOR UPDATE before anything is read, so concurrent
+// movements on the same product are serialised and always see the balance left
+// by the previous one. Callers posting several products in one transaction
+// should do so in ascending product ID order to avoid deadlocks.
+func postStockMovement(tx *gorm.DB, ledger LedgerSettings, in stockMovementInput) (*models.Stock, error) {
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, in.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errProductNotFound
+		}
+		return nil, err
+	}
+	product = ledger.withCostingMethod(product)
+
+	warehouse, err := resolveWarehouse(tx, in.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+
+	var balance models.StockBalance
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(models.StockBalance{ProductID: product.ID, WarehouseID: warehouse.ID}).
+		FirstOrCreate(&balance).Error; err != nil {
+		return nil, err
+	}
+
+	// Calculate new quantity at this warehouse
+	oldQty := balance.Quantity
+	newQty := oldQty
+
+	movementType, err := lookupMovementType(tx, in.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	switch movementType.Direction {
+	case models.MovementDirectionIn:
+		newQty = oldQty + in.Quantity
+		if in.Quarantine {
+			balance.QuarantinedQuantity += in.Quantity
+		}
+	case models.MovementDirectionNone:
+		// RESTOCK releases quarantined stock without moving it
+		if in.Type == "RESTOCK" {
+			if balance.QuarantinedQuantity < in.Quantity {
+				return nil, &insufficientStockError{
+					WarehouseID: warehouse.ID,
+					Available:   balance.QuarantinedQuantity,
+					Requested:   in.Quantity,
+				}
+			}
+			balance.QuarantinedQuantity -= in.Quantity
+		}
+	case models.MovementDirectionOut:
+		// Reserved and quarantined stock can only leave against its reservation
+		// or out of quarantine
+		available := oldQty - balance.ReservedQuantity - balance.QuarantinedQuantity
+		switch {
+		case in.Reservation > 0:
+			// Stock reserved for other orders stays out of reach
+			others := balance.ReservedQuantity - min(in.Reservation, balance.ReservedQuantity)
+			available = oldQty - balance.QuarantinedQuantity - others
+		case in.Quarantine:
+			available = balance.QuarantinedQuantity
+		}
+		if available < in.Quantity {
+			return nil, &insufficientStockError{
+				WarehouseID: warehouse.ID,
+				Available:   max(available, 0),
+				Requested:   in.Quantity,
+			}
+		}
+		newQty = oldQty - in.Quantity
+		if in.Reservation > 0 {
+			balance.ReservedQuantity = max(balance.ReservedQuantity-in.Quantity, 0)
+		}
+		if in.Quarantine {
+			balance.QuarantinedQuantity -= in.Quantity
+		}
+	case models.MovementDirectionSet:
+		// Stock held for sales orders or in quarantine must stay on hand;
+		// cancel the orders or dispose of the returns first
+		if held := balance.ReservedQuantity + balance.QuarantinedQuantity; in.Quantity < held {
+			return nil, conflictError(fmt.Sprintf(
+				"Cannot set the balance to %d, %d units are reserved for sales orders and %d are in quarantine",
+				in.Quantity, balance.ReservedQuantity, balance.QuarantinedQuantity))
+		}
+		newQty = in.Quantity
+	}
+
+	if product.TrackingMode == models.TrackingModeLot && movementType.Direction == models.MovementDirectionIn &&
+		in.Type != "TRANSFER_IN" && in.LotNumber == "" && len(in.Lots) == 0 {
+		return nil, validationError("Lot number is required for lot-tracked products")
+	}
+
+	// Adjustments and write-offs may take stock out of expired lots
+	writeOff := movementType.Direction == models.MovementDirectionSet || in.ReasonCode != ""
+	lots, err := applyLotChanges(tx, product.ID, warehouse.ID, oldQty, newQty-oldQty, in, writeOff)
+	if err != nil {
+		return nil, err
+	}
+
+	serials, err := applySerialChanges(tx, product, warehouse.ID, newQty-oldQty, in)
+	if err != nil {
+		return nil, err
+	}
+
+	delta := newQty - oldQty
+	moved := max(delta, -delta)
+	// Stock lost in transit already left the balance with the transfer, but is
+	// only now written off the books
+	if in.Type == "TRANSIT_LOSS" {
+		moved = in.Quantity
+	}
+	// Movement types that do not affect valuation are booked at zero cost and
+	// leave the cost layers and average cost alone
+	valued := movementType.AffectsValuation && !in.Unvalued
+	unitCost := 0.0
+	if valued {
+		unitCost, err = movementUnitCost(tx, product, delta, in)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	balance.Quantity = newQty
+	if err := tx.Save(&balance).Error; err != nil {
+		return nil, err
+	}
+
+	if err := syncProductQuantity(tx, product.ID, in.UserID); err != nil {
+		return nil, err
+	}
+
+	movement := models.Stock{
+		ProductID:   product.ID,
+		WarehouseID: warehouse.ID,
+		Type:        in.Type,
+		Quantity:    in.Quantity,
+		OldQuantity: oldQty,
+		NewQuantity: newQty,
+		Reference:   in.Reference,
+		ReasonCode:  in.ReasonCode,
+		UnitCost:    unitCost,
+		TotalCost:   unitCost * float64(moved),
+		TransferID:  in.TransferID,
+		POLineID:    in.POLineID,
+		SOLineID:    in.SOLineID,
+		ReversalOf:  in.ReversalOf,
+		Status:      models.StockStatusPosted,
+		Notes:       in.Notes,
+		CreatedBy:   in.UserID,
+		CreatedAt:   time.Now(),
+	}
+	if in.PendingID != nil {
+		movement.ID = *in.PendingID
+		if err := tx.Omit(clause.Associations, "PendingRequest", "ReviewedBy", "ReviewedAt", "ReviewNotes").
+			Save(&movement).Error; err != nil {
+			return nil, err
+		}
+	} else if err := tx.Create(&movement).Error; err != nil {
+		return nil, err
+	}
+
+	// Thresholds are checked against the product's total across warehouses
+	if err := raiseStockAlerts(tx, product, product.Quantity+delta, movement.ID, ledger.AlertCooldown); err != nil {
+		return nil, err
+	}
+
+	// Receipts open a FIFO layer and move the average; transfer legs only move stock between warehouses
+	if delta > 0 && in.Type != "TRANSFER_IN" && valued {
+		if err := recordReceiptCost(tx, product, &movement, delta, product.Quantity); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range lots {
+		lots[i].StockID = movement.ID
+		if err := tx.Omit("Lot").Create(&lots[i]).Error; err != nil {
+			return nil, err
+		}
+	}
+	movement.Lots = lots
+
+	for _, serial := range serials {
+		link := models.StockSerial{StockID: movement.ID, SerialID: serial.ID}
+		if err := tx.Create(&link).Error; err != nil {
+			return nil, err
+		}
+		link.Serial = &serial
+		movement.Serials = append(movement.Serials, link)
+	}
+
+	if err := recordEvent(tx, models.EventStockMoved, "stock", movement.ID,
+		newStockMovedEvent(&movement, product.Quantity+delta), in.UserID); err != nil {
+		return nil, err
+	}
+
+	return &movement, nil
+}
+
+// prepareReversal locks the movement to reverse, checks that it can be
+// reversed and builds the compensating movement that undoes its balance change
+func prepareReversal(tx *gorm.DB, id uint, notes string, userID uint) (*models.Stock, stockMovementInput, error) {
+	var original models.Stock
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Lots").Preload("Serials.Serial").First(&original, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, stockMovementInput{}, errMovementNotFound
+		}
+		return nil, stockMovementInput{}, err
+	}
+
+	switch {
+	case original.Status != models.StockStatusPosted:
+		return nil, stockMovementInput{}, validationError("Only posted movements can be reversed")
+	case original.ReversedBy != nil:
+		return nil, stockMovementInput{}, conflictError("Stock movement has already been reversed")
+	case original.ReversalOf != nil:
+		return nil, stockMovementInput{}, validationError("A reversal cannot itself be reversed")
+	case original.TransferID != nil:
+		return nil, stockMovementInput{}, validationError("Transfer movements are managed through their transfer")
+	case original.Type == "RETURN_IN", original.Type == "RESTOCK", original.Type == "SCRAP", original.Type == "RTV":
+		return nil, stockMovementInput{}, validationError("Return movements are managed through their RMA")
+	}
+
+	// Undo the change in balance rather than the requested quantity, so an
+	// ADJUST is reversed by the difference it made
+	delta := original.NewQuantity - original.OldQuantity
+	if delta == 0 {
+		return nil, stockMovementInput{}, validationError("Stock movement did not change the balance")
+	}
+
+	compensating := stockMovementInput{
+		ProductID:   original.ProductID,
+		WarehouseID: original.WarehouseID,
+		Type:        "OUT",
+		Quantity:    delta,
+		Reference:   original.Reference,
+		ReasonCode:  original.ReasonCode,
+		UnitCost:    original.UnitCost,
+		ReversalOf:  &original.ID,
+		Notes:       notes,
+		UserID:      userID,
+	}
+	if delta < 0 {
+		compensating.Type = "IN"
+		compensating.Quantity = -delta
+	}
+	if movementType, err := lookupMovementType(tx, original.Type); err == nil && !movementType.AffectsValuation {
+		compensating.Unvalued = true
+	}
+	// Put back into (or take out of) exactly the lots the original touched
+	for _, l := range original.Lots {
+		compensating.Lots = append(compensating.Lots, lotQuantity{LotID: l.LotID, Quantity: l.Quantity})
+	}
+	for _, l := range original.Serials {
+		compensating.Serials = append(compensating.Serials, l.Serial.SerialNumber)
+	}
+	if compensating.Notes == "" {
+		compensating.Notes = fmt.Sprintf("Reversal of movement #%d", original.ID)
+	}
+	return &original, compensating, nil
+}
+
+// postReversal posts the compensating movement built by prepareReversal and
+// marks the original as reversed
+func postReversal(tx *gorm.DB, ledger LedgerSettings, original *models.Stock, compensating stockMovementInput) (*models.Stock, error) {
+	movement, err := postStockMovement(tx, ledger, compensating)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := compensating.UserID
+	delta := original.NewQuantity - original.OldQuantity
+
+	now := time.Now()
+	if err := tx.Model(original).Updates(map[string]interface{}{
+		"reversed_by": movement.ID,
+		"reversed_at": now,
+		"updated_by":  userID,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	// A reversed receipt is no longer counted against its purchase order
+	if original.POLineID != nil {
+		if err := unreceivePurchaseOrderLine(tx, ledger, *original.POLineID, delta, userID); err != nil {
+			return nil, err
+		}
+	}
+	// and a reversed shipment no longer counts towards its sales order
+	if original.SOLineID != nil {
+		if err := unfulfillSalesOrderLine(tx, *original.SOLineID, -delta, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return movement, nil
+}
+
+// lookupMovementType returns the catalogue entry for a movement type code.
+// System types fall back to their built-in definition so the ledger keeps
+// working before the catalogue has been seeded.
+func lookupMovementType(tx *gorm.DB, code string) (models.MovementType, error) {
+	var movementType models.MovementType
+	err := tx.Where("code = ?", code).First(&movementType).Error
+	if err == nil {
+		return movementType, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return movementType, err
+	}
+
+	for _, t := range models.SystemMovementTypes() {
+		if t.Code == code {
+			return t, nil
+		}
+	}
+	return movementType, validationError(fmt.Sprintf("Unknown movement type %s", code))
+}
+
+// movementUnitCost values a change in balance of delta units
+func movementUnitCost(tx *gorm.DB, product models.Product, delta int, in stockMovementInput) (float64, error) {
+	switch {
+	case in.Type == "TRANSFER_IN" && in.UnitCost > 0:
+		return in.UnitCost, nil
+	case in.Type == "TRANSIT_LOSS":
+		// The caller already consumed the lost units' cost layers
+		return in.UnitCost, nil
+	case in.Type == "TRANSFER_IN", in.Type == "TRANSFER_OUT":
+		return currentUnitCost(tx, product)
+	case delta > 0:
+		return receiptUnitCost(tx, product, in.UnitCost)
+	case delta < 0:
+		return issueUnitCost(tx, product, -delta)
+	}
+	return 0, nil
+}
+
+// resolveWarehouse returns the active warehouse with the given ID, or the
+// default warehouse when id is 0
+func resolveWarehouse(tx *gorm.DB, id uint) (models.Warehouse, error) {
+	var warehouse models.Warehouse
+	query := tx.Where("is_active = ?", true)
+	if id == 0 {
+		query = query.Where("is_default = ?", true)
+	} else {
+		query = query.Where("id = ?", id)
+	}
+
+	if err := query.First(&warehouse).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return warehouse, errWarehouseNotFound
+		}
+		return warehouse, err
+	}
+	return warehouse, nil
+}
+
+// syncProductQuantity sets Product.Quantity to the sum of its warehouse balances
+func syncProductQuantity(tx *gorm.DB, productID uint, userID uint) error {
+	var total int
+	if err := tx.Model(&models.StockBalance{}).
+		Where("product_id = ?", productID).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&total).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Product{}).
+		Where("id = ?", productID).
+		Updates(map[string]interface{}{"quantity": total, "updated_by": userID}).Error
+}
+
+// respondStockError maps ledger errors onto HTTP responses
+func respondStockError(c *gin.Context, err error) {
+	var insufficient *insufficientStockError
+	switch {
+	case errors.As(err, &insufficient):
+		resp := gin.H{
+			"error":        insufficient.Error(),
+			"warehouse_id": insufficient.WarehouseID,
+			"available":    insufficient.Available,
+			"requested":    insufficient.Requested,
+		}
+		if insufficient.LotNumber != "" {
+			resp["lot_number"] = insufficient.LotNumber
+		}
+		c.JSON(http.StatusBadRequest, resp)
+	case errors.As(err, new(validationError)):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, new(conflictError)):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, new(notFoundError)):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.As(err, new(forbiddenError)):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock movement"})
+	}
+}
+
+// invalidateStockCaches drops every cache entry that depends on stock levels
+func invalidateStockCaches(client *redis.Client, ctx context.Context, productIDs ...uint) {
+	// 1. Stock Lists (History & Current Stock) --> เพราะมี Movement ใหม่
+	cache.InvalidateStockList(client, ctx)
+
+	// 2. Product Lists --> เพราะ Quantity เปลี่ยน
+	cache.InvalidateProductList(client, ctx)
+
+	// 3. Specific Product --> เพราะ Quantity เปลี่ยน
+	for _, id := range productIDs {
+		client.Del(ctx, cache.GenerateProductKey(id))
+	}
+}
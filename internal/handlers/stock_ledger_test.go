@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+)
+
+// TestPostStockMovementConcurrent hammers one product from many goroutines and
+// checks that the balance always equals what the ledger says it should be
+func TestPostStockMovementConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, models.RoleUser)
+	product := createTestProduct(t, db, user.ID)
+
+	if _, err := postTestMovement(db, stockMovementInput{
+		ProductID: product.ID, Type: "IN", Quantity: 50, UserID: user.ID,
+	}); err != nil {
+		t.Fatalf("initial IN: %v", err)
+	}
+
+	const workers, rounds = 20, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*rounds)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				in := stockMovementInput{ProductID: product.ID, Type: "OUT", Quantity: 3, UserID: user.ID}
+				if (w+r)%2 == 0 {
+					in.Type, in.Quantity = "IN", 2
+				}
+				_, err := postTestMovement(db, in)
+				// Running out is expected once the OUTs get ahead of the INs
+				if err != nil && !errors.As(err, new(*insufficientStockError)) {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("post movement: %v", err)
+	}
+
+	var ledger []models.Stock
+	if err := db.Where("product_id = ? AND status = ?", product.ID, models.StockStatusPosted).
+		Order("id").Find(&ledger).Error; err != nil {
+		t.Fatalf("load ledger: %v", err)
+	}
+
+	sum := 0
+	previous := 0
+	for _, m := range ledger {
+		if m.OldQuantity != previous {
+			t.Errorf("movement %d starts from %d, previous movement left %d", m.ID, m.OldQuantity, previous)
+		}
+		if m.NewQuantity < 0 {
+			t.Errorf("movement %d left a negative balance %d", m.ID, m.NewQuantity)
+		}
+		sum += m.NewQuantity - m.OldQuantity
+		previous = m.NewQuantity
+	}
+
+	var quantity int
+	if err := db.Model(&models.Product{}).Where("id = ?", product.ID).Pluck("quantity", &quantity).Error; err != nil {
+		t.Fatalf("load product: %v", err)
+	}
+	if quantity != sum {
+		t.Errorf("products.quantity = %d, ledger sums to %d", quantity, sum)
+	}
+
+	var balance models.StockBalance
+	if err := db.Where("product_id = ?", product.ID).First(&balance).Error; err != nil {
+		t.Fatalf("load balance: %v", err)
+	}
+	if balance.Quantity != sum {
+		t.Errorf("stock_balances.quantity = %d, ledger sums to %d", balance.Quantity, sum)
+	}
+}
+
+// TestReservedOutKeepsOtherReservations ships more than one order's
+// reservation and checks that it cannot take the stock held for another order
+func TestReservedOutKeepsOtherReservations(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, models.RoleUser)
+	product := createTestProduct(t, db, user.ID)
+	warehouse, err := resolveWarehouse(db, 0)
+	if err != nil {
+		t.Fatalf("default warehouse: %v", err)
+	}
+
+	if _, err := postTestMovement(db, stockMovementInput{
+		ProductID: product.ID, WarehouseID: warehouse.ID, Type: "IN", Quantity: 10, UserID: user.ID,
+	}); err != nil {
+		t.Fatalf("initial IN: %v", err)
+	}
+	// Two orders reserve 5 each
+	for i := 0; i < 2; i++ {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return reserveStock(tx, product.ID, warehouse.ID, 5)
+		}); err != nil {
+			t.Fatalf("reserve: %v", err)
+		}
+	}
+
+	in := stockMovementInput{
+		ProductID: product.ID, WarehouseID: warehouse.ID, Type: "OUT", Quantity: 6, Reservation: 5, UserID: user.ID,
+	}
+	_, err = postTestMovement(db, in)
+	var insufficient *insufficientStockError
+	if !errors.As(err, &insufficient) {
+		t.Fatalf("shipping 6 against a reservation of 5: got %v, want insufficient stock", err)
+	}
+	if insufficient.Available != 5 {
+		t.Errorf("available = %d, want 5", insufficient.Available)
+	}
+
+	in.Quantity = 5
+	if _, err := postTestMovement(db, in); err != nil {
+		t.Fatalf("shipping the reserved 5: %v", err)
+	}
+}
+
+// TestSetBelowReservedIsRejected checks that an adjustment cannot leave less
+// stock on hand than sales orders have reserved
+func TestSetBelowReservedIsRejected(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, models.RoleUser)
+	product := createTestProduct(t, db, user.ID)
+	warehouse, err := resolveWarehouse(db, 0)
+	if err != nil {
+		t.Fatalf("default warehouse: %v", err)
+	}
+
+	if _, err := postTestMovement(db, stockMovementInput{
+		ProductID: product.ID, WarehouseID: warehouse.ID, Type: "IN", Quantity: 10, UserID: user.ID,
+	}); err != nil {
+		t.Fatalf("initial IN: %v", err)
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return reserveStock(tx, product.ID, warehouse.ID, 6)
+	}); err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+
+	adjust := stockMovementInput{
+		ProductID: product.ID, WarehouseID: warehouse.ID, Type: "ADJUST", Quantity: 5,
+		ReasonCode: "DAMAGED", UserID: user.ID,
+	}
+	if _, err := postTestMovement(db, adjust); !errors.As(err, new(conflictError)) {
+		t.Fatalf("adjusting to 5 with 6 reserved: got %v, want a conflict", err)
+	}
+
+	var balance models.StockBalance
+	if err := db.Where("product_id = ? AND warehouse_id = ?", product.ID, warehouse.ID).First(&balance).Error; err != nil {
+		t.Fatalf("load balance: %v", err)
+	}
+	if balance.Quantity != 10 {
+		t.Errorf("balance = %d after a rejected adjustment, want 10", balance.Quantity)
+	}
+
+	adjust.Quantity = 6
+	if _, err := postTestMovement(db, adjust); err != nil {
+		t.Fatalf("adjusting to exactly the reserved 6: %v", err)
+	}
+}
+
+// TestSetBelowQuarantinedIsRejected checks that an adjustment cannot count
+// away returned stock still waiting in quarantine
+func TestSetBelowQuarantinedIsRejected(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, models.RoleUser)
+	product := createTestProduct(t, db, user.ID)
+
+	if _, err := postTestMovement(db, stockMovementInput{
+		ProductID: product.ID, Type: "IN", Quantity: 10, UserID: user.ID,
+	}); err != nil {
+		t.Fatalf("initial IN: %v", err)
+	}
+	if _, err := postTestMovement(db, stockMovementInput{
+		ProductID: product.ID, Type: "RETURN_IN", Quantity: 4, Quarantine: true, UserID: user.ID,
+	}); err != nil {
+		t.Fatalf("return into quarantine: %v", err)
+	}
+
+	adjust := stockMovementInput{ProductID: product.ID, Type: "ADJUST", Quantity: 3, ReasonCode: "DAMAGED", UserID: user.ID}
+	if _, err := postTestMovement(db, adjust); !errors.As(err, new(conflictError)) {
+		t.Fatalf("adjusting to 3 with 4 in quarantine: got %v, want a conflict", err)
+	}
+	adjust.Quantity = 4
+	if _, err := postTestMovement(db, adjust); err != nil {
+		t.Fatalf("adjusting to exactly the quarantined 4: %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+)
+
+const errLotNotFound = notFoundError("Lot not found")
+
+// lotQuantity is an explicit quantity of one lot for a movement
+type lotQuantity struct {
+	LotID    uint
+	Quantity int
+}
+
+// applyLotChanges moves the lot balances of a product at a warehouse by the
+// same delta as its stock balance and returns the allocations to record
+// against the movement.
+//
+//   - explicit lots (reversals, transfer receipts) are applied as given
+//   - stock lost in transit left its lots when it was shipped, so its lots
+//     are only recorded
+//   - an increase with a lot number goes into that lot, creating it if needed
+//   - a decrease with a lot number is taken from that lot only
+//   - any other decrease is picked first-expired-first-out from unexpired
+//     lots, then from stock held without a lot. A write-off (an adjustment
+//     or a movement with a reason code) may also take expired lots, and
+//     takes them first.
+//
+// The product row is already locked by postStockMovement, so the lot rows of
+// that product need no locks of their own.
+func applyLotChanges(tx *gorm.DB, productID, warehouseID uint, oldQty, delta int, in stockMovementInput, writeOff bool) ([]models.StockLotAllocation, error) {
+	if delta == 0 {
+		if in.Type == "TRANSIT_LOSS" {
+			return recordLots(tx, productID, in.Lots)
+		}
+		return nil, nil
+	}
+
+	if len(in.Lots) > 0 {
+		return applyExplicitLots(tx, productID, warehouseID, delta, in.Lots)
+	}
+
+	if delta > 0 {
+		if in.LotNumber == "" {
+			return nil, nil
+		}
+		lot, err := findOrCreateLot(tx, productID, in)
+		if err != nil {
+			return nil, err
+		}
+		return applyExplicitLots(tx, productID, warehouseID, delta, []lotQuantity{{LotID: lot.ID, Quantity: delta}})
+	}
+
+	need := -delta
+	if in.LotNumber != "" {
+		var lot models.Lot
+		if err := tx.Where("product_id = ? AND lot_number = ?", productID, in.LotNumber).First(&lot).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errLotNotFound
+			}
+			return nil, err
+		}
+		return applyExplicitLots(tx, productID, warehouseID, delta, []lotQuantity{{LotID: lot.ID, Quantity: need}})
+	}
+
+	return pickLotsFEFO(tx, productID, warehouseID, oldQty, need, writeOff)
+}
+
+// applyExplicitLots adds (delta > 0) or removes (delta < 0) the given lot quantities
+func applyExplicitLots(tx *gorm.DB, productID, warehouseID uint, delta int, lots []lotQuantity) ([]models.StockLotAllocation, error) {
+	total := 0
+	for _, l := range lots {
+		total += l.Quantity
+	}
+	if total > max(delta, -delta) {
+		return nil, validationError("Lot quantities exceed the movement quantity")
+	}
+
+	allocations := make([]models.StockLotAllocation, 0, len(lots))
+	for _, l := range lots {
+		var lot models.Lot
+		if err := tx.Where("id = ? AND product_id = ?", l.LotID, productID).First(&lot).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errLotNotFound
+			}
+			return nil, err
+		}
+
+		var balance models.LotBalance
+		if err := tx.Where(models.LotBalance{LotID: lot.ID, WarehouseID: warehouseID}).
+			Attrs(models.LotBalance{ProductID: productID}).
+			FirstOrCreate(&balance).Error; err != nil {
+			return nil, err
+		}
+
+		if delta > 0 {
+			balance.Quantity += l.Quantity
+		} else {
+			if balance.Quantity < l.Quantity {
+				return nil, &insufficientStockError{
+					WarehouseID: warehouseID,
+					LotNumber:   lot.LotNumber,
+					Available:   balance.Quantity,
+					Requested:   l.Quantity,
+				}
+			}
+			balance.Quantity -= l.Quantity
+		}
+		if err := tx.Save(&balance).Error; err != nil {
+			return nil, err
+		}
+
+		allocations = append(allocations, models.StockLotAllocation{LotID: lot.ID, Quantity: l.Quantity, Lot: &lot})
+	}
+	return allocations, nil
+}
+
+// recordLots returns allocations for the given lot quantities without
+// changing any lot balance
+func recordLots(tx *gorm.DB, productID uint, lots []lotQuantity) ([]models.StockLotAllocation, error) {
+	allocations := make([]models.StockLotAllocation, 0, len(lots))
+	for _, l := range lots {
+		var lot models.Lot
+		if err := tx.Where("id = ? AND product_id = ?", l.LotID, productID).First(&lot).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errLotNotFound
+			}
+			return nil, err
+		}
+		allocations = append(allocations, models.StockLotAllocation{LotID: lot.ID, Quantity: l.Quantity, Lot: &lot})
+	}
+	return allocations, nil
+}
+
+// pickLotsFEFO takes need units from unexpired lots, earliest expiry first,
+// and leaves the rest to stock held without a lot. includeExpired lets
+// expired lots be picked too.
+func pickLotsFEFO(tx *gorm.DB, productID, warehouseID uint, oldQty, need int, includeExpired bool) ([]models.StockLotAllocation, error) {
+	var balances []models.LotBalance
+	if err := tx.Preload("Lot").
+		Where("product_id = ? AND warehouse_id = ? AND quantity > 0", productID, warehouseID).
+		Find(&balances).Error; err != nil {
+		return nil, err
+	}
+	sortLotBalancesFEFO(balances)
+
+	lotTotal := 0
+	for _, b := range balances {
+		lotTotal += b.Quantity
+	}
+	untracked := oldQty - lotTotal
+
+	// Lots expire at the start of their expiry day in the server's time zone
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var picks []lotQuantity
+	remaining := need
+	for _, b := range balances {
+		if remaining == 0 {
+			break
+		}
+		if !includeExpired && b.Lot.ExpiryDate != nil && b.Lot.ExpiryDate.Before(today) {
+			continue
+		}
+		take := min(b.Quantity, remaining)
+		picks = append(picks, lotQuantity{LotID: b.LotID, Quantity: take})
+		remaining -= take
+	}
+
+	if remaining > untracked {
+		return nil, &insufficientStockError{
+			WarehouseID: warehouseID,
+			Available:   need - remaining + max(untracked, 0),
+			Requested:   need,
+		}
+	}
+
+	if len(picks) == 0 {
+		return nil, nil
+	}
+	return applyExplicitLots(tx, productID, warehouseID, -need, picks)
+}
+
+// sortLotBalancesFEFO orders lot balances by expiry date, undated lots last
+func sortLotBalancesFEFO(balances []models.LotBalance) {
+	sort.SliceStable(balances, func(i, j int) bool {
+		a, b := balances[i].Lot.ExpiryDate, balances[j].Lot.ExpiryDate
+		switch {
+		case a == nil && b == nil:
+			return balances[i].LotID < balances[j].LotID
+		case a == nil:
+			return false
+		case b == nil:
+			return true
+		case !a.Equal(*b):
+			return a.Before(*b)
+		default:
+			return balances[i].LotID < balances[j].LotID
+		}
+	})
+}
+
+// findOrCreateLot returns the product's lot with the input's lot number,
+// creating it with the input's dates on first receipt
+func findOrCreateLot(tx *gorm.DB, productID uint, in stockMovementInput) (models.Lot, error) {
+	lot := models.Lot{
+		ProductID:       productID,
+		LotNumber:       in.LotNumber,
+		ManufactureDate: in.ManufactureDate,
+		ExpiryDate:      in.ExpiryDate,
+		CreatedBy:       in.UserID,
+	}
+	err := tx.Where(models.Lot{ProductID: productID, LotNumber: in.LotNumber}).
+		Attrs(lot).
+		FirstOrCreate(&lot).Error
+	return lot, err
+}
+
+// transferLotsInTransit returns the lots shipped on a transfer for a product
+// that have not yet been received, earliest expiry first
+func transferLotsInTransit(tx *gorm.DB, transferID, productID uint, quantity int) ([]lotQuantity, error) {
+	type row struct {
+		LotID    uint
+		Quantity int
+	}
+	var rows []row
+	if err := tx.Table("stock_lot_allocations").
+		Select("stock_lot_allocations.lot_id, SUM(CASE WHEN stocks.type = 'TRANSFER_OUT' THEN stock_lot_allocations.quantity ELSE -stock_lot_allocations.quantity END) AS quantity").
+		Joins("JOIN stocks ON stocks.id = stock_lot_allocations.stock_id").
+		Joins("JOIN lots ON lots.id = stock_lot_allocations.lot_id").
+		Where("stocks.transfer_id = ? AND stocks.product_id = ?", transferID, productID).
+		Group("stock_lot_allocations.lot_id, lots.expiry_date").
+		Having("SUM(CASE WHEN stocks.type = 'TRANSFER_OUT' THEN stock_lot_allocations.quantity ELSE -stock_lot_allocations.quantity END) > 0").
+		Order("lots.expiry_date ASC NULLS LAST, stock_lot_allocations.lot_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var lots []lotQuantity
+	remaining := quantity
+	for _, r := range rows {
+		if remaining == 0 {
+			break
+		}
+		take := min(r.Quantity, remaining)
+		lots = append(lots, lotQuantity{LotID: r.LotID, Quantity: take})
+		remaining -= take
+	}
+	return lots, nil
+}
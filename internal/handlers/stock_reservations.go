@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// lockBalance locks the product row, then its balance at the warehouse, in
+// the same order postStockMovement takes them
+func lockBalance(tx *gorm.DB, productID, warehouseID uint) (models.StockBalance, error) {
+	var balance models.StockBalance
+
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return balance, errProductNotFound
+		}
+		return balance, err
+	}
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(models.StockBalance{ProductID: productID, WarehouseID: warehouseID}).
+		FirstOrCreate(&balance).Error
+	return balance, err
+}
+
+// reserveStock holds quantity units of a product at a warehouse, failing if
+// less than that is available to promise
+func reserveStock(tx *gorm.DB, productID, warehouseID uint, quantity int) error {
+	balance, err := lockBalance(tx, productID, warehouseID)
+	if err != nil {
+		return err
+	}
+
+	available := balance.Quantity - balance.ReservedQuantity - balance.QuarantinedQuantity
+	if available < quantity {
+		return &insufficientStockError{
+			WarehouseID: warehouseID,
+			Available:   max(available, 0),
+			Requested:   quantity,
+		}
+	}
+
+	return tx.Model(&balance).Update("reserved_quantity", balance.ReservedQuantity+quantity).Error
+}
+
+// releaseStock gives back quantity units reserved at a warehouse
+func releaseStock(tx *gorm.DB, productID, warehouseID uint, quantity int) error {
+	balance, err := lockBalance(tx, productID, warehouseID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&balance).Update("reserved_quantity", max(balance.ReservedQuantity-quantity, 0)).Error
+}
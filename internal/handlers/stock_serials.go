@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+)
+
+// applySerialChanges registers, moves or retires the serials named by a
+// movement of a serial-tracked product. Every unit added to or removed from
+// the warehouse balance must be named exactly once.
+func applySerialChanges(tx *gorm.DB, product models.Product, warehouseID uint, delta int, in stockMovementInput) ([]models.Serial, error) {
+	if product.TrackingMode != models.TrackingModeSerial {
+		if len(in.Serials) > 0 {
+			return nil, validationError("Product is not serial-tracked")
+		}
+		return nil, nil
+	}
+
+	serials := in.Serials
+	if len(serials) == 0 && in.Type == "TRANSFER_IN" && in.TransferID != nil {
+		var err error
+		serials, err = transferSerialsInTransit(tx, *in.TransferID, product.ID, delta)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	need := max(delta, -delta)
+	if in.Type == "RESTOCK" || in.Type == "TRANSIT_LOSS" {
+		need = in.Quantity
+	}
+	if len(serials) != need {
+		return nil, validationError(fmt.Sprintf("Expected %d serial numbers, got %d", need, len(serials)))
+	}
+
+	seen := make(map[string]bool, len(serials))
+	result := make([]models.Serial, 0, len(serials))
+	for _, sn := range serials {
+		if seen[sn] {
+			return nil, validationError(fmt.Sprintf("Duplicate serial number %s", sn))
+		}
+		seen[sn] = true
+
+		var serial models.Serial
+		err := tx.Where("serial_number = ?", sn).First(&serial).Error
+		found := err == nil
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if found && serial.ProductID != product.ID {
+			return nil, conflictError(fmt.Sprintf("Serial %s belongs to another product", sn))
+		}
+
+		switch {
+		case in.Type == "RESTOCK" || (delta < 0 && in.Quarantine):
+			// Quarantined serials are released by RESTOCK or leave by SCRAP/RTV
+			if !found {
+				return nil, notFoundError(fmt.Sprintf("Serial %s not found", sn))
+			}
+			if serial.Status != models.SerialStatusQuarantined || serial.WarehouseID == nil || *serial.WarehouseID != warehouseID {
+				return nil, validationError(fmt.Sprintf("Serial %s is not quarantined at this warehouse", sn))
+			}
+		case (delta > 0 && in.Type == "TRANSFER_IN") || in.Type == "TRANSIT_LOSS":
+			if !found || serial.Status != models.SerialStatusInTransit {
+				return nil, validationError(fmt.Sprintf("Serial %s is not in transit", sn))
+			}
+		case delta > 0:
+			if found && serial.Status != models.SerialStatusOut {
+				return nil, conflictError(fmt.Sprintf("Serial %s is already registered", sn))
+			}
+			if !found {
+				serial = models.Serial{SerialNumber: sn, ProductID: product.ID, CreatedBy: in.UserID}
+			}
+		default:
+			if !found {
+				return nil, notFoundError(fmt.Sprintf("Serial %s not found", sn))
+			}
+			if serial.Status != models.SerialStatusInStock || serial.WarehouseID == nil || *serial.WarehouseID != warehouseID {
+				return nil, validationError(fmt.Sprintf("Serial %s is not in stock at this warehouse", sn))
+			}
+		}
+
+		switch {
+		case in.Type == "RESTOCK":
+			serial.Status = models.SerialStatusInStock
+		case delta > 0 && in.Quarantine:
+			wh := warehouseID
+			serial.Status = models.SerialStatusQuarantined
+			serial.WarehouseID = &wh
+		case delta > 0:
+			wh := warehouseID
+			serial.Status = models.SerialStatusInStock
+			serial.WarehouseID = &wh
+		case in.Type == "TRANSFER_OUT":
+			serial.Status = models.SerialStatusInTransit
+			serial.WarehouseID = nil
+		default:
+			serial.Status = models.SerialStatusOut
+			serial.WarehouseID = nil
+		}
+		if err := tx.Save(&serial).Error; err != nil {
+			return nil, err
+		}
+		result = append(result, serial)
+	}
+
+	return result, nil
+}
+
+// transferSerialsInTransit returns up to n serials shipped on a transfer that
+// have not been received yet
+func transferSerialsInTransit(tx *gorm.DB, transferID, productID uint, n int) ([]string, error) {
+	var serials []string
+	err := tx.Model(&models.Serial{}).
+		Distinct("serials.id", "serials.serial_number").
+		Joins("JOIN stock_serials ON stock_serials.serial_id = serials.id").
+		Joins("JOIN stocks ON stocks.id = stock_serials.stock_id").
+		Where("stocks.transfer_id = ? AND stocks.type = ? AND serials.product_id = ? AND serials.status = ?",
+			transferID, "TRANSFER_OUT", productID, models.SerialStatusInTransit).
+		Order("serials.id").
+		Limit(n).
+		Pluck("serials.serial_number", &serials).Error
+	return serials, err
+}
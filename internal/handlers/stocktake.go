@@ -0,0 +1,626 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const errStocktakeNotFound = notFoundError("Stocktake not found")
+
+// StocktakeHandler holds dependencies for stocktake handling
+type StocktakeHandler struct {
+	db     *gorm.DB
+	cache  *redis.Client
+	ledger LedgerSettings
+}
+
+func NewStocktakeHandler(db *gorm.DB, cache *redis.Client, ledger LedgerSettings) *StocktakeHandler {
+	return &StocktakeHandler{
+		db:     db,
+		cache:  cache,
+		ledger: ledger,
+	}
+}
+
+// CreateStocktakeRequest selects the products to count at a warehouse. With no
+// filters every active product is counted.
+type CreateStocktakeRequest struct {
+	WarehouseID    uint   `json:"warehouse_id"` // optional, defaults to the default warehouse
+	ProductIDs     []uint `json:"product_ids"`
+	Category       string `json:"category"`
+	Location       string `json:"location"` // Product.Location, e.g. an aisle or bin
+	RequiredCounts int    `json:"required_counts" binding:"omitempty,min=1,max=5"`
+	Notes          string `json:"notes"`
+}
+
+// StocktakeCountLineRequest is the quantity a counter found for one product
+type StocktakeCountLineRequest struct {
+	ProductID uint   `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"min=0"`
+	Notes     string `json:"notes"`
+}
+
+// SubmitStocktakeCountsRequest records counts for one or more lines
+type SubmitStocktakeCountsRequest struct {
+	Lines []StocktakeCountLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+// CloseStocktakeRequest approves or cancels a stocktake
+type CloseStocktakeRequest struct {
+	Notes string `json:"notes"`
+}
+
+// StocktakeVariance compares a line's accepted count with the stock on hand
+type StocktakeVariance struct {
+	LineID           uint   `json:"line_id"`
+	ProductID        uint   `json:"product_id"`
+	SKU              string `json:"sku"`
+	Name             string `json:"name"`
+	Status           string `json:"status"`
+	SnapshotQuantity int    `json:"snapshot_quantity"`
+	MovedSince       int    `json:"moved_since"` // net movements posted since the snapshot
+	CurrentQuantity  int    `json:"current_quantity"`
+	ProductQuantity  int    `json:"product_quantity"` // Product.Quantity across all warehouses
+	CountedQuantity  *int   `json:"counted_quantity"`
+	ExpectedQuantity *int   `json:"expected_quantity"`
+	Variance         int    `json:"variance"`
+}
+
+// openStocktake snapshots the warehouse balances of the given products and
+// creates an OPEN stocktake for them. Serial-tracked products are counted by
+// serial number through their own movements and are left out.
+func openStocktake(tx *gorm.DB, warehouseID uint, products []models.Product, requiredCounts int, notes string, userID uint) (*models.Stocktake, error) {
+	warehouse, err := resolveWarehouse(tx, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	if requiredCounts == 0 {
+		requiredCounts = 1
+	}
+
+	productIDs := make([]uint, 0, len(products))
+	classes := make(map[uint]string, len(products))
+	for _, p := range products {
+		if p.TrackingMode != models.TrackingModeSerial {
+			productIDs = append(productIDs, p.ID)
+			classes[p.ID] = p.ABCClass
+		}
+	}
+	if len(productIDs) == 0 {
+		return nil, validationError("No products to count")
+	}
+
+	// A product can only be on one open count per warehouse
+	var busy []uint
+	if err := tx.Model(&models.StocktakeLine{}).
+		Joins("JOIN stocktakes ON stocktakes.id = stocktake_lines.stocktake_id").
+		Where("stocktakes.warehouse_id = ? AND stocktakes.status = ? AND stocktake_lines.product_id IN ?",
+			warehouse.ID, models.StocktakeStatusOpen, productIDs).
+		Pluck("stocktake_lines.product_id", &busy).Error; err != nil {
+		return nil, err
+	}
+	if len(busy) > 0 {
+		return nil, conflictError(fmt.Sprintf("Product %d is already being counted at this warehouse", busy[0]))
+	}
+
+	var balances []models.StockBalance
+	if err := tx.Where("warehouse_id = ? AND product_id IN ?", warehouse.ID, productIDs).Find(&balances).Error; err != nil {
+		return nil, err
+	}
+	onHand := make(map[uint]int, len(balances))
+	for _, b := range balances {
+		onHand[b.ProductID] = b.Quantity
+	}
+
+	sort.Slice(productIDs, func(i, j int) bool { return productIDs[i] < productIDs[j] })
+	lines := make([]models.StocktakeLine, 0, len(productIDs))
+	for _, id := range productIDs {
+		lines = append(lines, models.StocktakeLine{
+			ProductID:        id,
+			SnapshotQuantity: onHand[id],
+			ABCClass:         classes[id],
+			Status:           models.StocktakeLineStatusPending,
+		})
+	}
+
+	stocktake := models.Stocktake{
+		WarehouseID:    warehouse.ID,
+		Status:         models.StocktakeStatusOpen,
+		RequiredCounts: requiredCounts,
+		Notes:          notes,
+		FrozenAt:       time.Now(),
+		CreatedBy:      userID,
+		UpdatedBy:      userID,
+		Lines:          lines,
+	}
+	if err := tx.Create(&stocktake).Error; err != nil {
+		return nil, err
+	}
+
+	stocktake.Number = fmt.Sprintf("ST-%06d", stocktake.ID)
+	if err := tx.Model(&stocktake).Update("number", stocktake.Number).Error; err != nil {
+		return nil, err
+	}
+	return &stocktake, nil
+}
+
+// loadStocktake locks a stocktake and reads its lines (ordered by product) and their counts inside tx
+func loadStocktake(tx *gorm.DB, id int) (*models.Stocktake, error) {
+	var stocktake models.Stocktake
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("product_id, id") }).
+		Preload("Lines.Counts", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&stocktake, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errStocktakeNotFound
+		}
+		return nil, err
+	}
+	return &stocktake, nil
+}
+
+// evaluateStocktakeLine accepts a line once its last `required` counts were
+// made by different counters and found the same variance, and asks for a
+// recount when they disagree. Counts are compared by variance rather than
+// quantity so stock moving between two counts does not force a recount.
+func evaluateStocktakeLine(line *models.StocktakeLine, required int) {
+	if len(line.Counts) < required {
+		line.Status = models.StocktakeLineStatusPending
+		return
+	}
+
+	recent := line.Counts[len(line.Counts)-required:]
+	last := recent[len(recent)-1]
+	counters := make(map[uint]bool, required)
+	agree := true
+	for _, count := range recent {
+		if counters[count.CountedBy] || count.Variance() != last.Variance() {
+			agree = false
+			break
+		}
+		counters[count.CountedBy] = true
+	}
+
+	if !agree {
+		line.Status = models.StocktakeLineStatusRecount
+		line.CountedQuantity = nil
+		line.ExpectedQuantity = nil
+		line.Variance = 0
+		return
+	}
+
+	counted, expected := last.Quantity, last.ExpectedQuantity
+	line.Status = models.StocktakeLineStatusCounted
+	line.CountedQuantity = &counted
+	line.ExpectedQuantity = &expected
+	line.Variance = last.Variance()
+}
+
+// postStocktakeVariances posts an ADJUST for every counted line with a
+// variance, moving the current balance by the variance the count found.
+// Lines are posted in product order so locks are taken in a stable order.
+func postStocktakeVariances(tx *gorm.DB, ledger LedgerSettings, stocktake *models.Stocktake, notes string, userID uint) ([]uint, error) {
+	var pending int
+	for _, l := range stocktake.Lines {
+		if l.Status != models.StocktakeLineStatusCounted {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return nil, conflictError(fmt.Sprintf("%d lines still need counting", pending))
+	}
+
+	if notes == "" {
+		notes = fmt.Sprintf("Stocktake %s", stocktake.Number)
+	}
+
+	var productIDs []uint
+	for i := range stocktake.Lines {
+		line := &stocktake.Lines[i]
+		if line.Variance == 0 {
+			continue
+		}
+
+		balance, err := lockBalance(tx, line.ProductID, stocktake.WarehouseID)
+		if err != nil {
+			return nil, err
+		}
+		target := balance.Quantity + line.Variance
+		if target < 0 {
+			return nil, validationError(fmt.Sprintf("Product %d has moved since it was counted and needs a recount", line.ProductID))
+		}
+
+		movement, err := postStockMovement(tx, ledger, stockMovementInput{
+			ProductID:   line.ProductID,
+			WarehouseID: stocktake.WarehouseID,
+			Type:        "ADJUST",
+			Quantity:    target,
+			Reference:   stocktake.Number,
+			ReasonCode:  "COUNT_VARIANCE",
+			Notes:       notes,
+			UserID:      userID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		line.AdjustmentID = &movement.ID
+		line.VarianceValue = movement.TotalCost
+		if line.Variance < 0 {
+			line.VarianceValue = -movement.TotalCost
+		}
+		if err := tx.Omit("Product", "Counts").Save(line).Error; err != nil {
+			return nil, err
+		}
+		productIDs = append(productIDs, line.ProductID)
+	}
+	return productIDs, nil
+}
+
+// CreateStocktake godoc
+// @Summary Open a stocktake
+// @Description Snapshot the expected quantities of a set of products at a warehouse and open them for counting
+// @Tags stocktakes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param stocktake body CreateStocktakeRequest true "Stocktake scope"
+// @Success 201 {object} models.Stocktake
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /stocktakes [post]
+func (h *StocktakeHandler) CreateStocktake(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req CreateStocktakeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var stocktake *models.Stocktake
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("is_active = ?", true)
+		if len(req.ProductIDs) > 0 {
+			query = query.Where("id IN ?", req.ProductIDs)
+		}
+		if req.Category != "" {
+			query = query.Where("category = ?", req.Category)
+		}
+		if req.Location != "" {
+			query = query.Where("location = ?", req.Location)
+		}
+
+		var products []models.Product
+		if err := query.Find(&products).Error; err != nil {
+			return err
+		}
+
+		var err error
+		stocktake, err = openStocktake(tx, req.WarehouseID, products, req.RequiredCounts, req.Notes, userID.(uint))
+		return err
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, stocktake)
+}
+
+// GetStocktakes godoc
+// @Summary Get stocktakes
+// @Description List stocktakes, optionally filtered by status or warehouse
+// @Tags stocktakes
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Param status query string false "OPEN, POSTED or CANCELLED"
+// @Param warehouse_id query int false "Warehouse filter"
+// @Param cycle_date query string false "Daily cycle count date (YYYY-MM-DD)"
+// @Success 200 {object} map[string]interface{}
+// @Router /stocktakes [get]
+func (h *StocktakeHandler) GetStocktakes(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset := (page - 1) * limit
+
+	query := h.db.Model(&models.Stocktake{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+	}
+	if v := c.Query("cycle_date"); v != "" {
+		cycleDate, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("cycle_date = ?", cycleDate)
+	}
+
+	var total int64
+	var stocktakes []models.Stocktake
+	query.Count(&total)
+	query.Preload("Lines").Offset(offset).Limit(limit).Order("created_at DESC").Find(&stocktakes)
+
+	c.JSON(http.StatusOK, gin.H{
+		"stocktakes": stocktakes,
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
+
+// GetStocktakeByID godoc
+// @Summary Get a stocktake
+// @Description Get a stocktake with its counts and the variance of every line against the stock on hand
+// @Tags stocktakes
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Stocktake ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /stocktakes/{id} [get]
+func (h *StocktakeHandler) GetStocktakeByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stocktake ID"})
+		return
+	}
+
+	var stocktake models.Stocktake
+	if err := h.db.Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("product_id, id") }).
+		Preload("Lines.Product").Preload("Lines.Counts").Preload("Warehouse").
+		First(&stocktake, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stocktake not found"})
+		return
+	}
+
+	productIDs := make([]uint, 0, len(stocktake.Lines))
+	for _, l := range stocktake.Lines {
+		productIDs = append(productIDs, l.ProductID)
+	}
+	var balances []models.StockBalance
+	h.db.Where("warehouse_id = ? AND product_id IN ?", stocktake.WarehouseID, productIDs).Find(&balances)
+	onHand := make(map[uint]int, len(balances))
+	for _, b := range balances {
+		onHand[b.ProductID] = b.Quantity
+	}
+
+	variances := make([]StocktakeVariance, 0, len(stocktake.Lines))
+	totalVariance := 0
+	for _, l := range stocktake.Lines {
+		v := StocktakeVariance{
+			LineID:           l.ID,
+			ProductID:        l.ProductID,
+			Status:           l.Status,
+			SnapshotQuantity: l.SnapshotQuantity,
+			MovedSince:       onHand[l.ProductID] - l.SnapshotQuantity,
+			CurrentQuantity:  onHand[l.ProductID],
+			CountedQuantity:  l.CountedQuantity,
+			ExpectedQuantity: l.ExpectedQuantity,
+			Variance:         l.Variance,
+		}
+		if l.Product != nil {
+			v.SKU = l.Product.SKU
+			v.Name = l.Product.Name
+			v.ProductQuantity = l.Product.Quantity
+		}
+		variances = append(variances, v)
+		totalVariance += l.Variance
+	}
+
+	// Adjustments posted on approval
+	var movements []models.Stock
+	h.db.Where("reference = ? AND type = ?", stocktake.Number, "ADJUST").Order("product_id").Find(&movements)
+
+	c.JSON(http.StatusOK, gin.H{
+		"stocktake":      stocktake,
+		"variances":      variances,
+		"total_variance": totalVariance,
+		"movements":      movements,
+	})
+}
+
+// SubmitStocktakeCounts godoc
+// @Summary Submit counts
+// @Description Record what the current user counted for one or more products. Each count is compared with the balance at that moment; when the required counts disagree the line is flagged for recount.
+// @Tags stocktakes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Stocktake ID"
+// @Param counts body SubmitStocktakeCountsRequest true "Counted quantities"
+// @Success 200 {object} models.Stocktake
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /stocktakes/{id}/counts [post]
+func (h *StocktakeHandler) SubmitStocktakeCounts(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stocktake ID"})
+		return
+	}
+
+	var req SubmitStocktakeCountsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var stocktake *models.Stocktake
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		stocktake, err = loadStocktake(tx, id)
+		if err != nil {
+			return err
+		}
+		if stocktake.Status != models.StocktakeStatusOpen {
+			return conflictError("Stocktake is not open for counting")
+		}
+
+		lineIndex := make(map[uint]int, len(stocktake.Lines))
+		for i, l := range stocktake.Lines {
+			lineIndex[l.ProductID] = i
+		}
+
+		// Lock in product order so concurrent submissions take locks in a stable order
+		counts := append([]StocktakeCountLineRequest(nil), req.Lines...)
+		sort.SliceStable(counts, func(i, j int) bool { return counts[i].ProductID < counts[j].ProductID })
+
+		for _, r := range counts {
+			i, ok := lineIndex[r.ProductID]
+			if !ok {
+				return validationError(fmt.Sprintf("Product %d is not part of this stocktake", r.ProductID))
+			}
+			line := &stocktake.Lines[i]
+
+			// What the shelf should hold right now, including anything moved since
+			// the snapshot. The balance stays locked until the counts commit so no
+			// movement can post in between.
+			balance, err := lockBalance(tx, line.ProductID, stocktake.WarehouseID)
+			if err != nil {
+				return err
+			}
+
+			count := models.StocktakeCount{
+				StocktakeLineID:  line.ID,
+				Quantity:         r.Quantity,
+				ExpectedQuantity: balance.Quantity,
+				Notes:            r.Notes,
+				CountedBy:        userID.(uint),
+			}
+			if err := tx.Create(&count).Error; err != nil {
+				return err
+			}
+			line.Counts = append(line.Counts, count)
+
+			evaluateStocktakeLine(line, stocktake.RequiredCounts)
+			if err := tx.Omit("Product", "Counts").Save(line).Error; err != nil {
+				return err
+			}
+		}
+
+		stocktake.UpdatedBy = userID.(uint)
+		return tx.Omit("Lines").Save(stocktake).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stocktake)
+}
+
+// ApproveStocktake godoc
+// @Summary Approve a stocktake
+// @Description Post an ADJUST movement for every line with a variance, in one transaction, and close the stocktake. Every line must be counted.
+// @Tags stocktakes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Stocktake ID"
+// @Param approval body CloseStocktakeRequest false "Notes for the adjustments"
+// @Success 200 {object} models.Stocktake
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /stocktakes/{id}/approve [post]
+func (h *StocktakeHandler) ApproveStocktake(c *gin.Context) {
+	h.finishStocktake(c, models.StocktakeStatusPosted)
+}
+
+// CancelStocktake godoc
+// @Summary Cancel a stocktake
+// @Description Cancel an open stocktake without posting anything
+// @Tags stocktakes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Stocktake ID"
+// @Param cancellation body CloseStocktakeRequest false "Reason for cancelling"
+// @Success 200 {object} models.Stocktake
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /stocktakes/{id}/cancel [post]
+func (h *StocktakeHandler) CancelStocktake(c *gin.Context) {
+	h.finishStocktake(c, models.StocktakeStatusCancelled)
+}
+
+// finishStocktake moves an open stocktake to POSTED or CANCELLED
+func (h *StocktakeHandler) finishStocktake(c *gin.Context, status string) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stocktake ID"})
+		return
+	}
+
+	var req CloseStocktakeRequest
+	_ = c.ShouldBindJSON(&req)
+
+	var stocktake *models.Stocktake
+	var productIDs []uint
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		stocktake, err = loadStocktake(tx, id)
+		if err != nil {
+			return err
+		}
+		if stocktake.Status != models.StocktakeStatusOpen {
+			return conflictError("Only open stocktakes can be approved or cancelled")
+		}
+
+		now := time.Now()
+		if status == models.StocktakeStatusPosted {
+			productIDs, err = postStocktakeVariances(tx, h.ledger, stocktake, req.Notes, userID.(uint))
+			if err != nil {
+				return err
+			}
+			approver := userID.(uint)
+			stocktake.ApprovedBy = &approver
+			stocktake.ApprovedAt = &now
+		} else if req.Notes != "" {
+			stocktake.Notes = req.Notes
+		}
+
+		stocktake.Status = status
+		stocktake.ClosedAt = &now
+		stocktake.UpdatedBy = userID.(uint)
+		return tx.Omit("Lines").Save(stocktake).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	if len(productIDs) > 0 {
+		invalidateStockCaches(h.cache, c.Request.Context(), productIDs...)
+	}
+
+	c.JSON(http.StatusOK, stocktake)
+}
@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/cache"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	stockStreamChannel = "stream:stock" // Redis pub/sub channel shared by all instances
+	streamHeartbeat    = 25 * time.Second
+	streamClientBuffer = 256         // a client this far behind is dropped and resumes with Last-Event-ID
+	streamReplayBatch  = 500         // events read per query when resuming
+	streamReplayMax    = 5000        // beyond this a resuming client is told to reload instead
+	streamAuthRecheck  = time.Minute // how often an open stream checks its credential has not been revoked
+)
+
+// StreamMessage is one event pushed to stream clients. The SSE id is Offset,
+// so a reconnecting client resumes from the event stream.
+type StreamMessage struct {
+	Offset      int64           `json:"offset"`
+	Type        string          `json:"type"`
+	ProductID   uint            `json:"product_id"`
+	WarehouseID uint            `json:"warehouse_id,omitempty"`
+	Category    string          `json:"category"`
+	Location    string          `json:"location"`
+	Data        json.RawMessage `json:"data"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// StreamHandler pushes product and stock events to connected clients. Events
+// reach every instance through Redis pub/sub and are fanned out to the
+// clients connected to that instance.
+type StreamHandler struct {
+	db    *gorm.DB
+	cache *redis.Client
+
+	mu      sync.Mutex
+	clients map[chan StreamMessage]struct{}
+}
+
+func NewStreamHandler(db *gorm.DB, cache *redis.Client) *StreamHandler {
+	return &StreamHandler{
+		db:      db,
+		cache:   cache,
+		clients: map[chan StreamMessage]struct{}{},
+	}
+}
+
+// streamFilter is what a client asked to receive
+type streamFilter struct {
+	productIDs  map[uint]bool
+	category    string
+	location    string
+	warehouseID uint
+}
+
+func (f streamFilter) matches(m StreamMessage) bool {
+	if len(f.productIDs) > 0 && !f.productIDs[m.ProductID] {
+		return false
+	}
+	if f.category != "" && !strings.EqualFold(f.category, m.Category) {
+		return false
+	}
+	if f.location != "" && !strings.EqualFold(f.location, m.Location) {
+		return false
+	}
+	if f.warehouseID != 0 && m.WarehouseID != f.warehouseID {
+		return false
+	}
+	return true
+}
+
+// streamMessages attaches the product's category and location to events so
+// clients can filter on them
+func streamMessages(db *gorm.DB, events []models.OutboxEvent) ([]StreamMessage, error) {
+	messages := make([]StreamMessage, 0, len(events))
+	productIDs := []uint{}
+	for _, e := range events {
+		if e.Offset == nil {
+			continue
+		}
+		m := StreamMessage{
+			Offset:    *e.Offset,
+			Type:      e.Type,
+			Data:      e.Payload,
+			CreatedAt: e.CreatedAt,
+		}
+		if e.AggregateType == "product" {
+			m.ProductID = e.AggregateID
+		} else {
+			var moved StockMovedEvent
+			if err := json.Unmarshal(e.Payload, &moved); err != nil {
+				return nil, err
+			}
+			m.ProductID = moved.ProductID
+			m.WarehouseID = moved.WarehouseID
+		}
+		productIDs = append(productIDs, m.ProductID)
+		messages = append(messages, m)
+	}
+
+	var products []models.Product
+	if len(productIDs) > 0 {
+		if err := db.Select("id", "category", "location").Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[uint]models.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+	for i := range messages {
+		p := byID[messages[i].ProductID]
+		messages[i].Category = p.Category
+		messages[i].Location = p.Location
+	}
+	return messages, nil
+}
+
+// PublishConsumer is the event subscription that publishes the event stream
+// to Redis. It runs on one instance at a time, in offset order.
+func (h *StreamHandler) PublishConsumer() EventConsumer {
+	return func(ctx context.Context, events []models.OutboxEvent) error {
+		messages, err := streamMessages(h.db, events)
+		if err != nil {
+			return err
+		}
+		for _, m := range messages {
+			data, err := json.Marshal(m)
+			if err != nil {
+				return err
+			}
+			if err := h.cache.Publish(ctx, stockStreamChannel, data).Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Listen relays messages from Redis to this instance's clients until ctx is
+// cancelled
+func (h *StreamHandler) Listen(ctx context.Context) {
+	pubsub := h.cache.Subscribe(ctx, stockStreamChannel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var m StreamMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &m); err != nil {
+				log.Printf("Warning: Invalid stream message: %v", err)
+				continue
+			}
+			h.broadcast(m)
+		}
+	}
+}
+
+// broadcast hands a message to every client. A client whose buffer is full is
+// disconnected rather than slowing the others; it resumes with Last-Event-ID.
+func (h *StreamHandler) broadcast(m StreamMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		select {
+		case client <- m:
+		default:
+			delete(h.clients, client)
+			close(client)
+		}
+	}
+}
+
+func (h *StreamHandler) addClient() chan StreamMessage {
+	client := make(chan StreamMessage, streamClientBuffer)
+	h.mu.Lock()
+	h.clients[client] = struct{}{}
+	h.mu.Unlock()
+	return client
+}
+
+func (h *StreamHandler) removeClient(client chan StreamMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client)
+	}
+}
+
+// writeStreamEvent writes one SSE frame and flushes it to the client
+func writeStreamEvent(c *gin.Context, id, event string, data []byte) {
+	if id != "" {
+		fmt.Fprintf(c.Writer, "id: %s\n", id)
+	}
+	fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, data)
+	c.Writer.Flush()
+}
+
+// checkStreamCredential fails once the token, session or API key a stream was
+// opened with has been revoked, or when revocation cannot be checked
+func (h *StreamHandler) checkStreamCredential(c *gin.Context) error {
+	ctx := c.Request.Context()
+	if keyID, ok := c.Get("apiKeyID"); ok {
+		var key models.APIKey
+		if err := h.db.WithContext(ctx).First(&key, keyID).Error; err != nil {
+			return err
+		}
+		if key.RevokedAt != nil {
+			return errors.New("API key revoked")
+		}
+		return nil
+	}
+
+	jti, sid := c.GetString("tokenID"), c.GetString("sessionID")
+	if jti == "" && sid == "" {
+		return nil
+	}
+	revoked, err := h.cache.MGet(ctx, cache.GenerateRevokedTokenKey(jti), cache.GenerateRevokedSessionKey(sid)).Result()
+	if err != nil {
+		return err
+	}
+	for _, v := range revoked {
+		if v != nil {
+			return errors.New("token revoked")
+		}
+	}
+	return nil
+}
+
+// StreamStock godoc
+// @Summary Stream stock updates
+// @Description Server-Sent Events stream of product and stock events (ProductCreated, ProductUpdated, ProductDeleted, StockMoved, StockMovementPending, StockMovementRejected). Each event's id is its offset; reconnect with the Last-Event-ID header (or last_event_id) to receive what was missed. A reset event means the client was too far behind and should reload. The stream ends with an unauthorized event when the token expires or is revoked. Browsers may pass the token as access_token.
+// @Tags stream
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param product_id query string false "Product IDs, comma-separated"
+// @Param category query string false "Product category"
+// @Param location query string false "Product location"
+// @Param warehouse_id query int false "Warehouse ID (stock events only)"
+// @Param last_event_id query int false "Resume after this event ID"
+// @Success 200 {string} string "text/event-stream"
+// @Failure 400 {object} ErrorResponse
+// @Router /stream/stock [get]
+func (h *StreamHandler) StreamStock(c *gin.Context) {
+	filter := streamFilter{
+		productIDs: map[uint]bool{},
+		category:   c.Query("category"),
+		location:   c.Query("location"),
+	}
+	if ids := c.Query("product_id"); ids != "" {
+		for _, s := range strings.Split(ids, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+				return
+			}
+			filter.productIDs[uint(id)] = true
+		}
+	}
+	if s := c.Query("warehouse_id"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid warehouse ID"})
+			return
+		}
+		filter.warehouseID = uint(id)
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var last int64
+	resume := lastEventID != ""
+	if resume {
+		var err error
+		last, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || last < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+	}
+
+	// Register before reading the backlog so nothing published in between is missed
+	client := h.addClient()
+	defer h.removeClient(client)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+	c.Writer.Flush()
+
+	send := func(m StreamMessage) {
+		if m.Offset <= last {
+			return // already sent from the backlog or delivered twice
+		}
+		last = m.Offset
+		if !filter.matches(m) {
+			return
+		}
+		data, _ := json.Marshal(m)
+		writeStreamEvent(c, strconv.FormatInt(m.Offset, 10), m.Type, data)
+	}
+
+	if resume {
+		for replayed := 0; ; {
+			if replayed >= streamReplayMax {
+				var head int64
+				h.db.Model(&models.OutboxEvent{}).Select("COALESCE(MAX(event_offset), 0)").Scan(&head)
+				last = head
+				writeStreamEvent(c, strconv.FormatInt(head, 10), "reset", []byte(`{"reason":"too far behind, reload current stock"}`))
+				break
+			}
+
+			var events []models.OutboxEvent
+			if err := h.db.Where("event_offset > ?", last).Order("event_offset").Limit(streamReplayBatch).Find(&events).Error; err != nil {
+				return
+			}
+			messages, err := streamMessages(h.db, events)
+			if err != nil {
+				return
+			}
+			for _, m := range messages {
+				send(m)
+			}
+			replayed += len(events)
+			if len(events) < streamReplayBatch {
+				break
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	recheck := time.NewTicker(streamAuthRecheck)
+	defer recheck.Stop()
+
+	// The stream ends with the credential it was opened with
+	var expired <-chan time.Time
+	if expiresAt, ok := c.Get("tokenExpiresAt"); ok {
+		timer := time.NewTimer(time.Until(expiresAt.(time.Time)))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-expired:
+			writeStreamEvent(c, "", "unauthorized", []byte(`{"reason":"token expired"}`))
+			return
+		case <-recheck.C:
+			if err := h.checkStreamCredential(c); err != nil {
+				writeStreamEvent(c, "", "unauthorized", []byte(`{"reason":"token revoked"}`))
+				return
+			}
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		case m, ok := <-client:
+			if !ok {
+				return // fell behind; the client reconnects with Last-Event-ID
+			}
+			send(m)
+		}
+	}
+}
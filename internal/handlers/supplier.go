@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+)
+
+// SupplierHandler holds dependencies for supplier handling
+type SupplierHandler struct {
+	db *gorm.DB
+}
+
+func NewSupplierHandler(db *gorm.DB) *SupplierHandler {
+	return &SupplierHandler{
+		db: db,
+	}
+}
+
+// CreateSupplierRequest holds the fields for creating or updating a supplier
+type CreateSupplierRequest struct {
+	Code         string `json:"code" binding:"required"`
+	Name         string `json:"name" binding:"required"`
+	ContactName  string `json:"contact_name"`
+	Email        string `json:"email" binding:"omitempty,email"`
+	Phone        string `json:"phone"`
+	Address      string `json:"address"`
+	LeadTimeDays int    `json:"lead_time_days" binding:"min=0"`
+}
+
+// CreateSupplier godoc
+// @Summary Create a supplier
+// @Description Create a new supplier to raise purchase orders against
+// @Tags suppliers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param supplier body CreateSupplierRequest true "Supplier details"
+// @Success 201 {object} models.Supplier
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /suppliers [post]
+func (h *SupplierHandler) CreateSupplier(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req CreateSupplierRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if code exists
+	var existing models.Supplier
+	if err := h.db.Where("code = ?", req.Code).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Supplier code already exists"})
+		return
+	}
+
+	supplier := models.Supplier{
+		Code:         req.Code,
+		Name:         req.Name,
+		ContactName:  req.ContactName,
+		Email:        req.Email,
+		Phone:        req.Phone,
+		Address:      req.Address,
+		LeadTimeDays: req.LeadTimeDays,
+		IsActive:     true,
+		CreatedBy:    userID.(uint),
+		UpdatedBy:    userID.(uint),
+	}
+
+	if err := h.db.Create(&supplier).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create supplier"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, supplier)
+}
+
+// GetSuppliers godoc
+// @Summary Get all suppliers
+// @Description Get a list of active suppliers
+// @Tags suppliers
+// @Produce json
+// @Security BearerAuth
+// @Param search query string false "Search by code or name"
+// @Success 200 {array} models.Supplier
+// @Router /suppliers [get]
+func (h *SupplierHandler) GetSuppliers(c *gin.Context) {
+	query := h.db.Where("is_active = ?", true)
+	if search := c.Query("search"); search != "" {
+		query = query.Where("code LIKE ? OR name LIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+
+	var suppliers []models.Supplier
+	query.Order("code").Find(&suppliers)
+
+	c.JSON(http.StatusOK, suppliers)
+}
+
+// GetSupplierByID godoc
+// @Summary Get a supplier by ID
+// @Description Get a supplier together with its open purchase orders
+// @Tags suppliers
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Supplier ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /suppliers/{id} [get]
+func (h *SupplierHandler) GetSupplierByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid supplier ID"})
+		return
+	}
+
+	var supplier models.Supplier
+	if err := h.db.First(&supplier, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+		return
+	}
+
+	var orders []models.PurchaseOrder
+	h.db.Preload("Lines").Where("supplier_id = ? AND status IN ?", id, []string{
+		models.PurchaseOrderStatusDraft,
+		models.PurchaseOrderStatusApproved,
+		models.PurchaseOrderStatusPartiallyReceived,
+	}).Order("created_at DESC").Find(&orders)
+
+	c.JSON(http.StatusOK, gin.H{
+		"supplier":        supplier,
+		"purchase_orders": orders,
+	})
+}
+
+// UpdateSupplier godoc
+// @Summary Update a supplier
+// @Description Update an existing supplier
+// @Tags suppliers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Supplier ID"
+// @Param supplier body CreateSupplierRequest true "Supplier details"
+// @Success 200 {object} MessageResponse "Supplier updated successfully"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /suppliers/{id} [put]
+func (h *SupplierHandler) UpdateSupplier(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid supplier ID"})
+		return
+	}
+
+	var req CreateSupplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var supplier models.Supplier
+	if err := h.db.First(&supplier, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+		return
+	}
+
+	supplier.Code = req.Code
+	supplier.Name = req.Name
+	supplier.ContactName = req.ContactName
+	supplier.Email = req.Email
+	supplier.Phone = req.Phone
+	supplier.Address = req.Address
+	supplier.LeadTimeDays = req.LeadTimeDays
+	supplier.UpdatedBy = userID.(uint)
+
+	if err := h.db.Save(&supplier).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update supplier"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Supplier updated successfully"})
+}
+
+// DeleteSupplier godoc
+// @Summary Delete a supplier
+// @Description Soft delete a supplier with no open purchase orders
+// @Tags suppliers
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Supplier ID"
+// @Success 200 {object} MessageResponse "Supplier deleted successfully"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /suppliers/{id} [delete]
+func (h *SupplierHandler) DeleteSupplier(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid supplier ID"})
+		return
+	}
+
+	var supplier models.Supplier
+	if err := h.db.First(&supplier, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+		return
+	}
+
+	var open int64
+	h.db.Model(&models.PurchaseOrder{}).Where("supplier_id = ? AND status IN ?", id, []string{
+		models.PurchaseOrderStatusApproved,
+		models.PurchaseOrderStatusPartiallyReceived,
+	}).Count(&open)
+	if open > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Supplier has open purchase orders"})
+		return
+	}
+
+	// Soft delete
+	if err := h.db.Model(&supplier).Update("is_active", false).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete supplier"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Supplier deleted successfully"})
+}
+
+// SupplierProductRequest holds the ordering rules for a product bought from a supplier
+type SupplierProductRequest struct {
+	ProductID   uint    `json:"product_id" binding:"required"`
+	SupplierSKU string  `json:"supplier_sku"`
+	UnitCost    float64 `json:"unit_cost" binding:"min=0"`     // optional, defaults to the product's cost price
+	PackSize    int     `json:"pack_size" binding:"min=0"`     // optional, defaults to 1
+	MinOrderQty int     `json:"min_order_qty" binding:"min=0"` // optional
+	IsPreferred bool    `json:"is_preferred"`
+}
+
+// GetSupplierProducts godoc
+// @Summary Get a supplier's products
+// @Description List the products a supplier sells with their pack size, minimum order quantity and cost
+// @Tags suppliers
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Supplier ID"
+// @Success 200 {array} models.SupplierProduct
+// @Failure 400 {object} ErrorResponse
+// @Router /suppliers/{id}/products [get]
+func (h *SupplierHandler) GetSupplierProducts(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid supplier ID"})
+		return
+	}
+
+	var products []models.SupplierProduct
+	h.db.Preload("Product").Where("supplier_id = ?", id).Order("product_id").Find(&products)
+
+	c.JSON(http.StatusOK, products)
+}
+
+// SetSupplierProduct godoc
+// @Summary Add or update a supplier's product
+// @Description Record that a supplier sells a product, or update its ordering rules. Marking it preferred clears the flag on the product's other suppliers.
+// @Tags suppliers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Supplier ID"
+// @Param product body SupplierProductRequest true "Supplier product details"
+// @Success 200 {object} models.SupplierProduct
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /suppliers/{id}/products [put]
+func (h *SupplierHandler) SetSupplierProduct(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid supplier ID"})
+		return
+	}
+
+	var req SupplierProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.PackSize == 0 {
+		req.PackSize = 1
+	}
+
+	var item models.SupplierProduct
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var supplier models.Supplier
+		if err := tx.Where("id = ? AND is_active = ?", id, true).First(&supplier).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errSupplierNotFound
+			}
+			return err
+		}
+		var product models.Product
+		if err := tx.Where("id = ? AND is_active = ?", req.ProductID, true).First(&product).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errProductNotFound
+			}
+			return err
+		}
+
+		err := tx.Where("supplier_id = ? AND product_id = ?", supplier.ID, product.ID).First(&item).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if item.ID == 0 {
+			item = models.SupplierProduct{
+				SupplierID: supplier.ID,
+				ProductID:  product.ID,
+				CreatedBy:  userID.(uint),
+			}
+		}
+		item.SupplierSKU = req.SupplierSKU
+		item.UnitCost = req.UnitCost
+		item.PackSize = req.PackSize
+		item.MinOrderQty = req.MinOrderQty
+		item.IsPreferred = req.IsPreferred
+		item.UpdatedBy = userID.(uint)
+
+		// A product has at most one preferred supplier
+		if item.IsPreferred {
+			if err := tx.Model(&models.SupplierProduct{}).
+				Where("product_id = ? AND supplier_id <> ?", product.ID, supplier.ID).
+				Update("is_preferred", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&item).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// DeleteSupplierProduct godoc
+// @Summary Remove a supplier's product
+// @Description Stop offering a product from a supplier in replenishment suggestions
+// @Tags suppliers
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Supplier ID"
+// @Param product_id path int true "Product ID"
+// @Success 200 {object} MessageResponse "Supplier product removed successfully"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /suppliers/{id}/products/{product_id} [delete]
+func (h *SupplierHandler) DeleteSupplierProduct(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid supplier ID"})
+		return
+	}
+	productID, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	result := h.db.Where("supplier_id = ? AND product_id = ?", id, productID).Delete(&models.SupplierProduct{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove supplier product"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier product not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Supplier product removed successfully"})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/testutil"
+	"gorm.io/gorm"
+)
+
+// openTestDB connects to the shared test database, see testutil.OpenDB
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return testutil.OpenDB(t)
+}
+
+// uniqueName returns a name no other test row uses
+func uniqueName(prefix string) string {
+	return testutil.UniqueName(prefix)
+}
+
+func createTestUser(t *testing.T, db *gorm.DB, role string) models.User {
+	t.Helper()
+	user := models.User{
+		Email:    uniqueName("user") + "@example.com",
+		Name:     "Test " + role,
+		Role:     role,
+		IsActive: true,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func createTestProduct(t *testing.T, db *gorm.DB, userID uint) models.Product {
+	t.Helper()
+	product := models.Product{
+		SKU:       uniqueName("SKU"),
+		Name:      "Test product",
+		UnitPrice: 10,
+		CostPrice: 5,
+		IsActive:  true,
+		CreatedBy: userID,
+		UpdatedBy: userID,
+	}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	return product
+}
+
+// postTestMovement posts a single movement in its own transaction
+func postTestMovement(db *gorm.DB, in stockMovementInput) (*models.Stock, error) {
+	var movement *models.Stock
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		movement, err = postStockMovement(tx, LedgerSettings{}, in)
+		return err
+	})
+	return movement, err
+}
@@ -0,0 +1,512 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const errTransferNotFound = notFoundError("Transfer not found")
+
+// TransferHandler holds dependencies for inter-warehouse transfers
+type TransferHandler struct {
+	db     *gorm.DB
+	cache  *redis.Client
+	ledger LedgerSettings
+}
+
+func NewTransferHandler(db *gorm.DB, cache *redis.Client, ledger LedgerSettings) *TransferHandler {
+	return &TransferHandler{
+		db:     db,
+		cache:  cache,
+		ledger: ledger,
+	}
+}
+
+// TransferLineRequest is one product leaving the source warehouse
+type TransferLineRequest struct {
+	ProductID uint     `json:"product_id" binding:"required"`
+	Quantity  int      `json:"quantity" binding:"required,gt=0"`
+	LotNumber string   `json:"lot_number"` // optional, ships this lot instead of FEFO
+	Serials   []string `json:"serials"`    // required for serial-tracked products
+}
+
+// CreateTransferRequest ships stock from one warehouse to another
+type CreateTransferRequest struct {
+	FromWarehouseID uint                  `json:"from_warehouse_id"` // optional, defaults to the default warehouse
+	ToWarehouseID   uint                  `json:"to_warehouse_id" binding:"required"`
+	Lines           []TransferLineRequest `json:"lines" binding:"required,min=1,dive"`
+	Notes           string                `json:"notes"`
+}
+
+// ReceiveLineRequest is the quantity of a transfer line arriving at the destination
+type ReceiveLineRequest struct {
+	LineID   uint     `json:"line_id" binding:"required"`
+	Quantity int      `json:"quantity" binding:"required,gt=0"`
+	Serials  []string `json:"serials"` // optional, defaults to the serials still in transit
+}
+
+// ReceiveTransferRequest records a (possibly partial) receipt
+type ReceiveTransferRequest struct {
+	Lines []ReceiveLineRequest `json:"lines" binding:"required,min=1,dive"`
+	Notes string               `json:"notes"`
+}
+
+// CloseTransferRequest closes a transfer, writing off anything still in transit
+type CloseTransferRequest struct {
+	Notes string `json:"notes"`
+}
+
+// shipTransfer creates the transfer document and posts a TRANSFER_OUT for each
+// line at the source warehouse
+func shipTransfer(tx *gorm.DB, ledger LedgerSettings, fromID, toID uint, lines []TransferLineRequest, notes string, userID uint) (*models.StockTransfer, error) {
+	from, err := resolveWarehouse(tx, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := resolveWarehouse(tx, toID)
+	if err != nil {
+		return nil, err
+	}
+	if from.ID == to.ID {
+		return nil, validationError("Source and destination warehouse must differ")
+	}
+
+	// Lock products in a stable order so concurrent transfers cannot deadlock
+	lines = append([]TransferLineRequest(nil), lines...)
+	sort.Slice(lines, func(i, j int) bool { return lines[i].ProductID < lines[j].ProductID })
+
+	transfer := models.StockTransfer{
+		FromWarehouseID: from.ID,
+		ToWarehouseID:   to.ID,
+		Status:          models.TransferStatusInTransit,
+		Notes:           notes,
+		ShippedAt:       time.Now(),
+		CreatedBy:       userID,
+		UpdatedBy:       userID,
+	}
+	if err := tx.Create(&transfer).Error; err != nil {
+		return nil, err
+	}
+
+	transfer.TransferNo = fmt.Sprintf("TRF-%06d", transfer.ID)
+	if err := tx.Model(&transfer).Update("transfer_no", transfer.TransferNo).Error; err != nil {
+		return nil, err
+	}
+
+	for _, l := range lines {
+		line := models.StockTransferLine{
+			TransferID:      transfer.ID,
+			ProductID:       l.ProductID,
+			QuantityShipped: l.Quantity,
+		}
+		if err := tx.Create(&line).Error; err != nil {
+			return nil, err
+		}
+
+		movement, err := postStockMovement(tx, ledger, stockMovementInput{
+			ProductID:   l.ProductID,
+			WarehouseID: from.ID,
+			Type:        "TRANSFER_OUT",
+			Quantity:    l.Quantity,
+			Reference:   transfer.TransferNo,
+			TransferID:  &transfer.ID,
+			Notes:       notes,
+			UserID:      userID,
+			LotNumber:   l.LotNumber,
+			Serials:     l.Serials,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// The receipt is valued at the cost it left the source warehouse at
+		line.UnitCost = movement.UnitCost
+		if err := tx.Model(&line).Update("unit_cost", line.UnitCost).Error; err != nil {
+			return nil, err
+		}
+
+		transfer.Lines = append(transfer.Lines, line)
+	}
+
+	return &transfer, nil
+}
+
+// loadTransfer locks a transfer and reads its lines (ordered by product) inside tx
+func loadTransfer(tx *gorm.DB, id int) (*models.StockTransfer, error) {
+	var transfer models.StockTransfer
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("product_id, id") }).
+		First(&transfer, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errTransferNotFound
+		}
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// refreshTransferStatus sets RECEIVED once nothing is left in transit
+func refreshTransferStatus(transfer *models.StockTransfer) {
+	inTransit := 0
+	discrepancy := 0
+	for _, l := range transfer.Lines {
+		inTransit += l.InTransit()
+		discrepancy += l.QuantityDiscrepancy
+	}
+
+	switch {
+	case inTransit > 0:
+		transfer.Status = models.TransferStatusPartiallyReceived
+	case discrepancy > 0:
+		transfer.Status = models.TransferStatusClosed
+	default:
+		transfer.Status = models.TransferStatusReceived
+	}
+
+	if inTransit == 0 {
+		now := time.Now()
+		transfer.ReceivedAt = &now
+	}
+}
+
+// CreateTransfer godoc
+// @Summary Ship a stock transfer
+// @Description Create a transfer and ship it from the source warehouse; stock stays in transit until received
+// @Tags transfers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param transfer body CreateTransferRequest true "Transfer details"
+// @Success 201 {object} models.StockTransfer
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /transfers [post]
+func (h *TransferHandler) CreateTransfer(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req CreateTransferRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var transfer *models.StockTransfer
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		transfer, err = shipTransfer(tx, h.ledger, req.FromWarehouseID, req.ToWarehouseID, req.Lines, req.Notes, userID.(uint))
+		return err
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	productIDs := make([]uint, 0, len(req.Lines))
+	for _, l := range req.Lines {
+		productIDs = append(productIDs, l.ProductID)
+	}
+	invalidateStockCaches(h.cache, c.Request.Context(), productIDs...)
+
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// GetTransfers godoc
+// @Summary Get stock transfers
+// @Description List transfers, optionally filtered by status or warehouse
+// @Tags transfers
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Param status query string false "IN_TRANSIT, PARTIALLY_RECEIVED, RECEIVED or CLOSED"
+// @Param warehouse_id query int false "Source or destination warehouse"
+// @Success 200 {object} map[string]interface{}
+// @Router /transfers [get]
+func (h *TransferHandler) GetTransfers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	status := c.Query("status")
+	warehouseID := c.Query("warehouse_id")
+	offset := (page - 1) * limit
+
+	query := h.db.Model(&models.StockTransfer{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if warehouseID != "" {
+		query = query.Where("from_warehouse_id = ? OR to_warehouse_id = ?", warehouseID, warehouseID)
+	}
+
+	var total int64
+	var transfers []models.StockTransfer
+	query.Count(&total)
+	query.Preload("Lines").Offset(offset).Limit(limit).Order("created_at DESC").Find(&transfers)
+
+	c.JSON(http.StatusOK, gin.H{
+		"transfers": transfers,
+		"total":     total,
+		"page":      page,
+		"limit":     limit,
+	})
+}
+
+// GetTransferByID godoc
+// @Summary Get a stock transfer
+// @Description Get a transfer with its lines and the ledger rows written for both legs
+// @Tags transfers
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Transfer ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /transfers/{id} [get]
+func (h *TransferHandler) GetTransferByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+		return
+	}
+
+	var transfer models.StockTransfer
+	if err := h.db.Preload("Lines.Product").Preload("FromWarehouse").Preload("ToWarehouse").
+		First(&transfer, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		return
+	}
+
+	var movements []models.Stock
+	h.db.Preload("Lots.Lot").Preload("Serials.Serial").Where("transfer_id = ?", transfer.ID).Order("created_at").Find(&movements)
+
+	c.JSON(http.StatusOK, gin.H{
+		"transfer":  transfer,
+		"movements": movements,
+	})
+}
+
+// ReceiveTransfer godoc
+// @Summary Receive a stock transfer
+// @Description Receive some or all of the in-transit quantity at the destination warehouse
+// @Tags transfers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Transfer ID"
+// @Param receipt body ReceiveTransferRequest true "Received quantities"
+// @Success 200 {object} models.StockTransfer
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /transfers/{id}/receive [post]
+func (h *TransferHandler) ReceiveTransfer(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+		return
+	}
+
+	var req ReceiveTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var transfer *models.StockTransfer
+	var productIDs []uint
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		transfer, err = loadTransfer(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if transfer.Status != models.TransferStatusInTransit && transfer.Status != models.TransferStatusPartiallyReceived {
+			return validationError("Transfer is not in transit")
+		}
+
+		received := make(map[uint]int, len(req.Lines))
+		receivedSerials := make(map[uint][]string, len(req.Lines))
+		for _, r := range req.Lines {
+			received[r.LineID] += r.Quantity
+			receivedSerials[r.LineID] = append(receivedSerials[r.LineID], r.Serials...)
+		}
+
+		// Walk the lines in product order so locks are taken in a stable order
+		for i := range transfer.Lines {
+			line := &transfer.Lines[i]
+			qty, ok := received[line.ID]
+			if !ok {
+				continue
+			}
+			delete(received, line.ID)
+
+			if qty > line.InTransit() {
+				return validationError(fmt.Sprintf("Line %d has only %d in transit", line.ID, line.InTransit()))
+			}
+
+			// Received stock lands in the same lots it was shipped from
+			lots, err := transferLotsInTransit(tx, transfer.ID, line.ProductID, qty)
+			if err != nil {
+				return err
+			}
+
+			if _, err := postStockMovement(tx, h.ledger, stockMovementInput{
+				ProductID:   line.ProductID,
+				WarehouseID: transfer.ToWarehouseID,
+				Type:        "TRANSFER_IN",
+				Quantity:    qty,
+				Reference:   transfer.TransferNo,
+				UnitCost:    line.UnitCost,
+				TransferID:  &transfer.ID,
+				Notes:       req.Notes,
+				UserID:      userID.(uint),
+				Lots:        lots,
+				Serials:     receivedSerials[line.ID],
+			}); err != nil {
+				return err
+			}
+
+			line.QuantityReceived += qty
+			if err := tx.Save(line).Error; err != nil {
+				return err
+			}
+			productIDs = append(productIDs, line.ProductID)
+		}
+
+		for lineID := range received {
+			return validationError(fmt.Sprintf("Line %d does not belong to this transfer", lineID))
+		}
+
+		refreshTransferStatus(transfer)
+		transfer.UpdatedBy = userID.(uint)
+		return tx.Omit("Lines").Save(transfer).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	invalidateStockCaches(h.cache, c.Request.Context(), productIDs...)
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// CloseTransfer godoc
+// @Summary Close a stock transfer
+// @Description Close a transfer, writing anything still in transit off as a discrepancy with a TRANSIT_LOSS movement (reason LOST_IN_TRANSIT)
+// @Tags transfers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Transfer ID"
+// @Param close body CloseTransferRequest false "Closing notes"
+// @Success 200 {object} models.StockTransfer
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /transfers/{id}/close [post]
+func (h *TransferHandler) CloseTransfer(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+		return
+	}
+
+	var req CloseTransferRequest
+	_ = c.ShouldBindJSON(&req)
+
+	var transfer *models.StockTransfer
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		transfer, err = loadTransfer(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if transfer.Status != models.TransferStatusInTransit && transfer.Status != models.TransferStatusPartiallyReceived {
+			return validationError("Transfer is not in transit")
+		}
+
+		// Whatever never arrived is written off as a discrepancy
+		for i := range transfer.Lines {
+			line := &transfer.Lines[i]
+			lost := line.InTransit()
+			if lost == 0 {
+				continue
+			}
+			// Lost stock leaves the books at its FIFO cost
+			var product models.Product
+			if err := tx.First(&product, line.ProductID).Error; err != nil {
+				return err
+			}
+			unitCost, err := issueUnitCost(tx, product, lost)
+			if err != nil {
+				return err
+			}
+
+			// and is booked in the ledger against the source warehouse, in the
+			// lots and serials it was shipped with
+			lots, err := transferLotsInTransit(tx, transfer.ID, line.ProductID, lost)
+			if err != nil {
+				return err
+			}
+			var serials []string
+			if product.TrackingMode == models.TrackingModeSerial {
+				if serials, err = transferSerialsInTransit(tx, transfer.ID, line.ProductID, lost); err != nil {
+					return err
+				}
+			}
+			if _, err := postStockMovement(tx, h.ledger, stockMovementInput{
+				ProductID:   line.ProductID,
+				WarehouseID: transfer.FromWarehouseID,
+				Type:        "TRANSIT_LOSS",
+				Quantity:    lost,
+				Reference:   transfer.TransferNo,
+				ReasonCode:  "LOST_IN_TRANSIT",
+				TransferID:  &transfer.ID,
+				UnitCost:    unitCost,
+				Notes:       req.Notes,
+				UserID:      userID.(uint),
+				Lots:        lots,
+				Serials:     serials,
+			}); err != nil {
+				return err
+			}
+
+			line.QuantityDiscrepancy += lost
+			if err := tx.Save(line).Error; err != nil {
+				return err
+			}
+		}
+
+		refreshTransferStatus(transfer)
+		if req.Notes != "" {
+			transfer.Notes = req.Notes
+		}
+		transfer.UpdatedBy = userID.(uint)
+		return tx.Omit("Lines").Save(transfer).Error
+	})
+	if err != nil {
+		respondStockError(c, err)
+		return
+	}
+
+	// In-transit totals are part of the stock list
+	invalidateStockCaches(h.cache, c.Request.Context())
+
+	c.JSON(http.StatusOK, transfer)
+}
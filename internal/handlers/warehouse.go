@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"gorm.io/gorm"
+)
+
+// WarehouseHandler holds dependencies for warehouse handling
+type WarehouseHandler struct {
+	db *gorm.DB
+}
+
+func NewWarehouseHandler(db *gorm.DB) *WarehouseHandler {
+	return &WarehouseHandler{
+		db: db,
+	}
+}
+
+// CreateWarehouseRequest holds the fields for creating or updating a warehouse
+type CreateWarehouseRequest struct {
+	Code      string `json:"code" binding:"required"`
+	Name      string `json:"name" binding:"required"`
+	Address   string `json:"address"`
+	IsDefault bool   `json:"is_default"`
+}
+
+// CreateWarehouse godoc
+// @Summary Create a warehouse
+// @Description Create a new stock location
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param warehouse body CreateWarehouseRequest true "Warehouse details"
+// @Success 201 {object} models.Warehouse
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /warehouses [post]
+func (h *WarehouseHandler) CreateWarehouse(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req CreateWarehouseRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if code exists
+	var existing models.Warehouse
+	if err := h.db.Where("code = ?", req.Code).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Warehouse code already exists"})
+		return
+	}
+
+	warehouse := models.Warehouse{
+		Code:      req.Code,
+		Name:      req.Name,
+		Address:   req.Address,
+		IsDefault: req.IsDefault,
+		IsActive:  true,
+		CreatedBy: userID.(uint),
+		UpdatedBy: userID.(uint),
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Only one warehouse can be the default
+		if req.IsDefault {
+			if err := tx.Model(&models.Warehouse{}).Where("is_default = ?", true).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&warehouse).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create warehouse"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, warehouse)
+}
+
+// GetWarehouses godoc
+// @Summary Get all warehouses
+// @Description Get a list of active warehouses
+// @Tags warehouses
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Warehouse
+// @Router /warehouses [get]
+func (h *WarehouseHandler) GetWarehouses(c *gin.Context) {
+	var warehouses []models.Warehouse
+	h.db.Where("is_active = ?", true).Order("code").Find(&warehouses)
+
+	c.JSON(http.StatusOK, warehouses)
+}
+
+// GetWarehouseByID godoc
+// @Summary Get a warehouse by ID
+// @Description Get a warehouse together with the balances it holds
+// @Tags warehouses
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Warehouse ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /warehouses/{id} [get]
+func (h *WarehouseHandler) GetWarehouseByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid warehouse ID"})
+		return
+	}
+
+	var warehouse models.Warehouse
+	if err := h.db.First(&warehouse, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warehouse not found"})
+		return
+	}
+
+	var balances []models.StockBalance
+	h.db.Preload("Product").Where("warehouse_id = ? AND quantity <> 0", id).
+		Order("product_id").Find(&balances)
+
+	c.JSON(http.StatusOK, gin.H{
+		"warehouse": warehouse,
+		"balances":  balances,
+	})
+}
+
+// UpdateWarehouse godoc
+// @Summary Update a warehouse
+// @Description Update an existing warehouse
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Warehouse ID"
+// @Param warehouse body CreateWarehouseRequest true "Warehouse details"
+// @Success 200 {object} MessageResponse "Warehouse updated successfully"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /warehouses/{id} [put]
+func (h *WarehouseHandler) UpdateWarehouse(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid warehouse ID"})
+		return
+	}
+
+	var req CreateWarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var warehouse models.Warehouse
+	if err := h.db.First(&warehouse, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warehouse not found"})
+		return
+	}
+
+	warehouse.Code = req.Code
+	warehouse.Name = req.Name
+	warehouse.Address = req.Address
+	warehouse.UpdatedBy = userID.(uint)
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if req.IsDefault && !warehouse.IsDefault {
+			if err := tx.Model(&models.Warehouse{}).Where("is_default = ?", true).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+			warehouse.IsDefault = true
+		}
+		return tx.Save(&warehouse).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update warehouse"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Warehouse updated successfully"})
+}
+
+// DeleteWarehouse godoc
+// @Summary Delete a warehouse
+// @Description Soft delete an empty, non-default warehouse
+// @Tags warehouses
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Warehouse ID"
+// @Success 200 {object} MessageResponse "Warehouse deleted successfully"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /warehouses/{id} [delete]
+func (h *WarehouseHandler) DeleteWarehouse(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid warehouse ID"})
+		return
+	}
+
+	var warehouse models.Warehouse
+	if err := h.db.First(&warehouse, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Warehouse not found"})
+		return
+	}
+
+	if warehouse.IsDefault {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot delete the default warehouse"})
+		return
+	}
+
+	// Stock must be moved out before the location is closed
+	var held int64
+	h.db.Model(&models.StockBalance{}).Where("warehouse_id = ? AND quantity <> 0", id).Count(&held)
+	if held > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Warehouse still holds stock"})
+		return
+	}
+
+	// Soft delete
+	warehouse.IsActive = false
+	if err := h.db.Save(&warehouse).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete warehouse"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Warehouse deleted successfully"})
+}
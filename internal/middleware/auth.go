@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -9,23 +10,37 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/apikey"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/cache"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
+const apiKeyUsageInterval = time.Minute // least time between last-used updates of an API key
+
 type AuthMiddleware struct {
 	jwtSecret string
 	db        *gorm.DB
+	cache     *redis.Client
 }
 
-func NewAuthMiddleware(jwtSecret string, db *gorm.DB) *AuthMiddleware {
+func NewAuthMiddleware(jwtSecret string, db *gorm.DB, cache *redis.Client) *AuthMiddleware {
 	return &AuthMiddleware{
 		jwtSecret: jwtSecret,
 		db:        db,
+		cache:     cache,
 	}
 }
 
+// ValidateJWT authenticates the request with a Bearer JWT, or with a service
+// account API key sent as X-API-Key or as the Bearer token
 func (m *AuthMiddleware) ValidateJWT() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := c.GetHeader("X-API-Key"); key != "" {
+			m.validateAPIKey(c, key)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 
 		// มี Header มาไหม? (Authorization)
@@ -43,8 +58,13 @@ func (m *AuthMiddleware) ValidateJWT() gin.HandlerFunc {
 			return
 		}
 
-		// Token ปลอมหรือเปล่า? (Parse)
 		tokenString := parts[1]
+		if apikey.IsKey(tokenString) {
+			m.validateAPIKey(c, tokenString)
+			return
+		}
+
+		// Token ปลอมหรือเปล่า? (Parse)
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -74,6 +94,26 @@ func (m *AuthMiddleware) ValidateJWT() gin.HandlerFunc {
 			}
 		}
 
+		// Token หรือ Session ถูกเพิกถอน (Logout) ไปแล้วหรือยัง?
+		jti, _ := claims["jti"].(string)
+		sid, _ := claims["sid"].(string)
+		if jti != "" || sid != "" {
+			revoked, err := m.cache.MGet(c.Request.Context(),
+				cache.GenerateRevokedTokenKey(jti), cache.GenerateRevokedSessionKey(sid)).Result()
+			if err != nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to check token revocation"})
+				c.Abort()
+				return
+			}
+			for _, v := range revoked {
+				if v != nil {
+					c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+					c.Abort()
+					return
+				}
+			}
+		}
+
 		// 5. User ยังมีตัวตนอยู่จริงไหม?
 		var user models.User
 		userID := uint(claims["user_id"].(float64)) // แปลง ID จาก Token
@@ -87,6 +127,155 @@ func (m *AuthMiddleware) ValidateJWT() gin.HandlerFunc {
 		// 6. ผ่านหมด! เก็บข้อมูลลง Context
 		c.Set("userID", user.ID)
 		c.Set("userRole", user.Role) // เผื่อเอาไปเช็คสิทธิ์ต่อ
+		c.Set("tokenID", jti)
+		c.Set("sessionID", sid)
+		if exp, ok := claims["exp"].(float64); ok {
+			c.Set("tokenExpiresAt", time.Unix(int64(exp), 0))
+		}
 		c.Next()
 	}
 }
+
+// validateAPIKey authenticates a service account by one of its API keys. The
+// request gets the key's scopes that the account's role still grants.
+func (m *AuthMiddleware) validateAPIKey(c *gin.Context, rawKey string) {
+	var key models.APIKey
+	if err := m.db.Where("key_hash = ?", apikey.Hash(rawKey)).First(&key).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		c.Abort()
+		return
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key revoked"})
+		c.Abort()
+		return
+	}
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key expired"})
+		c.Abort()
+		return
+	}
+
+	var user models.User
+	if err := m.db.First(&user, key.UserID).Error; err != nil || !user.IsService || !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Service account disabled"})
+		c.Abort()
+		return
+	}
+
+	c.Set("userID", user.ID)
+	c.Set("userRole", user.Role)
+	c.Set("apiKeyID", key.ID)
+	if key.ExpiresAt != nil {
+		c.Set("tokenExpiresAt", *key.ExpiresAt)
+	}
+
+	granted, err := m.rolePermissions(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+		c.Abort()
+		return
+	}
+	scoped := map[string]bool{}
+	for _, p := range strings.Split(key.Scopes, ",") {
+		if granted[p] {
+			scoped[p] = true
+		}
+	}
+	c.Set("permissions", scoped)
+
+	// Last use only needs to be roughly right, skip the write on busy keys
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyUsageInterval {
+		if err := m.db.Model(&models.APIKey{}).Where("id = ?", key.ID).
+			UpdateColumns(map[string]interface{}{"last_used_at": now, "last_used_ip": c.ClientIP()}).Error; err != nil {
+			log.Printf("Warning: Failed to record use of API key %d: %v", key.ID, err)
+		}
+	}
+
+	c.Next()
+}
+
+// queryTokenKey is where HideQueryToken keeps the access_token parameter
+const queryTokenKey = "queryToken"
+
+// HideQueryToken moves the access_token query parameter out of the request URL
+// and into the context, so the request logger and anything else that records
+// URLs never see the credential. It must be registered before the logger.
+func HideQueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.Contains(c.Request.URL.RawQuery, "access_token") {
+			query := c.Request.URL.Query()
+			if token := query.Get("access_token"); token != "" {
+				c.Set(queryTokenKey, token)
+			}
+			query.Del("access_token")
+			c.Request.URL.RawQuery = query.Encode()
+		}
+		c.Next()
+	}
+}
+
+// TokenFromQuery lets clients that cannot set headers, such as the browser
+// EventSource, pass the JWT as the access_token query parameter, as set aside
+// by HideQueryToken. It must run before ValidateJWT and is only meant for
+// streaming routes.
+func (m *AuthMiddleware) TokenFromQuery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token := c.GetString(queryTokenKey); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission allows the request through only when the user's role has
+// all of the given permissions. A request made with an API key has only the
+// key's scopes that its service account's role still grants. The granted
+// permissions are stored in the context as "permissions" so handlers can
+// check finer-grained ones.
+func (m *AuthMiddleware) RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted, err := m.rolePermissions(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+			c.Abort()
+			return
+		}
+
+		for _, p := range permissions {
+			if !granted[p] {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions", "required": p})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// rolePermissions loads the permissions of the role ValidateJWT stored, once per request
+func (m *AuthMiddleware) rolePermissions(c *gin.Context) (map[string]bool, error) {
+	if v, ok := c.Get("permissions"); ok {
+		return v.(map[string]bool), nil
+	}
+
+	role, _ := c.Get("userRole")
+	var names []string
+	if err := m.db.Table("role_permissions").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.name = ?", role).
+		Pluck("role_permissions.permission", &names).Error; err != nil {
+		return nil, err
+	}
+
+	granted := make(map[string]bool, len(names))
+	for _, name := range names {
+		granted[name] = true
+	}
+	c.Set("permissions", granted)
+	return granted, nil
+}
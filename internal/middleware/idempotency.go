@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+const IdempotencyHeader = "Idempotency-Key"
+
+// idempotencyPendingTTL bounds how long a key stays locked by a request that
+// never finished, e.g. because the server crashed while handling it
+const idempotencyPendingTTL = time.Minute
+
+// idempotencyRecord is what gets stored in Redis for each key
+type idempotencyRecord struct {
+	RequestHash string `json:"request_hash"`
+	Completed   bool   `json:"completed"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        string `json:"body"`
+}
+
+type IdempotencyMiddleware struct {
+	cache *redis.Client
+	ttl   time.Duration
+}
+
+func NewIdempotencyMiddleware(cache *redis.Client) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		cache: cache,
+		ttl:   24 * time.Hour,
+	}
+}
+
+// responseRecorder keeps a copy of everything the handler writes
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// Handle replays the stored response when a request is retried with the same
+// Idempotency-Key, and rejects reuse of a key with a different body.
+// Must run after ValidateJWT so keys are scoped per user.
+func (m *IdempotencyMiddleware) Handle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+
+		// ไม่มี Header ก็ทำงานตามปกติ
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.FullPath()+"\n"), body...))
+		requestHash := hex.EncodeToString(sum[:])
+
+		userID, _ := c.Get("userID")
+		uid, _ := userID.(uint)
+		cacheKey := cache.GenerateIdempotencyKey(uid, c.FullPath(), key)
+		ctx := c.Request.Context()
+
+		// จองคีย์ก่อน ถ้าจองไม่ได้แปลว่าเคยมี Request นี้มาแล้ว
+		pending, _ := json.Marshal(idempotencyRecord{RequestHash: requestHash})
+		ok, err := m.cache.SetNX(ctx, cacheKey, pending, idempotencyPendingTTL).Result()
+		if err != nil {
+			// Redis ใช้ไม่ได้ ก็ยังให้ Request ผ่านไปได้
+			c.Next()
+			return
+		}
+
+		if !ok {
+			var record idempotencyRecord
+			if err := cache.GetCached(m.cache, ctx, cacheKey, &record); err != nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "Request with this Idempotency-Key is in progress"})
+				c.Abort()
+				return
+			}
+
+			if record.RequestHash != requestHash {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+				c.Abort()
+				return
+			}
+
+			if !record.Completed {
+				c.JSON(http.StatusConflict, gin.H{"error": "Request with this Idempotency-Key is in progress"})
+				c.Abort()
+				return
+			}
+
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.Status, record.ContentType, []byte(record.Body))
+			c.Abort()
+			return
+		}
+
+		// A panicking handler stored nothing either, release the key for retries
+		defer func() {
+			if r := recover(); r != nil {
+				m.cache.Del(context.WithoutCancel(ctx), cacheKey)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+		c.Next()
+
+		// Server errors are not stored so the client can retry with the same key.
+		// The client may have gone away by now, which must not lose the result.
+		ctx = context.WithoutCancel(ctx)
+		if recorder.Status() >= http.StatusInternalServerError {
+			m.cache.Del(ctx, cacheKey)
+			return
+		}
+
+		// The completed response is kept for the full TTL
+		cache.SetCached(m.cache, ctx, cacheKey, idempotencyRecord{
+			RequestHash: requestHash,
+			Completed:   true,
+			Status:      recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.String(),
+		}, m.ttl)
+	}
+}
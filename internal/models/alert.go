@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	AlertKindLowStock   = "LOW_STOCK"    // quantity at or below min_quantity
+	AlertKindOutOfStock = "OUT_OF_STOCK" // quantity at or below zero
+	AlertKindOverstock  = "OVERSTOCK"    // quantity above max_quantity
+)
+
+const (
+	AlertStatusOpen     = "OPEN"
+	AlertStatusResolved = "RESOLVED"
+)
+
+const (
+	AlertChannelWebhook = "WEBHOOK"
+	AlertChannelEmail   = "EMAIL"
+)
+
+const (
+	AlertDeliveryPending = "PENDING"
+	AlertDeliverySent    = "SENT"
+	AlertDeliveryFailed  = "FAILED"
+)
+
+// Alert is a stock threshold a product has crossed. A product has at most one
+// open alert of each kind; it is resolved when the quantity moves back, and
+// re-opened without notifying again if the threshold is crossed within the
+// cooldown.
+type Alert struct {
+	ID              uint            `gorm:"primaryKey" json:"id"`
+	ProductID       uint            `gorm:"not null;index" json:"product_id"`
+	Kind            string          `gorm:"not null" json:"kind"`         // LOW_STOCK, OUT_OF_STOCK, OVERSTOCK
+	Status          string          `gorm:"not null;index" json:"status"` // OPEN, RESOLVED
+	Quantity        int             `json:"quantity"`                     // Product.Quantity when last triggered
+	Threshold       int             `json:"threshold"`
+	MovementID      *uint           `json:"movement_id"` // movement that last triggered it
+	Occurrences     int             `gorm:"not null;default:1" json:"occurrences"`
+	LastTriggeredAt time.Time       `json:"last_triggered_at"`
+	ResolvedAt      *time.Time      `json:"resolved_at"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	Product         *Product        `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Deliveries      []AlertDelivery `gorm:"foreignKey:AlertID" json:"deliveries,omitempty"`
+}
+
+// AlertChannel is a destination alerts are delivered to
+type AlertChannel struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Type      string    `gorm:"not null" json:"type"`   // WEBHOOK, EMAIL
+	Target    string    `gorm:"not null" json:"target"` // webhook URL, or comma-separated email addresses
+	Secret    string    `json:"-"`                      // webhook signing key
+	Kinds     string    `json:"kinds"`                  // comma-separated alert kinds, empty for all
+	IsActive  bool      `gorm:"default:true" json:"is_active"`
+	CreatedBy uint      `json:"created_by"`
+	UpdatedBy uint      `json:"updated_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AlertDelivery is one alert sent to one channel, retried with backoff until
+// it succeeds or runs out of attempts
+type AlertDelivery struct {
+	ID            uint          `gorm:"primaryKey" json:"id"`
+	AlertID       uint          `gorm:"not null;index" json:"alert_id"`
+	ChannelID     uint          `gorm:"not null" json:"channel_id"`
+	Status        string        `gorm:"not null" json:"status"` // PENDING, SENT, FAILED
+	Attempts      int           `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time     `json:"next_attempt_at"`
+	LastError     string        `json:"last_error"`
+	DeliveredAt   *time.Time    `json:"delivered_at"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	Channel       *AlertChannel `gorm:"foreignKey:ChannelID" json:"channel,omitempty"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// APIKey lets a service account call the API without logging in. Only the
+// hash of the key is stored; PublicID is the part of the key shown in lists.
+// A request made with a key gets the permissions in Scopes that the service
+// account's role also has.
+type APIKey struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"` // the service account
+	Name        string     `gorm:"not null" json:"name"`
+	PublicID    string     `gorm:"uniqueIndex;not null" json:"public_id"`
+	KeyHash     string     `gorm:"uniqueIndex;not null" json:"-"`
+	Scopes      string     `json:"scopes"` // comma-separated permissions
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	LastUsedIP  string     `json:"last_used_ip"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	RotatedFrom *uint      `json:"rotated_from,omitempty"` // key this one replaced
+	CreatedBy   uint       `json:"created_by"`
+	UpdatedBy   uint       `json:"updated_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
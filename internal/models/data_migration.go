@@ -0,0 +1,12 @@
+package models
+
+import (
+	"time"
+)
+
+// DataMigration records a one-time data change that has been applied, so it
+// is not applied again on the next start
+type DataMigration struct {
+	Name      string    `gorm:"primaryKey" json:"name"` // prefixed with the date it was written, e.g. 20261017_...
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventProductCreated        = "ProductCreated"
+	EventProductUpdated        = "ProductUpdated"
+	EventProductDeleted        = "ProductDeleted"
+	EventStockMoved            = "StockMoved"
+	EventStockMovementPending  = "StockMovementPending"
+	EventStockMovementRejected = "StockMovementRejected"
+)
+
+const (
+	SubscriptionTypeWebhook  = "WEBHOOK"  // events are posted to a URL
+	SubscriptionTypeInternal = "INTERNAL" // events are handled inside the app, e.g. cache invalidation
+)
+
+// OutboxEvent is a domain event written in the same transaction as the change
+// it describes. The dispatcher publishes events in commit order by giving them
+// an Offset; consumers read the stream by offset.
+type OutboxEvent struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	Offset        *int64          `gorm:"column:event_offset;uniqueIndex" json:"offset"` // position in the stream, nil until published
+	Type          string          `gorm:"not null;index" json:"type"`
+	AggregateType string          `gorm:"not null" json:"aggregate_type"` // product, stock
+	AggregateID   uint            `gorm:"not null" json:"aggregate_id"`
+	Payload       json.RawMessage `gorm:"type:jsonb" json:"payload"`
+	ActorID       uint            `json:"actor_id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	PublishedAt   *time.Time      `json:"published_at"`
+}
+
+// EventSubscription is a consumer of the event stream and how far it has got.
+// Events after Offset are delivered in order, at least once.
+type EventSubscription struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	Name          string    `gorm:"uniqueIndex;not null" json:"name"`
+	Type          string    `gorm:"not null" json:"type"` // WEBHOOK, INTERNAL
+	URL           string    `json:"url"`
+	Secret        string    `json:"-"`                                                   // webhook signing key
+	EventTypes    string    `json:"event_types"`                                         // comma-separated, empty for all
+	Offset        int64     `gorm:"column:last_offset;not null;default:0" json:"offset"` // last event delivered
+	Attempts      int       `gorm:"not null;default:0" json:"attempts"`                  // consecutive failures
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error"`
+	IsActive      bool      `gorm:"default:true" json:"is_active"`
+	CreatedBy     uint      `json:"created_by"`
+	UpdatedBy     uint      `json:"updated_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// Lot is a batch of a product received together, with its own dates
+type Lot struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	ProductID       uint       `gorm:"not null;uniqueIndex:idx_lots_product_number" json:"product_id"`
+	LotNumber       string     `gorm:"not null;uniqueIndex:idx_lots_product_number" json:"lot_number"`
+	ManufactureDate *time.Time `json:"manufacture_date"`
+	ExpiryDate      *time.Time `gorm:"index" json:"expiry_date"`
+	CreatedBy       uint       `json:"created_by"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// LotBalance is the quantity of one lot held at one warehouse. The lot
+// balances of a product at a warehouse never exceed its StockBalance; any
+// difference is stock received without a lot.
+type LotBalance struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	LotID       uint      `gorm:"not null;uniqueIndex:idx_lot_balances_lot_warehouse" json:"lot_id"`
+	ProductID   uint      `gorm:"not null;index" json:"product_id"`
+	WarehouseID uint      `gorm:"not null;uniqueIndex:idx_lot_balances_lot_warehouse" json:"warehouse_id"`
+	Quantity    int       `gorm:"not null;default:0;check:chk_lot_balances_quantity,quantity >= 0" json:"quantity"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Lot         *Lot      `gorm:"foreignKey:LotID" json:"lot,omitempty"`
+}
+
+// StockLotAllocation records which lots a movement added to or consumed from
+type StockLotAllocation struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	StockID   uint      `gorm:"not null;index" json:"stock_id"`
+	LotID     uint      `gorm:"not null;index" json:"lot_id"`
+	Quantity  int       `gorm:"not null" json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+	Lot       *Lot      `gorm:"foreignKey:LotID" json:"lot,omitempty"`
+}
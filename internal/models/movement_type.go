@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	MovementDirectionIn   = "IN"   // adds the quantity to the balance
+	MovementDirectionOut  = "OUT"  // takes the quantity off the balance
+	MovementDirectionSet  = "SET"  // sets the balance to the quantity
+	MovementDirectionNone = "NONE" // leaves the balance unchanged
+)
+
+// MovementType is an entry in the catalogue of stock movement types.
+// System types are used by the built-in workflows and cannot be removed or
+// have their behaviour changed; other types are managed by admins.
+type MovementType struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	Code             string    `gorm:"uniqueIndex;not null" json:"code"`
+	Name             string    `gorm:"not null" json:"name"`
+	Direction        string    `gorm:"not null" json:"direction"`              // IN, OUT, SET, NONE
+	AffectsValuation bool      `json:"affects_valuation"`                      // false records the movement at zero cost
+	RequiresApproval bool      `gorm:"default:false" json:"requires_approval"` // held for approval before it is posted
+	RequiresReason   bool      `gorm:"default:false" json:"requires_reason"`   // a reason code must be given
+	Manual           bool      `json:"manual"`                                 // can be posted through POST /stocks
+	IsSystem         bool      `gorm:"default:false" json:"is_system"`
+	IsActive         bool      `gorm:"default:true" json:"is_active"`
+	CreatedBy        uint      `json:"created_by"`
+	UpdatedBy        uint      `json:"updated_by"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ReasonCode explains why a movement happened, e.g. why stock was written off
+type ReasonCode struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Code        string    `gorm:"uniqueIndex;not null" json:"code"`
+	Name        string    `gorm:"not null" json:"name"`
+	Description string    `json:"description"`
+	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	CreatedBy   uint      `json:"created_by"`
+	UpdatedBy   uint      `json:"updated_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SystemMovementTypes are the movement types the built-in workflows post
+func SystemMovementTypes() []MovementType {
+	types := []MovementType{
+		{Code: "IN", Name: "Stock in", Direction: MovementDirectionIn, AffectsValuation: true, Manual: true},
+		{Code: "OUT", Name: "Stock out", Direction: MovementDirectionOut, AffectsValuation: true, Manual: true},
+		{Code: "ADJUST", Name: "Adjustment", Direction: MovementDirectionSet, AffectsValuation: true, Manual: true},
+		{Code: "TRANSFER_OUT", Name: "Transfer shipped", Direction: MovementDirectionOut, AffectsValuation: true},
+		{Code: "TRANSFER_IN", Name: "Transfer received", Direction: MovementDirectionIn, AffectsValuation: true},
+		{Code: "TRANSIT_LOSS", Name: "Lost in transit", Direction: MovementDirectionNone, AffectsValuation: true},
+		{Code: "RETURN_IN", Name: "Customer return", Direction: MovementDirectionIn, AffectsValuation: true},
+		{Code: "RESTOCK", Name: "Return restocked", Direction: MovementDirectionNone, AffectsValuation: true},
+		{Code: "SCRAP", Name: "Return scrapped", Direction: MovementDirectionOut, AffectsValuation: true},
+		{Code: "RTV", Name: "Return to vendor", Direction: MovementDirectionOut, AffectsValuation: true},
+	}
+	for i := range types {
+		types[i].IsSystem = true
+		types[i].IsActive = true
+	}
+	return types
+}
+
+// DefaultReasonCodes are seeded on first start
+func DefaultReasonCodes() []ReasonCode {
+	return []ReasonCode{
+		{Code: "DAMAGED", Name: "Damaged"},
+		{Code: "EXPIRED", Name: "Expired"},
+		{Code: "THEFT", Name: "Theft"},
+		{Code: "COUNT_VARIANCE", Name: "Count variance"},
+		{Code: "LOST_IN_TRANSIT", Name: "Lost in transit"},
+	}
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	PurchaseOrderStatusDraft             = "DRAFT"
+	PurchaseOrderStatusApproved          = "APPROVED"
+	PurchaseOrderStatusPartiallyReceived = "PARTIALLY_RECEIVED"
+	PurchaseOrderStatusClosed            = "CLOSED"
+	PurchaseOrderStatusCancelled         = "CANCELLED"
+)
+
+type PurchaseOrder struct {
+	ID           uint                `gorm:"primaryKey" json:"id"`
+	PONumber     string              `gorm:"uniqueIndex" json:"po_number"`
+	SupplierID   uint                `gorm:"not null;index" json:"supplier_id"`
+	WarehouseID  uint                `gorm:"not null" json:"warehouse_id"` // where the goods are received
+	Status       string              `gorm:"not null;index" json:"status"` // DRAFT, APPROVED, PARTIALLY_RECEIVED, CLOSED, CANCELLED
+	ExpectedDate *time.Time          `json:"expected_date"`
+	Notes        string              `json:"notes"`
+	ApprovedBy   *uint               `json:"approved_by"`
+	ApprovedAt   *time.Time          `json:"approved_at"`
+	ClosedAt     *time.Time          `json:"closed_at"`
+	CreatedBy    uint                `json:"created_by"`
+	UpdatedBy    uint                `json:"updated_by"`
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+	Lines        []PurchaseOrderLine `gorm:"foreignKey:PurchaseOrderID" json:"lines"`
+	Supplier     *Supplier           `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+	Warehouse    *Warehouse          `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
+}
+
+type PurchaseOrderLine struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	PurchaseOrderID  uint      `gorm:"not null;index" json:"purchase_order_id"`
+	ProductID        uint      `gorm:"not null;index" json:"product_id"`
+	QuantityOrdered  int       `gorm:"not null" json:"quantity_ordered"`
+	QuantityReceived int       `gorm:"not null;default:0" json:"quantity_received"`
+	UnitCost         float64   `json:"unit_cost"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Product          *Product  `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+}
+
+// Outstanding returns the quantity still expected on the line
+func (l PurchaseOrderLine) Outstanding() int {
+	return max(l.QuantityOrdered-l.QuantityReceived, 0)
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	TokenRevokedLogout    = "LOGOUT"
+	TokenRevokedLogoutAll = "LOGOUT_ALL"
+	TokenRevokedReuse     = "REUSE"
+)
+
+// RefreshToken is one link in a session's chain of refresh tokens. Every
+// refresh uses the token up and issues the next one in the same family
+// (FamilyID is the session ID carried as "sid" in access tokens). Presenting a
+// used token again means it leaked, so the whole family is revoked.
+type RefreshToken struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID      string     `gorm:"not null;index" json:"family_id"`
+	TokenHash     string     `gorm:"uniqueIndex;not null" json:"-"` // SHA-256 of the token, the token itself is never stored
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt        *time.Time `json:"used_at"` // set when exchanged for the next token
+	RevokedAt     *time.Time `json:"revoked_at"`
+	RevokedReason string     `json:"revoked_reason,omitempty"` // LOGOUT, LOGOUT_ALL, REUSE
+	UserAgent     string     `json:"user_agent"`
+	IPAddress     string     `json:"ip_address"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ReplenishmentStatusProposed = "PROPOSED"
+	ReplenishmentStatusOrdered  = "ORDERED"
+)
+
+// ReplenishmentRun is one pass of the replenishment engine over the products
+type ReplenishmentRun struct {
+	ID          uint                      `gorm:"primaryKey" json:"id"`
+	WarehouseID uint                      `gorm:"not null" json:"warehouse_id"` // where suggested purchase orders are received
+	Notes       string                    `json:"notes"`
+	CreatedBy   uint                      `json:"created_by"`
+	CreatedAt   time.Time                 `json:"created_at"`
+	Suggestions []ReplenishmentSuggestion `gorm:"foreignKey:RunID" json:"suggestions"`
+	Warehouse   *Warehouse                `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
+}
+
+// ReplenishmentSuggestion proposes ordering a product whose available and
+// inbound stock has fallen to its reorder point (Product.MinQuantity)
+type ReplenishmentSuggestion struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	RunID             uint      `gorm:"not null;index" json:"run_id"`
+	ProductID         uint      `gorm:"not null;index" json:"product_id"`
+	SupplierID        *uint     `json:"supplier_id"` // nil when no supplier sells the product
+	Available         int       `json:"available"`   // on hand less reserved and quarantined
+	Inbound           int       `json:"inbound"`     // outstanding on open purchase orders and in transit
+	ReorderPoint      int       `json:"reorder_point"`
+	TargetQuantity    int       `json:"target_quantity"`    // the level the order brings stock up to
+	SuggestedQuantity int       `json:"suggested_quantity"` // after pack size and minimum order rounding
+	UnitCost          float64   `json:"unit_cost"`
+	Status            string    `gorm:"not null" json:"status"` // PROPOSED, ORDERED
+	PurchaseOrderID   *uint     `json:"purchase_order_id,omitempty"`
+	UpdatedBy         uint      `json:"updated_by"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	Product           *Product  `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Supplier          *Supplier `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+}
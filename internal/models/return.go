@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ReturnStatusAuthorized = "AUTHORIZED"
+	ReturnStatusReceived   = "RECEIVED" // at least partly received, waiting for inspection and disposition
+	ReturnStatusClosed     = "CLOSED"
+	ReturnStatusCancelled  = "CANCELLED"
+)
+
+const (
+	ReturnConditionGood      = "GOOD"
+	ReturnConditionDamaged   = "DAMAGED"
+	ReturnConditionDefective = "DEFECTIVE"
+)
+
+const (
+	DispositionRestock = "RESTOCK"
+	DispositionScrap   = "SCRAP"
+	DispositionRTV     = "RTV" // return to vendor
+)
+
+// ReturnAuthorization (RMA) authorises a customer to send goods back.
+// Received goods are held in quarantine at the warehouse until each unit is
+// inspected and then restocked, scrapped or returned to the vendor.
+type ReturnAuthorization struct {
+	ID             uint         `gorm:"primaryKey" json:"id"`
+	RMANumber      string       `gorm:"uniqueIndex" json:"rma_number"`
+	SalesReference string       `gorm:"not null;index" json:"sales_reference"` // SO number or external invoice
+	SalesOrderID   *uint        `gorm:"index" json:"sales_order_id"`           // set when the reference is one of our sales orders
+	CustomerName   string       `json:"customer_name"`
+	WarehouseID    uint         `gorm:"not null" json:"warehouse_id"` // where the goods are received into quarantine
+	Status         string       `gorm:"not null;index" json:"status"` // AUTHORIZED, RECEIVED, CLOSED, CANCELLED
+	Notes          string       `json:"notes"`
+	ClosedAt       *time.Time   `json:"closed_at"`
+	CreatedBy      uint         `json:"created_by"`
+	UpdatedBy      uint         `json:"updated_by"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	Lines          []ReturnLine `gorm:"foreignKey:ReturnID" json:"lines"`
+	Warehouse      *Warehouse   `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
+}
+
+type ReturnLine struct {
+	ID                 uint       `gorm:"primaryKey" json:"id"`
+	ReturnID           uint       `gorm:"not null;index" json:"return_id"`
+	ProductID          uint       `gorm:"not null;index" json:"product_id"`
+	Reason             string     `json:"reason"` // customer's reason for returning
+	QuantityAuthorized int        `gorm:"not null" json:"quantity_authorized"`
+	QuantityReceived   int        `gorm:"not null;default:0" json:"quantity_received"`
+	QuantityRestocked  int        `gorm:"not null;default:0" json:"quantity_restocked"`
+	QuantityScrapped   int        `gorm:"not null;default:0" json:"quantity_scrapped"`
+	QuantityRTV        int        `gorm:"not null;default:0" json:"quantity_rtv"`
+	Condition          string     `json:"condition"` // inspection result: GOOD, DAMAGED, DEFECTIVE
+	InspectionNotes    string     `json:"inspection_notes"`
+	InspectedBy        *uint      `json:"inspected_by"`
+	InspectedAt        *time.Time `json:"inspected_at"`
+	SupplierID         *uint      `json:"supplier_id"` // vendor the RTV quantity went back to
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	Product            *Product   `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+}
+
+// InQuarantine returns the quantity received but not yet dispositioned
+func (l ReturnLine) InQuarantine() int {
+	return l.QuantityReceived - l.QuantityRestocked - l.QuantityScrapped - l.QuantityRTV
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	PermInventoryRead    = "inventory:read"    // view products, stock, orders and the other records, and the live stock stream
+	PermEventRead        = "event:read"        // read the domain event log
+	PermProductWrite     = "product:write"     // create, edit and delete products, apply forecasts
+	PermStockWrite       = "stock:write"       // post and reverse stock movements
+	PermStockAdjust      = "stock:adjust"      // post movements that set the balance, e.g. ADJUST
+	PermStockApprove     = "stock:approve"     // approve or reject held movements
+	PermWarehouseWrite   = "warehouse:write"   // manage warehouses
+	PermTransferWrite    = "transfer:write"    // ship, receive and close transfers
+	PermSupplierWrite    = "supplier:write"    // manage suppliers and their products
+	PermPurchaseWrite    = "purchase:write"    // create, edit and receive purchase orders, run replenishment
+	PermPurchaseApprove  = "purchase:approve"  // approve, close and cancel purchase orders
+	PermSalesWrite       = "sales:write"       // create, fulfil and cancel sales orders
+	PermReturnWrite      = "return:write"      // process customer returns
+	PermStocktakeWrite   = "stocktake:write"   // open, count and cancel stocktakes
+	PermStocktakeApprove = "stocktake:approve" // post stocktakes, classify ABC and generate cycle counts
+	PermCatalogAdmin     = "catalog:admin"     // manage movement types and reason codes
+	PermAlertAdmin       = "alert:admin"       // manage alert channels and retry deliveries
+	PermEventAdmin       = "event:admin"       // manage event subscriptions
+	PermReportRead       = "report:read"       // valuation, shrinkage and count accuracy reports
+	PermUserAdmin        = "user:admin"        // manage roles and assign them to users
+)
+
+// ReadPermissions were added after roles and API keys could already read
+// everything, so existing ones are granted them once on upgrade
+func ReadPermissions() []string {
+	return []string{PermInventoryRead, PermEventRead}
+}
+
+// Permissions lists every permission the API checks
+func Permissions() []string {
+	return []string{
+		PermInventoryRead, PermEventRead,
+		PermProductWrite, PermStockWrite, PermStockAdjust, PermStockApprove,
+		PermWarehouseWrite, PermTransferWrite, PermSupplierWrite,
+		PermPurchaseWrite, PermPurchaseApprove, PermSalesWrite, PermReturnWrite,
+		PermStocktakeWrite, PermStocktakeApprove, PermCatalogAdmin,
+		PermAlertAdmin, PermEventAdmin, PermReportRead, PermUserAdmin,
+	}
+}
+
+// Role is a named set of permissions. User.Role holds the role name.
+// System roles are seeded on start and cannot be deleted; the admin role
+// always has every permission.
+type Role struct {
+	ID          uint             `gorm:"primaryKey" json:"id"`
+	Name        string           `gorm:"uniqueIndex;not null" json:"name"`
+	Description string           `json:"description"`
+	IsSystem    bool             `gorm:"default:false" json:"is_system"`
+	Permissions []RolePermission `gorm:"foreignKey:RoleID" json:"permissions"`
+	CreatedBy   uint             `json:"created_by"`
+	UpdatedBy   uint             `json:"updated_by"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// RolePermission grants one permission to a role
+type RolePermission struct {
+	ID         uint   `gorm:"primaryKey" json:"-"`
+	RoleID     uint   `gorm:"not null;uniqueIndex:idx_role_permissions_role_permission" json:"-"`
+	Permission string `gorm:"not null;uniqueIndex:idx_role_permissions_role_permission" json:"permission"`
+}
+
+// SystemRole is a built-in role and the permissions it starts with
+type SystemRole struct {
+	Name        string
+	Description string
+	Permissions []string
+}
+
+// SystemRoles are the built-in roles. Permissions of the manager and user
+// roles can be changed afterwards; admin always has every permission.
+func SystemRoles() []SystemRole {
+	user := []string{
+		PermInventoryRead, PermEventRead,
+		PermStockWrite, PermTransferWrite, PermSalesWrite, PermReturnWrite, PermStocktakeWrite,
+	}
+	manager := append([]string{
+		PermProductWrite, PermStockAdjust, PermStockApprove, PermWarehouseWrite,
+		PermSupplierWrite, PermPurchaseWrite, PermPurchaseApprove, PermReportRead,
+	}, user...)
+
+	return []SystemRole{
+		{Name: RoleAdmin, Description: "Full access", Permissions: Permissions()},
+		{Name: RoleManager, Description: "Runs the warehouse and approves sensitive changes", Permissions: manager},
+		{Name: RoleUser, Description: "Day-to-day stock operations", Permissions: user},
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	SalesOrderStatusOpen               = "OPEN"
+	SalesOrderStatusPartiallyFulfilled = "PARTIALLY_FULFILLED"
+	SalesOrderStatusFulfilled          = "FULFILLED"
+	SalesOrderStatusCancelled          = "CANCELLED"
+	SalesOrderStatusExpired            = "EXPIRED"
+)
+
+// SalesOrder reserves stock at a warehouse until it is fulfilled, cancelled
+// or its reservation expires. While the order is open, every line holds its
+// unfulfilled quantity in StockBalance.ReservedQuantity.
+type SalesOrder struct {
+	ID                uint             `gorm:"primaryKey" json:"id"`
+	SONumber          string           `gorm:"uniqueIndex" json:"so_number"`
+	CustomerName      string           `gorm:"not null" json:"customer_name"`
+	CustomerReference string           `json:"customer_reference"`
+	WarehouseID       uint             `gorm:"not null" json:"warehouse_id"` // where the stock is reserved and shipped from
+	Status            string           `gorm:"not null;index" json:"status"` // OPEN, PARTIALLY_FULFILLED, FULFILLED, CANCELLED, EXPIRED
+	ExpiresAt         *time.Time       `gorm:"index" json:"expires_at"`      // reservation released after this time, nil never expires
+	Notes             string           `json:"notes"`
+	ClosedAt          *time.Time       `json:"closed_at"`
+	CreatedBy         uint             `json:"created_by"`
+	UpdatedBy         uint             `json:"updated_by"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+	Lines             []SalesOrderLine `gorm:"foreignKey:SalesOrderID" json:"lines"`
+	Warehouse         *Warehouse       `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
+}
+
+type SalesOrderLine struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	SalesOrderID      uint      `gorm:"not null;index" json:"sales_order_id"`
+	ProductID         uint      `gorm:"not null;index" json:"product_id"`
+	QuantityOrdered   int       `gorm:"not null" json:"quantity_ordered"`
+	QuantityFulfilled int       `gorm:"not null;default:0" json:"quantity_fulfilled"`
+	UnitPrice         float64   `json:"unit_price"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	Product           *Product  `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+}
+
+// Outstanding returns the quantity still to be shipped, which is also the
+// quantity reserved while the order is open
+func (l SalesOrderLine) Outstanding() int {
+	return max(l.QuantityOrdered-l.QuantityFulfilled, 0)
+}
+
+// IsOpen reports whether the order still holds a reservation
+func (o SalesOrder) IsOpen() bool {
+	return o.Status == SalesOrderStatusOpen || o.Status == SalesOrderStatusPartiallyFulfilled
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	TrackingModeNone   = "none"
+	TrackingModeLot    = "lot"
+	TrackingModeSerial = "serial"
+)
+
+const (
+	SerialStatusInStock     = "IN_STOCK"
+	SerialStatusInTransit   = "IN_TRANSIT"
+	SerialStatusOut         = "OUT"
+	SerialStatusQuarantined = "QUARANTINED" // returned, held at a warehouse until dispositioned
+)
+
+// Serial is a single unit of a serial-tracked product
+type Serial struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	SerialNumber string     `gorm:"uniqueIndex;not null" json:"serial_number"`
+	ProductID    uint       `gorm:"not null;index" json:"product_id"`
+	WarehouseID  *uint      `gorm:"index" json:"warehouse_id"` // nil unless IN_STOCK or QUARANTINED
+	Status       string     `gorm:"not null" json:"status"`    // IN_STOCK, IN_TRANSIT, OUT, QUARANTINED
+	CreatedBy    uint       `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	Product      *Product   `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Warehouse    *Warehouse `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
+}
+
+// StockSerial links a movement to each serial it moved
+type StockSerial struct {
+	ID       uint    `gorm:"primaryKey" json:"id"`
+	StockID  uint    `gorm:"not null;index" json:"stock_id"`
+	SerialID uint    `gorm:"not null;index" json:"serial_id"`
+	Serial   *Serial `gorm:"foreignKey:SerialID" json:"serial,omitempty"`
+}
@@ -1,29 +1,66 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
+const (
+	StockStatusPosted   = "POSTED"   // applied to the balance
+	StockStatusPending  = "PENDING"  // waiting for a manager to approve it
+	StockStatusRejected = "REJECTED" // never applied
+)
+
 type Stock struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	ProductID   uint      `gorm:"not null" json:"product_id"`
-	Type        string    `gorm:"not null" json:"type"` // IN, OUT, ADJUST
-	Quantity    int       `gorm:"not null" json:"quantity"`
-	OldQuantity int       `json:"old_quantity"`
-	NewQuantity int       `json:"new_quantity"`
-	Reference   string    `json:"reference"` // PO number, Sales order, etc.
-	Notes       string    `json:"notes"`
-	CreatedBy   uint      `json:"created_by"`
-	UpdatedBy   uint      `json:"updated_by"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Product     Product   `gorm:"foreignKey:ProductID" json:"product,omitempty"`
-	User        User      `gorm:"foreignKey:CreatedBy" json:"user,omitempty"`
+	ID             uint                 `gorm:"primaryKey" json:"id"`
+	ProductID      uint                 `gorm:"not null" json:"product_id"`
+	WarehouseID    uint                 `json:"warehouse_id"`
+	Type           string               `gorm:"not null" json:"type"` // IN, OUT, ADJUST, TRANSFER_OUT, TRANSFER_IN, TRANSIT_LOSS, RETURN_IN, RESTOCK, SCRAP, RTV
+	Quantity       int                  `gorm:"not null" json:"quantity"`
+	OldQuantity    int                  `json:"old_quantity"` // balance at the warehouse before the movement
+	NewQuantity    int                  `json:"new_quantity"` // balance at the warehouse after the movement
+	Reference      string               `json:"reference"`    // PO number, Sales order, etc.
+	ReasonCode     string               `gorm:"index" json:"reason_code,omitempty"`
+	UnitCost       float64              `json:"unit_cost"`  // receipt cost for inbound, issue cost for outbound
+	TotalCost      float64              `json:"total_cost"` // unit cost times the change in balance
+	TransferID     *uint                `gorm:"index" json:"transfer_id,omitempty"`
+	POLineID       *uint                `gorm:"index" json:"po_line_id,omitempty"`  // purchase order line a receipt was booked against
+	SOLineID       *uint                `gorm:"index" json:"so_line_id,omitempty"`  // sales order line a shipment fulfilled
+	ReversalOf     *uint                `gorm:"index" json:"reversal_of,omitempty"` // set on the compensating movement
+	ReversedBy     *uint                `json:"reversed_by,omitempty"`              // set on the original once reversed
+	ReversedAt     *time.Time           `json:"reversed_at,omitempty"`
+	Status         string               `gorm:"not null;default:POSTED;index" json:"status"` // POSTED, PENDING, REJECTED
+	PendingRequest json.RawMessage      `gorm:"type:jsonb" json:"pending_request,omitempty"` // the request held for approval
+	ReviewedBy     *uint                `json:"reviewed_by,omitempty"`                       // manager who approved or rejected a pending movement
+	ReviewedAt     *time.Time           `json:"reviewed_at,omitempty"`
+	ReviewNotes    string               `json:"review_notes,omitempty"`
+	Notes          string               `json:"notes"`
+	CreatedBy      uint                 `json:"created_by"`
+	UpdatedBy      uint                 `json:"updated_by"`
+	CreatedAt      time.Time            `json:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+	Product        Product              `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	User           User                 `gorm:"foreignKey:CreatedBy" json:"user,omitempty"`
+	Warehouse      *Warehouse           `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
+	Lots           []StockLotAllocation `gorm:"foreignKey:StockID" json:"lots,omitempty"`
+	Serials        []StockSerial        `gorm:"foreignKey:StockID" json:"serials,omitempty"`
 }
 
 type StockUpdateRequest struct {
-	ProductID uint   `json:"product_id" binding:"required"`
-	Type      string `json:"type" binding:"required,oneof=IN OUT ADJUST"`
-	Quantity  int    `json:"quantity" binding:"required,gt=0"`
-	Notes     string `json:"notes"`
+	ProductID     uint    `json:"product_id" binding:"required"`
+	WarehouseID   uint    `json:"warehouse_id"` // optional, defaults to the default warehouse
+	ToWarehouseID uint    `json:"to_warehouse_id" binding:"required_if=Type TRANSFER"`
+	Type          string  `json:"type" binding:"required"` // TRANSFER or an active manual movement type code
+	Quantity      int     `json:"quantity" binding:"required,gt=0"`
+	UnitCost      float64 `json:"unit_cost" binding:"min=0"` // optional receipt cost, defaults to the current cost
+	ReasonCode    string  `json:"reason_code"`               // required when the movement type requires a reason
+	Notes         string  `json:"notes"`
+
+	// Lot tracking: required on IN for lot-tracked stock, optional on OUT to pick a lot instead of FEFO
+	LotNumber       string     `json:"lot_number"`
+	ManufactureDate *time.Time `json:"manufacture_date"`
+	ExpiryDate      *time.Time `json:"expiry_date"`
+
+	// Serial tracking: one serial number per unit moved, required for serial-tracked products
+	Serials []string `json:"serials" binding:"omitempty,dive,required"`
 }
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	StocktakeStatusOpen      = "OPEN"
+	StocktakeStatusPosted    = "POSTED"
+	StocktakeStatusCancelled = "CANCELLED"
+)
+
+const (
+	StocktakeLineStatusPending = "PENDING" // waiting for (more) counts
+	StocktakeLineStatusCounted = "COUNTED" // the required counts agree
+	StocktakeLineStatusRecount = "RECOUNT" // counts disagree, another count is needed
+)
+
+// Stocktake is a count of a set of products at one warehouse. Balances are
+// snapshotted when it is opened and variances are posted as ADJUST movements
+// when it is approved. Daily cycle counts are stocktakes with a CycleDate.
+type Stocktake struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	Number         string          `gorm:"uniqueIndex" json:"number"`
+	WarehouseID    uint            `gorm:"not null;index" json:"warehouse_id"`
+	Status         string          `gorm:"not null;index" json:"status"`              // OPEN, POSTED, CANCELLED
+	RequiredCounts int             `gorm:"not null;default:1" json:"required_counts"` // matching counts by different users needed per line
+	Notes          string          `json:"notes"`
+	FrozenAt       time.Time       `json:"frozen_at"`            // when the expected quantities were snapshotted
+	CycleDate      *time.Time      `json:"cycle_date,omitempty"` // set on the daily cycle count generated for that date
+	ApprovedBy     *uint           `json:"approved_by"`
+	ApprovedAt     *time.Time      `json:"approved_at"`
+	ClosedAt       *time.Time      `json:"closed_at"`
+	CreatedBy      uint            `json:"created_by"`
+	UpdatedBy      uint            `json:"updated_by"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	Lines          []StocktakeLine `gorm:"foreignKey:StocktakeID" json:"lines"`
+	Warehouse      *Warehouse      `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
+}
+
+// StocktakeLine is one product being counted. Variance is measured against
+// the balance at the time of the accepted count, so movements posted while the
+// count is open are not mistaken for differences.
+type StocktakeLine struct {
+	ID               uint             `gorm:"primaryKey" json:"id"`
+	StocktakeID      uint             `gorm:"not null;index" json:"stocktake_id"`
+	ProductID        uint             `gorm:"not null;index" json:"product_id"`
+	SnapshotQuantity int              `gorm:"not null" json:"snapshot_quantity"` // balance when the stocktake was opened
+	ABCClass         string           `json:"abc_class"`                         // the product's class when the stocktake was opened
+	Status           string           `gorm:"not null" json:"status"`            // PENDING, COUNTED, RECOUNT
+	CountedQuantity  *int             `json:"counted_quantity"`                  // accepted count
+	ExpectedQuantity *int             `json:"expected_quantity"`                 // balance when the accepted count was taken
+	Variance         int              `json:"variance"`                          // counted minus expected
+	VarianceValue    float64          `json:"variance_value"`                    // cost of the posted adjustment, signed
+	AdjustmentID     *uint            `json:"adjustment_id,omitempty"`           // ADJUST movement posted on approval
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+	Product          *Product         `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Counts           []StocktakeCount `gorm:"foreignKey:StocktakeLineID" json:"counts,omitempty"`
+}
+
+// StocktakeCount is a single count of a line by one counter
+type StocktakeCount struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	StocktakeLineID  uint      `gorm:"not null;index" json:"stocktake_line_id"`
+	Quantity         int       `gorm:"not null" json:"quantity"`
+	ExpectedQuantity int       `gorm:"not null" json:"expected_quantity"` // balance at the moment of the count
+	Notes            string    `json:"notes"`
+	CountedBy        uint      `gorm:"not null" json:"counted_by"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Variance is the difference the count found
+func (c StocktakeCount) Variance() int {
+	return c.Quantity - c.ExpectedQuantity
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+type Supplier struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Code         string    `gorm:"uniqueIndex;not null" json:"code"`
+	Name         string    `gorm:"not null" json:"name"`
+	ContactName  string    `json:"contact_name"`
+	Email        string    `json:"email"`
+	Phone        string    `json:"phone"`
+	Address      string    `json:"address"`
+	LeadTimeDays int       `gorm:"default:0" json:"lead_time_days"`
+	IsActive     bool      `gorm:"default:true" json:"is_active"`
+	CreatedBy    uint      `json:"created_by"`
+	UpdatedBy    uint      `json:"updated_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// SupplierProduct is a product a supplier sells, with the ordering rules used
+// when replenishment proposes a purchase
+type SupplierProduct struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	SupplierID  uint      `gorm:"not null;uniqueIndex:idx_supplier_products_supplier_product" json:"supplier_id"`
+	ProductID   uint      `gorm:"not null;uniqueIndex:idx_supplier_products_supplier_product;index" json:"product_id"`
+	SupplierSKU string    `json:"supplier_sku"`
+	UnitCost    float64   `json:"unit_cost"`                               // 0 uses the product's cost price
+	PackSize    int       `gorm:"not null;default:1" json:"pack_size"`     // orders are rounded up to whole packs
+	MinOrderQty int       `gorm:"not null;default:0" json:"min_order_qty"` // smallest quantity the supplier accepts
+	IsPreferred bool      `json:"is_preferred"`                            // chosen first by replenishment
+	CreatedBy   uint      `json:"created_by"`
+	UpdatedBy   uint      `json:"updated_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Supplier    *Supplier `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+	Product     *Product  `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+}
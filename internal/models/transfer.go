@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	TransferStatusInTransit         = "IN_TRANSIT"
+	TransferStatusPartiallyReceived = "PARTIALLY_RECEIVED"
+	TransferStatusReceived          = "RECEIVED"
+	TransferStatusClosed            = "CLOSED" // closed with a discrepancy
+)
+
+// StockTransfer moves stock between two warehouses. Shipping decrements the
+// source, receiving increments the destination; whatever has been shipped but
+// not yet received is in transit.
+type StockTransfer struct {
+	ID              uint                `gorm:"primaryKey" json:"id"`
+	TransferNo      string              `gorm:"uniqueIndex" json:"transfer_no"`
+	FromWarehouseID uint                `gorm:"not null" json:"from_warehouse_id"`
+	ToWarehouseID   uint                `gorm:"not null" json:"to_warehouse_id"`
+	Status          string              `gorm:"not null" json:"status"` // IN_TRANSIT, PARTIALLY_RECEIVED, RECEIVED, CLOSED
+	Notes           string              `json:"notes"`
+	ShippedAt       time.Time           `json:"shipped_at"`
+	ReceivedAt      *time.Time          `json:"received_at"`
+	CreatedBy       uint                `json:"created_by"`
+	UpdatedBy       uint                `json:"updated_by"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+	Lines           []StockTransferLine `gorm:"foreignKey:TransferID" json:"lines"`
+	FromWarehouse   *Warehouse          `gorm:"foreignKey:FromWarehouseID" json:"from_warehouse,omitempty"`
+	ToWarehouse     *Warehouse          `gorm:"foreignKey:ToWarehouseID" json:"to_warehouse,omitempty"`
+}
+
+type StockTransferLine struct {
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	TransferID          uint      `gorm:"not null;index" json:"transfer_id"`
+	ProductID           uint      `gorm:"not null" json:"product_id"`
+	QuantityShipped     int       `gorm:"not null" json:"quantity_shipped"`
+	QuantityReceived    int       `gorm:"not null;default:0" json:"quantity_received"`
+	QuantityDiscrepancy int       `gorm:"not null;default:0" json:"quantity_discrepancy"` // shipped but never received
+	UnitCost            float64   `json:"unit_cost"`                                      // cost at shipping, carried to the receipt
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+	Product             *Product  `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+}
+
+// InTransit returns the quantity shipped but not yet received or written off
+func (l StockTransferLine) InTransit() int {
+	return l.QuantityShipped - l.QuantityReceived - l.QuantityDiscrepancy
+}
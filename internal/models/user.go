@@ -4,15 +4,34 @@ import (
 	"time"
 )
 
+const (
+	RoleAdmin   = "admin"
+	RoleManager = "manager" // approves held stock movements
+	RoleUser    = "user"
+)
+
 type User struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
 	Email       string    `gorm:"uniqueIndex;not null" json:"email"`
 	Password    string    `json:"-"`
 	GoogleID    *string   `gorm:"uniqueIndex" json:"google_id,omitempty"`
 	Name        string    `json:"name"`
-	Role        string    `gorm:"default:user" json:"role"`
+	Role        string    `gorm:"default:user" json:"role"` // admin, manager, user
 	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	IsService   bool      `gorm:"default:false" json:"is_service"` // service account, authenticates with API keys only
 	LastLoginAt time.Time `json:"last_login_at"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider. Subject is the provider's stable ID for the account ("sub").
+type UserIdentity struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	Provider    string    `gorm:"not null;uniqueIndex:idx_user_identities_provider_subject" json:"provider"`
+	Subject     string    `gorm:"not null;uniqueIndex:idx_user_identities_provider_subject" json:"subject"`
+	Email       string    `json:"email"`
+	LastLoginAt time.Time `json:"last_login_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
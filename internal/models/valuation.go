@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	CostingMethodFIFO     = "FIFO"
+	CostingMethodAverage  = "AVERAGE"
+	CostingMethodStandard = "STANDARD"
+)
+
+// CostLayer is the unconsumed part of one receipt, used for FIFO costing.
+// Layers are kept for every product so its costing method can be changed.
+type CostLayer struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	ProductID         uint      `gorm:"not null;index" json:"product_id"`
+	StockID           uint      `gorm:"not null" json:"stock_id"` // the receipt that created the layer
+	UnitCost          float64   `gorm:"not null" json:"unit_cost"`
+	QuantityReceived  int       `gorm:"not null" json:"quantity_received"`
+	QuantityRemaining int       `gorm:"not null" json:"quantity_remaining"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
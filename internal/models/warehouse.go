@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+type Warehouse struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Code      string    `gorm:"uniqueIndex;not null" json:"code"`
+	Name      string    `gorm:"not null" json:"name"`
+	Address   string    `json:"address"`
+	IsDefault bool      `gorm:"default:false" json:"is_default"`
+	IsActive  bool      `gorm:"default:true" json:"is_active"`
+	CreatedBy uint      `json:"created_by"`
+	UpdatedBy uint      `json:"updated_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StockBalance is the on-hand quantity of one product at one warehouse.
+// Product.Quantity is kept as the sum of these rows. ReservedQuantity is held
+// for open sales orders and QuarantinedQuantity is returned stock awaiting
+// disposition; what is left is available to promise.
+type StockBalance struct {
+	ID                  uint       `gorm:"primaryKey" json:"id"`
+	ProductID           uint       `gorm:"not null;uniqueIndex:idx_stock_balances_product_warehouse" json:"product_id"`
+	WarehouseID         uint       `gorm:"not null;uniqueIndex:idx_stock_balances_product_warehouse" json:"warehouse_id"`
+	Quantity            int        `gorm:"not null;default:0;check:chk_stock_balances_quantity,quantity >= 0" json:"quantity"`
+	ReservedQuantity    int        `gorm:"not null;default:0;check:chk_stock_balances_reserved,reserved_quantity >= 0" json:"reserved_quantity"`
+	QuarantinedQuantity int        `gorm:"not null;default:0;check:chk_stock_balances_quarantined,quarantined_quantity >= 0" json:"quarantined_quantity"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	Product             *Product   `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Warehouse           *Warehouse `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
+}
@@ -0,0 +1,83 @@
+// Package testutil holds the database setup shared by tests that need
+// PostgreSQL or Redis. They are skipped when TEST_DATABASE_URL or
+// TEST_REDIS_URL is not set.
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/impk123/Inventory-Management-Mini-System/pkg/database"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	migrateOnce sync.Once
+	migrateErr  error
+	seq         atomic.Int64
+)
+
+// OpenDB connects to the PostgreSQL database named by TEST_DATABASE_URL,
+// migrating and seeding it on first use. Tests create their own rows with
+// unique names, so the database may be shared between runs.
+func OpenDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	migrateOnce.Do(func() {
+		if migrateErr = database.RunMigrations(db); migrateErr == nil {
+			migrateErr = database.SeedData(db)
+		}
+	})
+	if migrateErr != nil {
+		t.Fatalf("migrate test database: %v", migrateErr)
+	}
+	return db
+}
+
+// UniqueName returns a name no other test row uses
+func UniqueName(prefix string) string {
+	return fmt.Sprintf("%s-%d-%d", prefix, time.Now().UnixNano(), seq.Add(1))
+}
+
+// OpenRedis connects to the Redis named by TEST_REDIS_URL, for example
+// redis://localhost:6379/15. Tests only write keys they generate themselves.
+func OpenRedis(t testing.TB) *redis.Client {
+	t.Helper()
+	url := os.Getenv("TEST_REDIS_URL")
+	if url == "" {
+		t.Skip("TEST_REDIS_URL not set")
+	}
+
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		t.Fatalf("parse TEST_REDIS_URL: %v", err)
+	}
+	client := redis.NewClient(opts)
+	t.Cleanup(func() { client.Close() })
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("connect to test redis: %v", err)
+	}
+	return client
+}
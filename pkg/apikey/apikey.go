@@ -0,0 +1,41 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Prefix marks a bearer credential as an API key rather than a JWT
+const Prefix = "imk_"
+
+// Generate returns a new key, the short public ID shown to identify it and
+// the hash to store. The key itself is only ever shown once.
+func Generate() (key, publicID, hash string, err error) {
+	id := make([]byte, 6)
+	if _, err = rand.Read(id); err != nil {
+		return "", "", "", err
+	}
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+
+	publicID = hex.EncodeToString(id)
+	key = Prefix + publicID + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	return key, publicID, Hash(key), nil
+}
+
+// Hash is how keys are stored and looked up. Keys carry 256 bits of
+// randomness, so a plain SHA-256 is enough.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsKey reports whether a credential looks like an API key
+func IsKey(credential string) bool {
+	return strings.HasPrefix(credential, Prefix)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,8 +14,9 @@ import (
 )
 
 const (
-	ProductVersionKey = "version:product"
-	StockVersionKey   = "version:stock"
+	ProductVersionKey    = "version:product"
+	StockVersionKey      = "version:stock"
+	ReservationExpiryKey = "reservation:expiry"
 )
 
 // GenerateProductKey สร้าง Key สำหรับสินค้า (Single)
@@ -75,6 +77,52 @@ func GenerateStockHistoryKey(client *redis.Client, ctx context.Context, productI
 	return sb.String()
 }
 
+// GenerateIdempotencyKey สร้าง Key สำหรับเก็บผลลัพธ์ของ Request ที่มี Idempotency-Key แยกตาม User และ Path
+func GenerateIdempotencyKey(userID uint, path string, key string) string {
+	return fmt.Sprintf("idempotency:user:%d:%s:%s", userID, path, key)
+}
+
+// GenerateRevokedTokenKey สร้าง Key สำหรับ Access Token (jti) ที่ถูกเพิกถอนก่อนหมดอายุ
+func GenerateRevokedTokenKey(jti string) string {
+	return fmt.Sprintf("auth:revoked:jti:%s", jti)
+}
+
+// GenerateRevokedSessionKey สร้าง Key สำหรับ Session (sid) ที่ถูกเพิกถอน ทำให้ Access Token ทุกใบของ Session ใช้ไม่ได้
+func GenerateRevokedSessionKey(sid string) string {
+	return fmt.Sprintf("auth:revoked:sid:%s", sid)
+}
+
+// ScheduleReservationExpiry บันทึกเวลาหมดอายุของการจองสินค้าของ Sales Order ลงใน Sorted Set (score = Unix time)
+func ScheduleReservationExpiry(client *redis.Client, ctx context.Context, orderID uint, at time.Time) error {
+	return client.ZAdd(ctx, ReservationExpiryKey, redis.Z{Score: float64(at.Unix()), Member: orderID}).Err()
+}
+
+// DueReservationExpiries ดึง Sales Order ID ที่การจองหมดอายุแล้ว ณ เวลา now
+func DueReservationExpiries(client *redis.Client, ctx context.Context, now time.Time) ([]uint, error) {
+	members, err := client.ZRangeByScore(ctx, ReservationExpiryKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(members))
+	for _, m := range members {
+		id, err := strconv.ParseUint(m, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// ClearReservationExpiry ลบ Sales Order ออกจากตารางเวลาหมดอายุ (เมื่อส่งครบ ยกเลิก หรือหมดอายุไปแล้ว)
+func ClearReservationExpiry(client *redis.Client, ctx context.Context, orderID uint) error {
+	return client.ZRem(ctx, ReservationExpiryKey, orderID).Err()
+}
+
 // InvalidateStockList "เปลี่ยน Version" เพื่อทำให้ Cache Stock List เดิมทั้งหมดเป็นโมฆะทันที
 func InvalidateStockList(client *redis.Client, ctx context.Context) error {
 	return client.Incr(ctx, StockVersionKey).Err()
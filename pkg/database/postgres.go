@@ -3,11 +3,13 @@ package database
 import (
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/impk123/Inventory-Management-Mini-System/internal/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -62,6 +64,42 @@ func RunMigrations(db *gorm.DB) error {
 		&models.User{},
 		&models.Product{},
 		&models.Stock{},
+		&models.Warehouse{},
+		&models.StockBalance{},
+		&models.StockTransfer{},
+		&models.StockTransferLine{},
+		&models.Lot{},
+		&models.LotBalance{},
+		&models.StockLotAllocation{},
+		&models.Serial{},
+		&models.StockSerial{},
+		&models.CostLayer{},
+		&models.Supplier{},
+		&models.SupplierProduct{},
+		&models.PurchaseOrder{},
+		&models.PurchaseOrderLine{},
+		&models.SalesOrder{},
+		&models.SalesOrderLine{},
+		&models.ReturnAuthorization{},
+		&models.ReturnLine{},
+		&models.MovementType{},
+		&models.ReasonCode{},
+		&models.Stocktake{},
+		&models.StocktakeLine{},
+		&models.StocktakeCount{},
+		&models.ReplenishmentRun{},
+		&models.ReplenishmentSuggestion{},
+		&models.Alert{},
+		&models.AlertChannel{},
+		&models.AlertDelivery{},
+		&models.OutboxEvent{},
+		&models.EventSubscription{},
+		&models.Role{},
+		&models.RolePermission{},
+		&models.RefreshToken{},
+		&models.UserIdentity{},
+		&models.APIKey{},
+		&models.DataMigration{},
 	); err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
@@ -98,6 +136,70 @@ func createIndexes(db *gorm.DB) {
 		CREATE INDEX IF NOT EXISTS idx_stocks_created_at ON stocks(created_at);
 		CREATE INDEX IF NOT EXISTS idx_stocks_type ON stocks(type);
 		CREATE INDEX IF NOT EXISTS idx_stocks_reference ON stocks(reference);
+		CREATE INDEX IF NOT EXISTS idx_stocks_warehouse_id ON stocks(warehouse_id);
+	`)
+
+	// Stock balance indexes
+	db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_stock_balances_warehouse_id ON stock_balances(warehouse_id);
+	`)
+
+	// Transfer indexes
+	db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_stock_transfers_status ON stock_transfers(status);
+		CREATE INDEX IF NOT EXISTS idx_stock_transfer_lines_product_id ON stock_transfer_lines(product_id);
+	`)
+
+	// Lot indexes
+	db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_lot_balances_product_warehouse ON lot_balances(product_id, warehouse_id);
+	`)
+
+	// Serial indexes
+	db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_serials_status ON serials(status);
+	`)
+
+	// Purchase order indexes
+	db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_suppliers_is_active ON suppliers(is_active);
+		CREATE INDEX IF NOT EXISTS idx_purchase_order_lines_product_id ON purchase_order_lines(product_id);
+	`)
+
+	// Sales order indexes
+	db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_sales_orders_warehouse_id ON sales_orders(warehouse_id);
+		CREATE INDEX IF NOT EXISTS idx_sales_orders_expires_at ON sales_orders(expires_at) WHERE expires_at IS NOT NULL;
+	`)
+
+	// Return indexes
+	db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_return_lines_product_id ON return_lines(product_id);
+	`)
+
+	// Stocktake indexes
+	db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_stocktakes_warehouse_status ON stocktakes(warehouse_id, status);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_stocktakes_warehouse_cycle_date ON stocktakes(warehouse_id, cycle_date) WHERE cycle_date IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_stocktake_lines_abc_class ON stocktake_lines(abc_class);
+	`)
+
+	// Replenishment indexes
+	db.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_supplier_products_preferred ON supplier_products(product_id) WHERE is_preferred;
+		CREATE INDEX IF NOT EXISTS idx_replenishment_suggestions_status ON replenishment_suggestions(run_id, status);
+	`)
+
+	// Alert indexes
+	db.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_alerts_product_kind_open ON alerts(product_id, kind) WHERE status = 'OPEN';
+		CREATE INDEX IF NOT EXISTS idx_alert_deliveries_due ON alert_deliveries(next_attempt_at) WHERE status = 'PENDING';
+	`)
+
+	// Outbox indexes
+	db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_outbox_events_unpublished ON outbox_events(id) WHERE event_offset IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_outbox_events_aggregate ON outbox_events(aggregate_type, aggregate_id);
 	`)
 
 	log.Println("✅ Database indexes created/verified")
@@ -161,5 +263,160 @@ func SeedData(db *gorm.DB) error {
 		log.Println("✅ Seeded admin user: admin@inventory.com / admin123")
 	}
 
+	if err := seedDefaultWarehouse(db); err != nil {
+		return err
+	}
+
+	if err := seedMovementCatalog(db); err != nil {
+		return err
+	}
+
+	if err := runDataMigration(db, "20261017_grant_read_permissions", grantReadPermissions); err != nil {
+		return err
+	}
+
+	if err := seedRoles(db); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// seedMovementCatalog makes sure the system movement types and the default
+// reason codes exist. Existing rows are left as the admins configured them.
+func seedMovementCatalog(db *gorm.DB) error {
+	for _, t := range models.SystemMovementTypes() {
+		var movementType models.MovementType
+		if err := db.Where("code = ?", t.Code).Attrs(t).FirstOrCreate(&movementType).Error; err != nil {
+			return fmt.Errorf("failed to seed movement type %s: %w", t.Code, err)
+		}
+	}
+
+	for _, r := range models.DefaultReasonCodes() {
+		r.IsActive = true
+		var reason models.ReasonCode
+		if err := db.Where("code = ?", r.Code).Attrs(r).FirstOrCreate(&reason).Error; err != nil {
+			return fmt.Errorf("failed to seed reason code %s: %w", r.Code, err)
+		}
+	}
+
+	return nil
+}
+
+// runDataMigration applies a one-time data change and records it by name in
+// the same transaction. A migration already recorded is skipped, even when
+// its effect was undone since.
+func runDataMigration(db *gorm.DB, name string, apply func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.DataMigration{Name: name, AppliedAt: time.Now()})
+		if result.Error != nil {
+			return fmt.Errorf("failed to record data migration %s: %w", name, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		if err := apply(tx); err != nil {
+			return fmt.Errorf("data migration %s failed: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// grantReadPermissions gives every existing role and live API key the read
+// permissions, since both could read everything before those permissions
+// were checked. On a new database there is nothing to grant yet.
+func grantReadPermissions(tx *gorm.DB) error {
+	permissions := models.ReadPermissions()
+
+	var roleIDs []uint
+	if err := tx.Model(&models.Role{}).Pluck("id", &roleIDs).Error; err != nil {
+		return err
+	}
+	for _, roleID := range roleIDs {
+		for _, permission := range permissions {
+			grant := models.RolePermission{RoleID: roleID, Permission: permission}
+			if err := tx.Where(grant).FirstOrCreate(&grant).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	var keys []models.APIKey
+	if err := tx.Where("revoked_at IS NULL").Find(&keys).Error; err != nil {
+		return err
+	}
+	for _, key := range keys {
+		scopes := []string{}
+		seen := map[string]bool{}
+		for _, s := range append(strings.Split(key.Scopes, ","), permissions...) {
+			if s = strings.TrimSpace(s); s != "" && !seen[s] {
+				seen[s] = true
+				scopes = append(scopes, s)
+			}
+		}
+		if err := tx.Model(&models.APIKey{}).Where("id = ?", key.ID).
+			UpdateColumn("scopes", strings.Join(scopes, ",")).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedRoles makes sure the system roles exist. Permissions are granted when a
+// role is first created, except admin which is topped up with every permission.
+func seedRoles(db *gorm.DB) error {
+	for _, r := range models.SystemRoles() {
+		role := models.Role{Name: r.Name, Description: r.Description, IsSystem: true}
+		result := db.Where("name = ?", r.Name).Attrs(role).FirstOrCreate(&role)
+		if result.Error != nil {
+			return fmt.Errorf("failed to seed role %s: %w", r.Name, result.Error)
+		}
+		if result.RowsAffected == 0 && r.Name != models.RoleAdmin {
+			continue
+		}
+
+		for _, permission := range r.Permissions {
+			grant := models.RolePermission{RoleID: role.ID, Permission: permission}
+			if err := db.Where(grant).FirstOrCreate(&grant).Error; err != nil {
+				return fmt.Errorf("failed to seed permission %s for role %s: %w", permission, r.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// seedDefaultWarehouse makes sure a default warehouse exists and moves stock
+// recorded before multi-warehouse support into it
+func seedDefaultWarehouse(db *gorm.DB) error {
+	var warehouse models.Warehouse
+	if err := db.Where("is_default = ?", true).First(&warehouse).Error; err != nil {
+		warehouse = models.Warehouse{
+			Code:      "MAIN",
+			Name:      "Main Warehouse",
+			IsDefault: true,
+			IsActive:  true,
+		}
+		if err := db.Create(&warehouse).Error; err != nil {
+			return fmt.Errorf("failed to seed default warehouse: %w", err)
+		}
+		log.Println("✅ Seeded default warehouse: MAIN")
+	}
+
+	// Products without any balance keep their existing quantity at the default warehouse
+	if err := db.Exec(`
+		INSERT INTO stock_balances (product_id, warehouse_id, quantity, created_at, updated_at)
+		SELECT p.id, ?, p.quantity, NOW(), NOW() FROM products p
+		WHERE NOT EXISTS (SELECT 1 FROM stock_balances b WHERE b.product_id = p.id)
+	`, warehouse.ID).Error; err != nil {
+		return fmt.Errorf("failed to backfill stock balances: %w", err)
+	}
+
+	if err := db.Exec(`UPDATE stocks SET warehouse_id = ? WHERE warehouse_id IS NULL OR warehouse_id = 0`,
+		warehouse.ID).Error; err != nil {
+		return fmt.Errorf("failed to backfill stock warehouses: %w", err)
+	}
+
 	return nil
 }